@@ -0,0 +1,253 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultAckRenotifyInterval is how long a late change may stay unacknowledged
+// before the cook is notified again, unless ACK_RENOTIFY_INTERVAL says otherwise.
+const defaultAckRenotifyInterval = 30 * time.Minute
+
+// Acknowledgement is a late meal change waiting for (or confirmed by) the cook.
+type Acknowledgement struct {
+	ID             int64      `json:"id"`
+	CookUserID     int        `json:"cook_user_id"`
+	CookUserName   string     `json:"cook_user_name"`
+	Change         MealChange `json:"change"`
+	NotifiedAt     time.Time  `json:"notified_at"`
+	NotifyCount    int        `json:"notify_count"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+}
+
+// getPendingAcknowledgementsQuery lists unacknowledged changes for cook $1, oldest first.
+const getPendingAcknowledgementsQuery = `SELECT a.id, a.cook_user_id, c.name,
+    mc.id, TO_CHAR(mc.date, 'YYYY-MM-DD'), mc.user_id, u.name, mc.meal_period,
+    mc.old_option, mc.new_option, mc.actor_id, ac.name, mc.source, mc.cutoff_mode, mc.changed_at,
+    a.notified_at, a.notify_count
+FROM acknowledgements a
+JOIN meal_changes mc ON mc.id = a.meal_change_id
+JOIN users c ON c.id = a.cook_user_id
+JOIN users u ON u.id = mc.user_id
+LEFT JOIN users ac ON ac.id = mc.actor_id
+WHERE a.acknowledged_at IS NULL AND a.cook_user_id = $1
+ORDER BY mc.changed_at, a.id`
+
+// getAcknowledgementQuery returns who has to acknowledge $1 and whether they already have.
+const getAcknowledgementQuery = "SELECT cook_user_id, acknowledged_at IS NOT NULL FROM acknowledgements WHERE id = $1"
+
+// acknowledgeStmt marks $1 as acknowledged by $2 unless someone got there first.
+const acknowledgeStmt = `UPDATE acknowledgements SET acknowledged_at = now(), acknowledged_by = $2
+WHERE id = $1 AND acknowledged_at IS NULL
+RETURNING acknowledged_at`
+
+// renotifyAcknowledgementsStmt bumps every pending acknowledgement last notified at
+// least $1 seconds ago and returns what to tell the cook. Changes to days that have
+// already passed are left alone.
+const renotifyAcknowledgementsStmt = `UPDATE acknowledgements a
+SET notified_at = now(), notify_count = a.notify_count + 1
+FROM meal_changes mc, users c, users u, meal_periods p, meal_options o
+WHERE mc.id = a.meal_change_id AND c.id = a.cook_user_id AND u.id = mc.user_id
+    AND p.id = mc.meal_period AND o.id = mc.new_option
+    AND a.acknowledged_at IS NULL
+    AND a.notified_at <= now() - $1::int * interval '1 second'
+    AND mc.date >= CURRENT_DATE
+RETURNING a.cook_user_id, c.name, TO_CHAR(mc.date, 'YYYY-MM-DD'), u.name, p.name, o.label, a.notify_count`
+
+// lateCookAssignments resolves the cooks for the days of a bulk meal update that have
+// late rows, so each late change can be tied to the cook who has to acknowledge it.
+// It returns nil without querying when nothing is late.
+func lateCookAssignments(updates []MealUpdate, late []bool) (map[string]DailyCookSchedule, error) {
+	var from, to string
+	for i, m := range updates {
+		if !late[i] {
+			continue
+		}
+		if from == "" || m.Date < from {
+			from = m.Date
+		}
+		if to == "" || m.Date > to {
+			to = m.Date
+		}
+	}
+	if from == "" {
+		return nil, nil
+	}
+	return loadCookAssignments(from, to)
+}
+
+// ackCook returns the cook who has to acknowledge a late change to period on date, as a
+// SQL parameter: nil when nobody cooks (各自) or the cook made the change themselves.
+func ackCook(cooks map[string]DailyCookSchedule, date string, period int, caller User) interface{} {
+	a := cooks[date][period]
+	if a == nil || a.CookUserID == caller.ID {
+		return nil
+	}
+	return a.CookUserID
+}
+
+// lateRecipients are who a late change is for: the cook of the period and the member
+// whose meal changed, leaving out whoever made the change. It is empty when they are
+// the same person and made it themselves.
+func lateRecipients(cook *CookAssignment, userID int, userName string, caller User) []Recipient {
+	var to []Recipient
+	if cook != nil && cook.CookUserID != caller.ID {
+		to = append(to, Recipient{UserID: cook.CookUserID, Name: cook.CookUserName})
+	}
+	if userID != caller.ID && (cook == nil || cook.CookUserID != userID) {
+		to = append(to, Recipient{UserID: userID, Name: userName})
+	}
+	return to
+}
+
+// getPendingAcknowledgements lists the late changes the caller has yet to acknowledge.
+// Admins may pass cook_user_id to see another cook's list.
+func getPendingAcknowledgements(c *gin.Context) {
+	caller := currentUser(c)
+	cookID := caller.ID
+	if s := c.Query("cook_user_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cook_user_id"})
+			return
+		}
+		if id != caller.ID && !caller.IsAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you can only list your own acknowledgements"})
+			return
+		}
+		cookID = id
+	}
+
+	rows, err := db.Query(getPendingAcknowledgementsQuery, cookID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	pending := []Acknowledgement{}
+	for rows.Next() {
+		var a Acknowledgement
+		mc := &a.Change
+		var oldOption, actorID sql.NullInt64
+		var actorName, cutoffMode sql.NullString
+		if err := rows.Scan(&a.ID, &a.CookUserID, &a.CookUserName,
+			&mc.ID, &mc.Date, &mc.UserID, &mc.UserName, &mc.MealPeriod,
+			&oldOption, &mc.NewOption, &actorID, &actorName, &mc.Source, &cutoffMode, &mc.ChangedAt,
+			&a.NotifiedAt, &a.NotifyCount); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		mc.OldOption = nullInt(oldOption)
+		mc.ActorID, mc.ActorName = nullInt(actorID), nullString(actorName)
+		mc.CutoffMode = nullString(cutoffMode)
+		pending = append(pending, a)
+	}
+	c.JSON(http.StatusOK, pending)
+}
+
+// acknowledge records that the cook has seen a late change, which stops re-notification.
+// Only that cook or an admin may acknowledge it.
+func acknowledge(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid acknowledgement id"})
+		return
+	}
+	caller := currentUser(c)
+	var cookID int
+	var done bool
+	err = db.QueryRow(getAcknowledgementQuery, id).Scan(&cookID, &done)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "acknowledgement not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if cookID != caller.ID && !caller.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the cook or an admin can acknowledge this change"})
+		return
+	}
+	if done {
+		c.JSON(http.StatusConflict, gin.H{"error": "already acknowledged"})
+		return
+	}
+
+	var at time.Time
+	err = db.QueryRow(acknowledgeStmt, id, caller.ID).Scan(&at)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "already acknowledged"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Acknowledged", "acknowledged_at": at})
+}
+
+// ackRenotifyInterval reads ACK_RENOTIFY_INTERVAL, a Go duration such as "30m".
+// Unset or invalid values fall back to the default; "0" turns re-notification off.
+func ackRenotifyInterval() time.Duration {
+	s := os.Getenv("ACK_RENOTIFY_INTERVAL")
+	if s == "" {
+		return defaultAckRenotifyInterval
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		log.Printf("invalid ACK_RENOTIFY_INTERVAL %q, using %s", s, defaultAckRenotifyInterval)
+		return defaultAckRenotifyInterval
+	}
+	return d
+}
+
+// runAckRenotifier re-sends pending acknowledgements once they have waited interval.
+// It checks at most once a minute and runs until the process exits.
+func runAckRenotifier(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	every := time.Minute
+	if interval < every {
+		every = interval
+	}
+	for range time.Tick(every) {
+		events, err := renotifyPendingAcknowledgements(interval)
+		if err != nil {
+			log.Printf("failed to re-notify pending acknowledgements: %v", err)
+			continue
+		}
+		dispatch.publish(mergeEvents(events)...)
+	}
+}
+
+// renotifyPendingAcknowledgements marks pending acknowledgements that have waited at
+// least interval as notified again and returns a reminder for each, addressed to its cook.
+func renotifyPendingAcknowledgements(interval time.Duration) ([]Event, error) {
+	rows, err := db.Query(renotifyAcknowledgementsStmt, int(interval/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []Event
+	for rows.Next() {
+		var cook, date, userName, periodName, label string
+		var cookID, count int
+		if err := rows.Scan(&cookID, &cook, &date, &userName, &periodName, &label, &count); err != nil {
+			return nil, err
+		}
+		msg := fmt.Sprintf("%s さん、%s の %s さんの%sが「%s」に変更されています。確認をお願いします（%d回目の通知）",
+			cook, date, userName, periodName, label, count)
+		events = append(events, Event{Kind: eventAckReminder, Messages: []string{msg},
+			Recipients: []Recipient{{UserID: cookID, Name: cook}}})
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetPendingAcknowledgements verifies the caller's pending list and that members
+// cannot list another cook's acknowledgements.
+func TestGetPendingAcknowledgements(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	at := time.Date(2025, 2, 16, 9, 12, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(getPendingAcknowledgementsQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "cook_user_id", "cook_user_name",
+			"mc_id", "date", "user_id", "user_name", "meal_period", "old_option", "new_option",
+			"actor_id", "actor_name", "source", "cutoff_mode", "changed_at", "notified_at", "notify_count"}).
+			AddRow(7, 1, "John", 12, "2025-02-16", 3, "Taro", 2, 2, 1, 3, "Taro",
+				"PUT /api/meals/bulk-update", "acknowledge", at, at, 1))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/acknowledgements/pending", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{
+		"id": 7, "cook_user_id": 1, "cook_user_name": "John",
+		"change": {"id":12,"date":"2025-02-16","user_id":3,"user_name":"Taro","meal_period":2,"old_option":2,"new_option":1,
+			"actor_id":3,"actor_name":"Taro","source":"PUT /api/meals/bulk-update","cutoff_mode":"acknowledge","changed_at":"2025-02-16T09:12:00Z"},
+		"notified_at": "2025-02-16T09:12:00Z", "notify_count": 1, "acknowledged_at": null
+	}]`, w.Body.String())
+
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/acknowledgements/pending?cook_user_id=1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestAcknowledge verifies who may acknowledge a change and that it can only be done once.
+func TestAcknowledge(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 5, Name: "Mother", IsCook: true, IsEater: true, Active: true})
+	r := setupRouter()
+
+	state := func(cook int, done bool) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"cook_user_id", "done"}).AddRow(cook, done)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(getAcknowledgementQuery)).WithArgs(int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"cook_user_id", "done"}))
+	mock.ExpectQuery(regexp.QuoteMeta(getAcknowledgementQuery)).WithArgs(int64(7)).WillReturnRows(state(2, false))
+	mock.ExpectQuery(regexp.QuoteMeta(getAcknowledgementQuery)).WithArgs(int64(7)).WillReturnRows(state(5, true))
+	mock.ExpectQuery(regexp.QuoteMeta(getAcknowledgementQuery)).WithArgs(int64(7)).WillReturnRows(state(5, false))
+	mock.ExpectQuery(regexp.QuoteMeta(acknowledgeStmt)).WithArgs(int64(7), 5).
+		WillReturnRows(sqlmock.NewRows([]string{"acknowledged_at"}).AddRow(time.Date(2025, 2, 16, 10, 0, 0, 0, time.UTC)))
+
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/api/acknowledgements/x", http.StatusBadRequest},
+		{"/api/acknowledgements/8", http.StatusNotFound},
+		{"/api/acknowledgements/7", http.StatusForbidden},
+		{"/api/acknowledgements/7", http.StatusConflict},
+		{"/api/acknowledgements/7", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", tc.path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.path)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestRenotifyPendingAcknowledgements verifies the interval is passed in seconds and
+// each returned row becomes a reminder.
+func TestRenotifyPendingAcknowledgements(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(renotifyAcknowledgementsStmt)).WithArgs(1800).
+		WillReturnRows(sqlmock.NewRows([]string{"cook_user_id", "cook", "date", "user_name", "period", "label", "notify_count"}).
+			AddRow(5, "Mother", "2025-02-16", "Taro", "夕食", "なし", 2))
+	events, err := renotifyPendingAcknowledgements(30 * time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []Event{{Kind: eventAckReminder,
+		Messages:   []string{"Mother さん、2025-02-16 の Taro さんの夕食が「なし」に変更されています。確認をお願いします（2回目の通知）"},
+		Recipients: []Recipient{{UserID: 5, Name: "Mother"}}}}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestAckRenotifyInterval verifies ACK_RENOTIFY_INTERVAL parsing and its fallbacks.
+func TestAckRenotifyInterval(t *testing.T) {
+	for env, want := range map[string]time.Duration{
+		"":    defaultAckRenotifyInterval,
+		"10m": 10 * time.Minute,
+		"0":   0,
+		"bad": defaultAckRenotifyInterval,
+	} {
+		t.Setenv("ACK_RENOTIFY_INTERVAL", env)
+		assert.Equal(t, want, ackRenotifyInterval(), env)
+	}
+}
+
+// TestBulkUpdateMealsAcknowledge verifies that every late row is passed its cook to
+// acknowledge, that the cutoff mode only labels the message, and that rows whose
+// option did not change are not announced.
+func TestBulkUpdateMealsAcknowledge(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	events := notificationStandIn(t)
+
+	// Dinner locks at 15:00 and needs the cook's acknowledgement; lunch has no cutoff.
+	expectBulkUpdatePrelude(mock, sqlmock.NewRows([]string{"id", "name", "sort_order", "cutoff_time", "cutoff_mode", "packs_bento", "active"}).
+		AddRow(1, "昼食", 2, nil, "notify", true, true).
+		AddRow(2, "夕食", 3, "15:00", "acknowledge", false, true))
+	mock.ExpectQuery(regexp.QuoteMeta(getCookSchedulesQuery)).WithArgs("2024-02-04", "2024-02-04").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name"}).
+			AddRow("2024-02-04", 1, 5, "Mother").
+			AddRow("2024-02-04", 2, 5, "Mother"))
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(bulkUpdateMealsStmt))
+	src := "PUT /api/meals/bulk-update"
+	prep.ExpectQuery().WithArgs(3, "2024-02-04", 1, 1, 1, src, nil, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"changed"}).AddRow(false))
+	prep.ExpectQuery().WithArgs(3, "2024-02-04", 2, 1, 1, src, cutoffAcknowledge, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"changed"}).AddRow(true))
+	mock.ExpectCommit()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/meals/bulk-update", bytes.NewBufferString(
+		`[{"user_id":3,"date":"2024-02-04","options":{"1":1,"2":1}}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, Event{Kind: eventLateMealChange, Messages: []string{
+		"John さんが 2024-02-04 の Taro さんの夕食を「なし」に変更しました（締め切り後・要確認）",
+	}}, nextEvent(t, events))
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected notification: %v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestLateRecipients verifies a late change is addressed to the cook and the member,
+// but never to whoever made it.
+func TestLateRecipients(t *testing.T) {
+	mother := &CookAssignment{CookUserID: 5, CookUserName: "Mother"}
+	john := User{ID: 1, Name: "John"}
+	assert.Equal(t, []Recipient{{UserID: 5, Name: "Mother"}, {UserID: 3, Name: "Taro"}}, lateRecipients(mother, 3, "Taro", john))
+	assert.Equal(t, []Recipient{{UserID: 5, Name: "Mother"}}, lateRecipients(mother, 1, "John", john))
+	assert.Equal(t, []Recipient{{UserID: 3, Name: "Taro"}}, lateRecipients(mother, 3, "Taro", User{ID: 5}))
+	assert.Equal(t, []Recipient{{UserID: 5, Name: "Mother"}}, lateRecipients(mother, 5, "Mother", john))
+	assert.Empty(t, lateRecipients(nil, 1, "John", john))
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sessionCookieName is the cookie that carries the session token for browser clients.
+// API clients may send the same token as "Authorization: Bearer <token>" instead.
+const sessionCookieName = "session"
+
+// sessionTTL is how long a session stays valid after login.
+const sessionTTL = 30 * 24 * time.Hour
+
+// callerKey is the gin context key under which requireAuth stores the calling User.
+const callerKey = "caller"
+
+// pinPattern restricts PINs to 4-8 digits so they can be typed on a phone keypad.
+var pinPattern = regexp.MustCompile(`^[0-9]{4,8}$`)
+
+// minPasswordLength is the shortest password accepted by setUserCredentials.
+const minPasswordLength = 8
+
+var errUnauthenticated = errors.New("authentication required")
+
+// Failed logins are throttled per name and per client IP, so a 4-digit PIN cannot be
+// guessed through the API: after loginFreeAttempts failures each further failure
+// locks that name or IP out for twice as long, from loginBackoff up to loginLockout.
+// Failures older than loginFailureWindow are forgotten; a successful login clears them.
+const (
+	loginFreeAttempts  = 3
+	loginBackoff       = time.Second
+	loginLockout       = 15 * time.Minute
+	loginFailureWindow = 24 * time.Hour
+)
+
+// loginThrottle counts recent login failures by key ("name:..." or "ip:...").
+type loginThrottle struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+	now      func() time.Time
+}
+
+type loginFailures struct {
+	count  int
+	last   time.Time
+	locked time.Time // no attempts before this
+}
+
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{failures: map[string]*loginFailures{}, now: time.Now}
+}
+
+// logins throttles POST /api/auth/login.
+var logins = newLoginThrottle()
+
+// wait returns how long the keys are still locked out, or 0.
+func (t *loginThrottle) wait(keys ...string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	var longest time.Duration
+	for _, k := range keys {
+		if f, ok := t.failures[k]; ok && f.locked.Sub(now) > longest {
+			longest = f.locked.Sub(now)
+		}
+	}
+	return longest
+}
+
+// fail records a failed login for the keys.
+func (t *loginThrottle) fail(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for k, f := range t.failures {
+		if now.Sub(f.last) > loginFailureWindow {
+			delete(t.failures, k)
+		}
+	}
+	for _, k := range keys {
+		f, ok := t.failures[k]
+		if !ok {
+			f = &loginFailures{}
+			t.failures[k] = f
+		}
+		f.count++
+		f.last = now
+		if n := f.count - loginFreeAttempts; n > 0 {
+			backoff := loginLockout
+			if n <= 10 && loginBackoff<<(n-1) < loginLockout {
+				backoff = loginBackoff << (n - 1)
+			}
+			f.locked = now.Add(backoff)
+		}
+	}
+}
+
+// succeed clears the failures of the keys.
+func (t *loginThrottle) succeed(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, k := range keys {
+		delete(t.failures, k)
+	}
+}
+
+// LoginRequest is the request body for POST /api/auth/login.
+// Exactly one of Password or PIN must be set.
+type LoginRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	PIN      string `json:"pin"`
+}
+
+// CredentialsUpdate is the request body for PUT /api/users/:user_id/credentials.
+// Nil fields are left unchanged; "" removes that credential.
+type CredentialsUpdate struct {
+	Password *string `json:"password"`
+	PIN      *string `json:"pin"`
+}
+
+// loginCandidatesQuery finds active users by name; names are not unique, so every
+// match is tried against the supplied secret.
+const loginCandidatesQuery = `SELECT id, name, is_cook, is_eater, display_order, active, is_admin,
+    COALESCE(password_hash, ''), COALESCE(pin_hash, '')
+FROM users
+WHERE name = $1 AND active
+ORDER BY id`
+
+// credentialsExistQuery reports whether anyone has a password or PIN yet.
+// Until then the API is in setup mode and the first login sets the secret it was given.
+const credentialsExistQuery = "SELECT EXISTS (SELECT 1 FROM users WHERE password_hash IS NOT NULL OR pin_hash IS NOT NULL)"
+
+const createSessionStmt = "INSERT INTO sessions (token_hash, user_id, expires_at) VALUES ($1, $2, $3)"
+
+const deleteExpiredSessionsStmt = "DELETE FROM sessions WHERE expires_at <= now()"
+
+const deleteSessionStmt = "DELETE FROM sessions WHERE token_hash = $1"
+
+// getSessionUserQuery resolves a token hash to its (still active) user.
+const getSessionUserQuery = `SELECT u.id, u.name, u.is_cook, u.is_eater, u.display_order, u.active, u.is_admin
+FROM sessions s
+JOIN users u ON u.id = s.user_id
+WHERE s.token_hash = $1 AND s.expires_at > now() AND u.active`
+
+const updateCredentialsStmt = `UPDATE users SET
+    password_hash = CASE WHEN $1::bool THEN $2::text ELSE password_hash END,
+    pin_hash      = CASE WHEN $3::bool THEN $4::text ELSE pin_hash END
+WHERE id = $5`
+
+// promoteFirstAdminStmt makes the setup-mode user an admin unless the household already has one.
+const promoteFirstAdminStmt = "UPDATE users SET is_admin = true WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM users WHERE is_admin)"
+
+const userExistsQuery = "SELECT id FROM users WHERE id = $1"
+
+// login checks a member's password or PIN and starts a session.
+// The token is returned in the body for API clients and set as an HttpOnly cookie for browsers.
+func login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || (req.Password == "") == (req.PIN == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and exactly one of password or pin are required"})
+		return
+	}
+	keys := []string{"name:" + name, "ip:" + c.ClientIP()}
+	if wait := logins.wait(keys...); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed logins, try again later"})
+		return
+	}
+
+	rows, err := db.Query(loginCandidatesQuery, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	var candidates []User
+	var matched *User
+	for rows.Next() {
+		var u User
+		var passwordHash, pinHash string
+		if err := rows.Scan(&u.ID, &u.Name, &u.IsCook, &u.IsEater, &u.DisplayOrder, &u.Active, &u.IsAdmin, &passwordHash, &pinHash); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		candidates = append(candidates, u)
+		hash, secret := passwordHash, req.Password
+		if req.PIN != "" {
+			hash, secret = pinHash, req.PIN
+		}
+		if matched == nil && hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil {
+			matched = &candidates[len(candidates)-1]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if matched == nil && len(candidates) > 0 {
+		// Setup mode: nobody has credentials yet, so the first login claims its secret.
+		var exists bool
+		if err := db.QueryRow(credentialsExistQuery).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !exists {
+			update := CredentialsUpdate{Password: &req.Password}
+			if req.PIN != "" {
+				update = CredentialsUpdate{PIN: &req.PIN}
+			}
+			if status, err := storeCredentials(candidates[0].ID, update); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			matched = &candidates[0]
+			result, err := db.Exec(promoteFirstAdminStmt, matched.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if n, _ := result.RowsAffected(); n == 1 {
+				matched.IsAdmin = true
+			}
+		}
+	}
+	if matched == nil {
+		logins.fail(keys...)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid name or credentials"})
+		return
+	}
+	logins.succeed(keys...)
+
+	token, err := newSessionToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := db.Exec(createSessionStmt, hashToken(token), matched.ID, time.Now().Add(sessionTTL)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Housekeeping: logins are rare enough that pruning here keeps the table small.
+	if _, err := db.Exec(deleteExpiredSessionsStmt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookieName, token, int(sessionTTL.Seconds()), "/", "", overTLS(c), true)
+	c.JSON(http.StatusOK, gin.H{"token": token, "user": matched})
+}
+
+// logout ends the caller's session. It succeeds even if the session is already gone.
+func logout(c *gin.Context) {
+	if token := sessionToken(c); token != "" {
+		if _, err := db.Exec(deleteSessionStmt, hashToken(token)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookieName, "", -1, "/", "", overTLS(c), true)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// overTLS reports whether the request reached us, or the proxy in front of us, over
+// HTTPS; the session cookie is then marked Secure.
+func overTLS(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+// getMe returns the user the current session belongs to.
+func getMe(c *gin.Context) {
+	c.JSON(http.StatusOK, currentUser(c))
+}
+
+// setUserCredentials sets or clears a member's password and/or PIN.
+// Members may only change their own credentials; admins may set or reset anyone's,
+// which is how the rest of the household is onboarded.
+// Before anyone has credentials (setup mode) no session is required. The user is
+// looked up only once the caller is allowed, so ids cannot be probed anonymously.
+func setUserCredentials(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	var req CredentialsUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	caller, err := authenticate(c)
+	switch {
+	case err == errUnauthenticated:
+		var exists bool
+		if err := db.QueryRow(credentialsExistQuery).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errUnauthenticated.Error()})
+			return
+		}
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	case userID != caller.ID && !caller.IsAdmin:
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own credentials"})
+		return
+	}
+
+	err = db.QueryRow(userExistsQuery, userID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := storeCredentials(userID, req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Credentials updated"})
+}
+
+// storeCredentials validates and hashes the given secrets and writes them.
+// It returns the HTTP status to use when it fails.
+func storeCredentials(userID int, req CredentialsUpdate) (int, error) {
+	var passwordHash, pinHash interface{}
+	if req.Password != nil && *req.Password != "" {
+		if len(*req.Password) < minPasswordLength {
+			return http.StatusBadRequest, errors.New("password must be at least 8 characters")
+		}
+		h, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		passwordHash = string(h)
+	}
+	if req.PIN != nil && *req.PIN != "" {
+		if !pinPattern.MatchString(*req.PIN) {
+			return http.StatusBadRequest, errors.New("pin must be 4 to 8 digits")
+		}
+		h, err := bcrypt.GenerateFromPassword([]byte(*req.PIN), bcrypt.DefaultCost)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		pinHash = string(h)
+	}
+	if _, err := db.Exec(updateCredentialsStmt, req.Password != nil, passwordHash, req.PIN != nil, pinHash, userID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// requireAuth rejects requests without a valid session and stores the caller
+// in the context for handlers (see currentUser).
+func requireAuth(c *gin.Context) {
+	u, err := authenticate(c)
+	if err == errUnauthenticated {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Set(callerKey, u)
+	c.Next()
+}
+
+// authenticate resolves the request's session token to a user.
+func authenticate(c *gin.Context) (User, error) {
+	var u User
+	token := sessionToken(c)
+	if token == "" {
+		return u, errUnauthenticated
+	}
+	err := db.QueryRow(getSessionUserQuery, hashToken(token)).
+		Scan(&u.ID, &u.Name, &u.IsCook, &u.IsEater, &u.DisplayOrder, &u.Active, &u.IsAdmin)
+	if err == sql.ErrNoRows {
+		return u, errUnauthenticated
+	}
+	return u, err
+}
+
+// currentUser returns the caller stored by requireAuth.
+func currentUser(c *gin.Context) User {
+	u, _ := c.MustGet(callerKey).(User)
+	return u
+}
+
+// sessionToken reads the token from the Authorization header, falling back to the cookie.
+func sessionToken(c *gin.Context) string {
+	if h := c.GetHeader("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	token, _ := c.Cookie(sessionCookieName)
+	return token
+}
+
+// newSessionToken returns 32 random bytes, hex encoded.
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken is what is stored in sessions.token_hash; the raw token is never persisted,
+// so a leaked sessions table cannot be replayed.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
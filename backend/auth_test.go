@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loginCandidateRows returns one login candidate (Taro, id=3) with the given hashes.
+func loginCandidateRows(passwordHash, pinHash string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin", "password_hash", "pin_hash"}).
+		AddRow(3, "Taro", false, true, 3, true, false, passwordHash, pinHash)
+}
+
+// TestLoginWithPIN verifies that a correct PIN creates a session whose token is
+// returned in the body and set as an HttpOnly cookie.
+func TestLoginWithPIN(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	pinHash, _ := bcrypt.GenerateFromPassword([]byte("1234"), bcrypt.MinCost)
+	mock.ExpectQuery(regexp.QuoteMeta(loginCandidatesQuery)).WithArgs("Taro").
+		WillReturnRows(loginCandidateRows("", string(pinHash)))
+	mock.ExpectExec(regexp.QuoteMeta(createSessionStmt)).
+		WithArgs(sqlmock.AnyArg(), 3, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(deleteExpiredSessionsStmt)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBufferString(`{"name":"Taro","pin":"1234"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Token string `json:"token"`
+		User  User   `json:"user"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Token, 64)
+	assert.Equal(t, 3, resp.User.ID)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), sessionCookieName+"="+resp.Token)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "HttpOnly")
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "Secure")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestLoginWrongPIN verifies that a wrong PIN is rejected without creating a session.
+func TestLoginWrongPIN(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	t.Cleanup(func() { logins = newLoginThrottle() })
+
+	pinHash, _ := bcrypt.GenerateFromPassword([]byte("1234"), bcrypt.MinCost)
+	mock.ExpectQuery(regexp.QuoteMeta(loginCandidatesQuery)).WithArgs("Taro").
+		WillReturnRows(loginCandidateRows("", string(pinHash)))
+	mock.ExpectQuery(regexp.QuoteMeta(credentialsExistQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBufferString(`{"name":"Taro","pin":"9999"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestLoginThrottled verifies that repeated failures lock a name out with a 429
+// before the database is asked, with a growing backoff and a lockout cap.
+func TestLoginThrottled(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	now := time.Date(2025, 2, 17, 12, 0, 0, 0, time.UTC)
+	logins = newLoginThrottle()
+	logins.now = func() time.Time { return now }
+	t.Cleanup(func() { logins = newLoginThrottle() })
+
+	for i := 0; i < loginFreeAttempts; i++ {
+		logins.fail("name:Taro")
+		assert.Zero(t, logins.wait("name:Taro"))
+	}
+	logins.fail("name:Taro")
+	assert.Equal(t, loginBackoff, logins.wait("name:Taro", "ip:"))
+	logins.fail("name:Taro")
+	assert.Equal(t, 2*loginBackoff, logins.wait("name:Taro"))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBufferString(`{"name":" Taro ","pin":"1234"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	for i := 0; i < 20; i++ {
+		logins.fail("name:Taro")
+	}
+	assert.Equal(t, loginLockout, logins.wait("name:Taro"))
+	now = now.Add(loginLockout)
+	assert.Zero(t, logins.wait("name:Taro"))
+	logins.succeed("name:Taro")
+	logins.fail("name:Taro")
+	assert.Zero(t, logins.wait("name:Taro"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestLoginSetupMode verifies that before anyone has credentials, the first login
+// stores the secret it was given and becomes the first admin.
+func TestLoginSetupMode(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(loginCandidatesQuery)).WithArgs("Taro").
+		WillReturnRows(loginCandidateRows("", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(credentialsExistQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta(updateCredentialsStmt)).
+		WithArgs(false, nil, true, sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(promoteFirstAdminStmt)).WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(createSessionStmt)).
+		WithArgs(sqlmock.AnyArg(), 3, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(deleteExpiredSessionsStmt)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBufferString(`{"name":"Taro","pin":"1234"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"is_admin":true`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestRequireAuth verifies that requests without a session are rejected and that
+// a bearer token is resolved to the caller through its hash.
+func TestRequireAuth(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/auth/me", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getSessionUserQuery)).WithArgs(hashToken("tok")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}).
+			AddRow(3, "Taro", false, true, 3, true, false))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer tok")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":3,"name":"Taro","is_cook":false,"is_eater":true,"display_order":3,"active":true,"is_admin":false}`, w.Body.String())
+
+	// Expired or unknown sessions come back as no rows.
+	mock.ExpectQuery(regexp.QuoteMeta(getSessionUserQuery)).WithArgs(hashToken("old")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/auth/me", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "old"})
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestLogout verifies that logout deletes the session row and clears the cookie.
+func TestLogout(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectExec(regexp.QuoteMeta(deleteSessionStmt)).WithArgs(hashToken("tok")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/auth/logout", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "tok"})
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "Max-Age=0")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSetUserCredentialsOthers verifies that once credentials exist, a member
+// cannot set another member's credentials, even if that member has none yet.
+func TestSetUserCredentialsOthers(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getSessionUserQuery)).WithArgs(hashToken("tok")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}).
+			AddRow(3, "Taro", false, true, 3, true, false))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/users/4/credentials", bytes.NewBufferString(`{"pin":"0000"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer tok")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSetUserCredentialsAnonymous verifies that once credentials exist, an anonymous
+// caller gets 401 whether or not the user exists, so member ids cannot be probed.
+func TestSetUserCredentialsAnonymous(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	for _, path := range []string{"/api/users/4/credentials", "/api/users/42/credentials"} {
+		mock.ExpectQuery(regexp.QuoteMeta(credentialsExistQuery)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", path, bytes.NewBufferString(`{"pin":"0000"}`))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForbiddenRow explains why one element of a bulk request was rejected.
+// Index is the element's position in the request body.
+type ForbiddenRow struct {
+	Index  int    `json:"index"`
+	UserID int    `json:"user_id"`
+	Date   string `json:"date,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// requireAdmin rejects callers without the admin role. It must run after requireAuth.
+func requireAdmin(c *gin.Context) {
+	if !currentUser(c).IsAdmin {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+		return
+	}
+	c.Next()
+}
+
+// requireCook rejects callers who are neither cooks nor admins. It must run after
+// requireAuth.
+func requireCook(c *gin.Context) {
+	if u := currentUser(c); !u.IsCook && !u.IsAdmin {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "cook role required"})
+		return
+	}
+	c.Next()
+}
+
+// loadCookAssignments resolves the cook for every active period of every day in
+// [from, to], using the same precedence as GET /api/cook-schedules.
+func loadCookAssignments(from, to string) (map[string]DailyCookSchedule, error) {
+	rows, err := db.Query(getCookSchedulesQuery, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[string]DailyCookSchedule)
+	for rows.Next() {
+		var dateStr string
+		var mealPeriod int
+		var cookUserID sql.NullInt64
+		var cookUserName sql.NullString
+		if err := rows.Scan(&dateStr, &mealPeriod, &cookUserID, &cookUserName); err != nil {
+			return nil, err
+		}
+		if _, ok := result[dateStr]; !ok {
+			result[dateStr] = DailyCookSchedule{}
+		}
+		var assignment *CookAssignment
+		if cookUserID.Valid {
+			assignment = &CookAssignment{
+				CookUserID:   int(cookUserID.Int64),
+				CookUserName: cookUserName.String,
+			}
+		}
+		result[dateStr][mealPeriod] = assignment
+	}
+	return result, rows.Err()
+}
+
+// isCookOn reports whether userID cooks any period of the given day.
+func (s DailyCookSchedule) isCookOn(userID int) bool {
+	for _, a := range s {
+		if a != nil && a.CookUserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// forbiddenMealUpdates returns the rows of a bulk meal update the caller may not write.
+// Members may change their own rows; admins may change any row, and the cook of a day
+// may change anyone's rows for that day. Dates must already be validated.
+func forbiddenMealUpdates(caller User, updates []MealUpdate) ([]ForbiddenRow, error) {
+	if caller.IsAdmin {
+		return nil, nil
+	}
+	var others []int
+	var from, to string
+	for i, m := range updates {
+		if m.UserID == caller.ID {
+			continue
+		}
+		others = append(others, i)
+		if from == "" || m.Date < from {
+			from = m.Date
+		}
+		if to == "" || m.Date > to {
+			to = m.Date
+		}
+	}
+	if len(others) == 0 {
+		return nil, nil
+	}
+
+	cooks, err := loadCookAssignments(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var denied []ForbiddenRow
+	for _, i := range others {
+		m := updates[i]
+		if cooks[m.Date].isCookOn(caller.ID) {
+			continue
+		}
+		denied = append(denied, ForbiddenRow{
+			Index:  i,
+			UserID: m.UserID,
+			Date:   m.Date,
+			Reason: "only the member, an admin or the cook for " + m.Date + " can change this row",
+		})
+	}
+	return denied, nil
+}
+
+// validDate reports whether s is a YYYY-MM-DD date.
+func validDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// asCaller replaces the caller setupRouter injects for the rest of the test.
+func asCaller(t *testing.T, u User) {
+	prev := testCallerUser
+	testCallerUser = u
+	t.Cleanup(func() { testCallerUser = prev })
+}
+
+// TestRequireAdmin verifies that admin-only routes reject members before any DB access.
+func TestRequireAdmin(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+
+	r := setupRouter()
+	for _, tc := range []struct{ method, path, body string }{
+		{"PUT", "/api/users/3/roles", `{"is_cook":true,"is_eater":true}`},
+		{"PUT", "/api/cook-default-schedules", `[]`},
+		{"DELETE", "/api/cook-schedules", `[]`},
+		{"PUT", "/api/cook-schedules", `[]`},
+		{"POST", "/api/meal-periods", `{"name":"朝食"}`},
+		{"POST", "/api/meal-options", `{"label":"外食"}`},
+		{"PATCH", "/api/meal-options/1", `{"active":false}`},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, tc.path)
+		assert.JSONEq(t, `{"error":"admin role required"}`, w.Body.String())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBulkUpdateMealsForbiddenRows verifies that a member may change their own rows
+// and other members' rows on days they cook, and that any other row rejects the
+// whole request with a per-row explanation and no write.
+func TestBulkUpdateMealsForbiddenRows(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 1, Name: "John", IsCook: true, IsEater: true, Active: true})
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(true).WillReturnRows(mealOptionRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	// John cooks dinner on the 4th only.
+	mock.ExpectQuery(regexp.QuoteMeta(getCookSchedulesQuery)).WithArgs("2024-02-04", "2024-02-05").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name"}).
+			AddRow("2024-02-04", 1, nil, nil).
+			AddRow("2024-02-04", 2, 1, "John").
+			AddRow("2024-02-05", 1, nil, nil).
+			AddRow("2024-02-05", 2, 5, "Mother"))
+
+	payload := `[
+		{"user_id":1,"date":"2024-02-05","options":{"1":3}},
+		{"user_id":2,"date":"2024-02-04","options":{"2":1}},
+		{"user_id":2,"date":"2024-02-05","options":{"2":1}}
+	]`
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/meals/bulk-update", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{
+		"error": "some rows belong to other members",
+		"rows": [{"index":2,"user_id":2,"date":"2024-02-05","reason":"only the member, an admin or the cook for 2024-02-05 can change this row"}]
+	}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateUserDefaultsOthers verifies that members cannot change another member's defaults.
+func TestUpdateUserDefaultsOthers(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/user-defaults/4", bytes.NewBufferString(`[{"day_of_week":0,"options":{"1":3}}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
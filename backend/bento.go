@@ -0,0 +1,244 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// defaultBentoReminderTime is when the next day's 弁当 list is sent to its cook,
+// unless BENTO_REMINDER_TIME says otherwise.
+const defaultBentoReminderTime = "20:00"
+
+// BentoList is the response of GET /api/bento: who takes a 弁当 on a date and who packs
+// them. Cook is the cook of the period marked packs_bento (昼食); nil means 各自.
+type BentoList struct {
+	Date   string          `json:"date"`
+	Cook   *CookAssignment `json:"cook"`
+	Count  int             `json:"count"`
+	Bentos []Bento         `json:"bentos"`
+}
+
+// Bento is one member's 弁当 for the day. MealPeriods are the periods it is for, in
+// display order; Note is their standing preference (size, no rice, ...).
+type Bento struct {
+	UserID      int     `json:"user_id"`
+	Name        string  `json:"name"`
+	MealPeriods []int   `json:"meal_periods"`
+	Note        *string `json:"note"`
+}
+
+// BentoNoteUpdate is the request body for PUT /api/users/:user_id/bento-note.
+type BentoNoteUpdate struct {
+	BentoNote *string `json:"bento_note"`
+}
+
+// getBentoQuery lists the members whose choice (resolved as in getMealsQuery) for any
+// active period of date $1 is a 弁当 option, with the periods and their bento_note.
+const getBentoQuery = `WITH choices AS (
+    SELECT u.id AS user_id, u.name, u.display_order, u.bento_note, p.id AS meal_period, p.sort_order,
+        COALESCE(m.meal_option, ud.meal_option, 1) AS meal_option
+    FROM users u
+    CROSS JOIN meal_periods p
+    LEFT JOIN meals m ON m.user_id = u.id AND m.date = $1::date AND m.meal_period = p.id
+    LEFT JOIN user_defaults ud ON ud.user_id = u.id
+        AND ud.day_of_week = EXTRACT(DOW FROM $1::date)
+        AND ud.meal_period = p.id
+    WHERE u.is_eater = true AND u.active = true AND p.active = true
+)
+SELECT c.user_id, c.name, c.bento_note, array_agg(c.meal_period ORDER BY c.sort_order, c.meal_period)
+FROM choices c
+JOIN meal_options o ON o.id = c.meal_option AND o.is_bento
+GROUP BY c.user_id, c.name, c.display_order, c.bento_note
+ORDER BY c.display_order, c.user_id`
+
+const updateBentoNoteStmt = "UPDATE users SET bento_note = $1 WHERE id = $2 RETURNING id"
+
+// getBento returns the 弁当 to pack on ?date=.
+func getBento(c *gin.Context) {
+	date, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	list, err := loadBentoList(date.Format("2006-01-02"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// updateBentoNote sets a member's 弁当 preference. Members may only change their own;
+// admins may change anyone's. A blank note clears it.
+func updateBentoNote(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if caller := currentUser(c); !caller.IsAdmin && userID != caller.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own bento note"})
+		return
+	}
+	var req BentoNoteUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	note := optionalText(req.BentoNote)
+	err = db.QueryRow(updateBentoNoteStmt, note, userID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "bento_note": note})
+}
+
+// loadBentoList collects the 弁当 of date and the cook who packs them.
+func loadBentoList(date string) (BentoList, error) {
+	list := BentoList{Date: date, Bentos: []Bento{}}
+	rows, err := db.Query(getBentoQuery, date)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b Bento
+		var note sql.NullString
+		var periods pq.Int64Array
+		if err := rows.Scan(&b.UserID, &b.Name, &note, &periods); err != nil {
+			return list, err
+		}
+		b.Note = nullString(note)
+		b.MealPeriods = make([]int, len(periods))
+		for i, p := range periods {
+			b.MealPeriods[i] = int(p)
+		}
+		list.Bentos = append(list.Bentos, b)
+	}
+	if err := rows.Err(); err != nil {
+		return list, err
+	}
+	list.Count = len(list.Bentos)
+	list.Cook, err = bentoCook(date)
+	return list, err
+}
+
+// bentoCook resolves the cook of date's active period marked packs_bento, who packs
+// the 弁当. Without such a period, or without a cook for it, everyone packs their own.
+func bentoCook(date string) (*CookAssignment, error) {
+	periods, err := queryMealPeriods(false)
+	if err != nil {
+		return nil, err
+	}
+	packing := 0
+	for _, p := range periods {
+		if p.PacksBento {
+			packing = p.ID
+			break
+		}
+	}
+	if packing == 0 {
+		return nil, nil
+	}
+	rows, err := db.Query(getCookSchedulesQuery, date, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dateStr string
+		var periodID int
+		var cookUserID sql.NullInt64
+		var cookUserName sql.NullString
+		if err := rows.Scan(&dateStr, &periodID, &cookUserID, &cookUserName); err != nil {
+			return nil, err
+		}
+		if periodID == packing && cookUserID.Valid {
+			return &CookAssignment{CookUserID: int(cookUserID.Int64), CookUserName: cookUserName.String}, nil
+		}
+	}
+	return nil, rows.Err()
+}
+
+// bentoReminderMessage tells the cook how many 弁当 to pack for whom. It is empty
+// when nobody takes one.
+func bentoReminderMessage(list BentoList) string {
+	if list.Count == 0 {
+		return ""
+	}
+	names := make([]string, len(list.Bentos))
+	for i, b := range list.Bentos {
+		names[i] = b.Name
+		if b.Note != nil {
+			names[i] += "（" + *b.Note + "）"
+		}
+	}
+	to := "料理担当なし（各自）"
+	if list.Cook != nil {
+		to = list.Cook.CookUserName + " さん"
+	}
+	return fmt.Sprintf("%s、明日 %s のお弁当は %d 個です: %s", to, list.Date, list.Count, strings.Join(names, "、"))
+}
+
+// bentoReminderTime reads BENTO_REMINDER_TIME, a local time of day such as "20:00".
+// Unset or invalid values fall back to the default; "off" turns the reminder off.
+func bentoReminderTime() (hour, minute int, ok bool) {
+	s := os.Getenv("BENTO_REMINDER_TIME")
+	if s == "off" {
+		return 0, 0, false
+	}
+	if s == "" {
+		s = defaultBentoReminderTime
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		log.Printf("invalid BENTO_REMINDER_TIME %q, using %s", s, defaultBentoReminderTime)
+		t, _ = time.Parse("15:04", defaultBentoReminderTime)
+	}
+	return t.Hour(), t.Minute(), true
+}
+
+// runBentoReminder sends the next day's 弁当 list every evening at hour:minute local
+// time. It runs until the process exits.
+func runBentoReminder(hour, minute int, ok bool) {
+	if !ok {
+		return
+	}
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, time.Local)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(next))
+		list, err := loadBentoList(next.AddDate(0, 0, 1).Format("2006-01-02"))
+		if err != nil {
+			log.Printf("failed to load tomorrow's bento list: %v", err)
+			continue
+		}
+		msg := bentoReminderMessage(list)
+		if msg == "" {
+			continue
+		}
+		// Without a cook everyone packs their own, so the whole household is told.
+		e := Event{Kind: eventBentoReminder, Messages: []string{msg}}
+		if list.Cook != nil {
+			e.Recipients = []Recipient{{UserID: list.Cook.CookUserID, Name: list.Cook.CookUserName}}
+		}
+		dispatch.publish(e)
+	}
+}
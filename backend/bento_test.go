@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetBento verifies the 弁当 of a day come with their periods and notes, and that
+// the cook of the packs_bento period packs them.
+func TestGetBento(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getBentoQuery)).WithArgs("2025-02-17").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "bento_note", "meal_periods"}).
+			AddRow(1, "John", "ご飯少なめ", "{1}").
+			AddRow(3, "Taro", nil, "{1,2}"))
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(false).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getCookSchedulesQuery)).WithArgs("2025-02-17", "2025-02-17").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name"}).
+			AddRow("2025-02-17", 1, 5, "Mother").
+			AddRow("2025-02-17", 2, nil, nil))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/bento?date=2025-02-17", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"date":"2025-02-17","cook":{"cook_user_id":5,"cook_user_name":"Mother"},"count":2,"bentos":[
+		{"user_id":1,"name":"John","meal_periods":[1],"note":"ご飯少なめ"},
+		{"user_id":3,"name":"Taro","meal_periods":[1,2],"note":null}
+	]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/bento?date=2025-02-30", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetBentoWithBreakfast verifies the 昼食 cook still packs the 弁当 once 朝食 is
+// added ahead of it in display order.
+func TestGetBentoWithBreakfast(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getBentoQuery)).WithArgs("2025-02-17").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "bento_note", "meal_periods"}).
+			AddRow(1, "John", nil, "{1}"))
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sort_order", "cutoff_time", "cutoff_mode", "packs_bento", "active"}).
+			AddRow(3, "朝食", 1, nil, "notify", false, true).
+			AddRow(1, "昼食", 2, nil, "notify", true, true).
+			AddRow(2, "夕食", 3, "15:00", "notify", false, true))
+	mock.ExpectQuery(regexp.QuoteMeta(getCookSchedulesQuery)).WithArgs("2025-02-17", "2025-02-17").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name"}).
+			AddRow("2025-02-17", 3, 2, "Paul").
+			AddRow("2025-02-17", 1, 5, "Mother").
+			AddRow("2025-02-17", 2, 2, "Paul"))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/bento?date=2025-02-17", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"date":"2025-02-17","cook":{"cook_user_id":5,"cook_user_name":"Mother"},"count":1,"bentos":[
+		{"user_id":1,"name":"John","meal_periods":[1],"note":null}
+	]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateBentoNote verifies members may set only their own note, blanks clear it,
+// and unknown users are a 404.
+func TestUpdateBentoNote(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+
+	mock.ExpectQuery(regexp.QuoteMeta(updateBentoNoteStmt)).WithArgs("大盛り", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(updateBentoNoteStmt)).WithArgs(nil, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	r := setupRouter()
+	for _, tc := range []struct {
+		path, body, want string
+		code             int
+	}{
+		{"/api/users/3/bento-note", `{"bento_note":" 大盛り "}`, `{"user_id":3,"bento_note":"大盛り"}`, http.StatusOK},
+		{"/api/users/3/bento-note", `{"bento_note":""}`, `{"user_id":3,"bento_note":null}`, http.StatusOK},
+		{"/api/users/4/bento-note", `{"bento_note":"大盛り"}`, `{"error":"you can only change your own bento note"}`, http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.body)
+		assert.JSONEq(t, tc.want, w.Body.String(), tc.body)
+	}
+
+	asCaller(t, User{ID: 1, Name: "John", IsAdmin: true, Active: true})
+	mock.ExpectQuery(regexp.QuoteMeta(updateBentoNoteStmt)).WithArgs("大盛り", 42).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/users/42/bento-note", bytes.NewBufferString(`{"bento_note":"大盛り"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBentoReminderMessage verifies the reminder names the cook and every 弁当, and
+// that nothing is sent for a day without one.
+func TestBentoReminderMessage(t *testing.T) {
+	note := "ご飯少なめ"
+	list := BentoList{Date: "2025-02-17", Cook: &CookAssignment{CookUserID: 5, CookUserName: "Mother"}, Count: 2,
+		Bentos: []Bento{{UserID: 1, Name: "John", Note: &note}, {UserID: 3, Name: "Taro"}}}
+	assert.Equal(t, "Mother さん、明日 2025-02-17 のお弁当は 2 個です: John（ご飯少なめ）、Taro", bentoReminderMessage(list))
+
+	list.Cook = nil
+	assert.Equal(t, "料理担当なし（各自）、明日 2025-02-17 のお弁当は 2 個です: John（ご飯少なめ）、Taro", bentoReminderMessage(list))
+	assert.Equal(t, "", bentoReminderMessage(BentoList{Date: "2025-02-17"}))
+}
+
+// TestBentoReminderTime verifies BENTO_REMINDER_TIME parsing and its fallbacks.
+func TestBentoReminderTime(t *testing.T) {
+	for env, want := range map[string][3]interface{}{
+		"":      {20, 0, true},
+		"19:30": {19, 30, true},
+		"off":   {0, 0, false},
+		"25:00": {20, 0, true},
+	} {
+		t.Setenv("BENTO_REMINDER_TIME", env)
+		hour, minute, ok := bentoReminderTime()
+		assert.Equal(t, want, [3]interface{}{hour, minute, ok}, env)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The feeds cover a fixed window around today; calendar apps re-fetch periodically.
+const (
+	calendarPastDays   = 30
+	calendarFutureDays = 90
+)
+
+// icsUIDDomain makes event UIDs globally unique as RFC 5545 asks.
+const icsUIDDomain = "meal-schedule"
+
+// upsertCalendarTokenStmt stores a member's feed token, replacing (and so revoking) any previous one.
+const upsertCalendarTokenStmt = `INSERT INTO calendar_tokens (user_id, token_hash) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = now()`
+
+const deleteCalendarTokenStmt = "DELETE FROM calendar_tokens WHERE user_id = $1"
+
+// calendarTokenValidQuery reports whether $2 is the feed token hash of active member $1.
+const calendarTokenValidQuery = `SELECT EXISTS (
+    SELECT 1 FROM calendar_tokens t JOIN users u ON u.id = t.user_id
+    WHERE t.user_id = $1 AND t.token_hash = $2 AND u.active
+)`
+
+// getCookCalendarQuery lists the periods in [$1, $2] member $3 cooks, resolved by
+// getCookSchedulesQuery itself (cook_schedules over cook_default_schedules).
+const getCookCalendarQuery = `WITH cooks (date, meal_period, cook_user_id, cook_user_name) AS (` + getCookSchedulesQuery + `
+)
+SELECT k.date, p.id, p.name
+FROM cooks k
+JOIN meal_periods p ON p.id = k.meal_period
+WHERE k.cook_user_id = $3
+ORDER BY k.date, p.sort_order, p.id`
+
+// getMealCalendarQuery lists member $3's effective choice for every active period in
+// [$1, $2]: the explicit meal, else the weekday default, else なし(1), as in getMealsQuery.
+const getMealCalendarQuery = `SELECT TO_CHAR(d.date, 'YYYY-MM-DD'), p.id, p.name, o.label
+FROM users u
+CROSS JOIN generate_series($1::date, $2::date, '1 day') AS d(date)
+CROSS JOIN meal_periods p
+LEFT JOIN meals m ON m.user_id = u.id AND m.date = d.date AND m.meal_period = p.id
+LEFT JOIN user_defaults ud ON ud.user_id = u.id
+    AND ud.day_of_week = EXTRACT(DOW FROM d.date)
+    AND ud.meal_period = p.id
+JOIN meal_options o ON o.id = COALESCE(m.meal_option, ud.meal_option, 1)
+WHERE u.id = $3 AND p.active = true
+ORDER BY d.date, p.sort_order, p.id`
+
+// icsEvent is one all-day VEVENT. Date is YYYY-MM-DD.
+type icsEvent struct {
+	UID     string
+	Date    string
+	Summary string
+}
+
+// createCalendarToken issues a new feed token for a member, revoking the previous one.
+// The token is only returned here; like session tokens, only its hash is stored.
+func createCalendarToken(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if caller := currentUser(c); !caller.IsAdmin && caller.ID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only manage your own calendar feeds"})
+		return
+	}
+	token, err := newSessionToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := db.Exec(upsertCalendarTokenStmt, userID, hashToken(token)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"token":     token,
+		"cook_url":  fmt.Sprintf("/api/calendar/cook/%d.ics?token=%s", userID, token),
+		"meals_url": fmt.Sprintf("/api/calendar/meals/%d.ics?token=%s", userID, token),
+	})
+}
+
+// deleteCalendarToken revokes a member's feed token; both feeds stop working.
+func deleteCalendarToken(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if caller := currentUser(c); !caller.IsAdmin && caller.ID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only manage your own calendar feeds"})
+		return
+	}
+	if _, err := db.Exec(deleteCalendarTokenStmt, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar token revoked"})
+}
+
+// getCookCalendar serves the periods a member cooks as an iCalendar feed.
+// Calendar apps cannot log in, so it is authorised by the ?token= issued by createCalendarToken.
+func getCookCalendar(c *gin.Context) {
+	userID, ok := calendarFeedUser(c)
+	if !ok {
+		return
+	}
+	from, to := calendarWindow(time.Now())
+	rows, err := db.Query(getCookCalendarQuery, from, to, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	var events []icsEvent
+	for rows.Next() {
+		var date, periodName string
+		var periodID int
+		if err := rows.Scan(&date, &periodID, &periodName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		events = append(events, icsEvent{
+			UID:     fmt.Sprintf("cook-%d-%s-%d@%s", userID, date, periodID, icsUIDDomain),
+			Date:    date,
+			Summary: "料理担当: " + periodName,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(icsCalendar("料理担当", events, time.Now())))
+}
+
+// getMealCalendar serves a member's resolved meal choices as an iCalendar feed.
+// It is authorised by ?token= like getCookCalendar.
+func getMealCalendar(c *gin.Context) {
+	userID, ok := calendarFeedUser(c)
+	if !ok {
+		return
+	}
+	from, to := calendarWindow(time.Now())
+	rows, err := db.Query(getMealCalendarQuery, from, to, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	var events []icsEvent
+	for rows.Next() {
+		var date, periodName, label string
+		var periodID int
+		if err := rows.Scan(&date, &periodID, &periodName, &label); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		events = append(events, icsEvent{
+			UID:     fmt.Sprintf("meal-%d-%s-%d@%s", userID, date, periodID, icsUIDDomain),
+			Date:    date,
+			Summary: periodName + ": " + label,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(icsCalendar("ごはん", events, time.Now())))
+}
+
+// calendarFeedUser parses the :file parameter ("<user_id>.ics") and checks ?token=.
+// Unknown members and wrong tokens both get 404, so feed URLs cannot be probed.
+func calendarFeedUser(c *gin.Context) (int, bool) {
+	file := c.Param("file")
+	userID, err := strconv.Atoi(strings.TrimSuffix(file, ".ics"))
+	if err != nil || !strings.HasSuffix(file, ".ics") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+		return 0, false
+	}
+	token := c.Query("token")
+	var valid bool
+	if token != "" {
+		if err := db.QueryRow(calendarTokenValidQuery, userID, hashToken(token)).Scan(&valid); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return 0, false
+		}
+	}
+	if !valid {
+		c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// calendarWindow returns the first and last dates the feeds cover around now.
+func calendarWindow(now time.Time) (from, to string) {
+	return now.AddDate(0, 0, -calendarPastDays).Format("2006-01-02"),
+		now.AddDate(0, 0, calendarFutureDays).Format("2006-01-02")
+}
+
+// icsCalendar renders all-day events as an RFC 5545 VCALENDAR: CRLF line endings,
+// escaped text and lines folded at 75 octets. stamp becomes every DTSTAMP.
+func icsCalendar(name string, events []icsEvent, stamp time.Time) string {
+	var b strings.Builder
+	line := func(s string) { b.WriteString(icsFold(s)) }
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//" + icsUIDDomain + "//JA")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:" + icsEscape(name))
+	dtstamp := stamp.UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		day, err := time.Parse("2006-01-02", e.Date)
+		if err != nil {
+			continue
+		}
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + dtstamp)
+		line("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + day.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + icsEscape(e.Summary))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// icsEscape escapes a TEXT value (RFC 5545 3.3.11).
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// icsFold terminates a content line with CRLF, folding it so no physical line exceeds
+// 75 octets; it never splits a UTF-8 sequence.
+func icsFold(s string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCreateCalendarToken verifies that only the hash is stored and the returned URLs
+// carry the raw token, and that members cannot issue tokens for others.
+func TestCreateCalendarToken(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectExec(regexp.QuoteMeta(upsertCalendarTokenStmt)).WithArgs(3, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/users/3/calendar-token", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp struct {
+		Token string `json:"token"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Token, 64)
+	assert.Contains(t, w.Body.String(), `"cook_url":"/api/calendar/cook/3.ics?token=`+resp.Token+`"`)
+
+	asCaller(t, User{ID: 4, Name: "Hanako", IsEater: true, Active: true})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/users/3/calendar-token", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetCookCalendar verifies the feed is refused without a valid token and renders
+// one all-day VEVENT with a stable UID per cooked period.
+func TestGetCookCalendar(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	for _, path := range []string{"/api/calendar/cook/5.ics", "/api/calendar/cook/5?token=abc", "/api/calendar/cook/x.ics?token=abc"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(calendarTokenValidQuery)).WithArgs(5, hashToken("wrong")).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/calendar/cook/5.ics?token=wrong", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mock.ExpectQuery(regexp.QuoteMeta(calendarTokenValidQuery)).WithArgs(5, hashToken("secret")).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(getCookCalendarQuery)).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 5).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "name"}).AddRow("2025-02-16", 2, "夕食"))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/calendar/cook/5.ics?token=secret", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, "UID:cook-5-2025-02-16-2@meal-schedule\r\n")
+	assert.Contains(t, body, "DTSTART;VALUE=DATE:20250216\r\nDTEND;VALUE=DATE:20250217\r\n")
+	assert.Contains(t, body, "SUMMARY:料理担当: 夕食\r\n")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetMealCalendar verifies the meals feed renders the resolved choice per period.
+func TestGetMealCalendar(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(calendarTokenValidQuery)).WithArgs(3, hashToken("secret")).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(getMealCalendarQuery)).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 3).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "name", "label"}).
+			AddRow("2025-02-16", 1, "昼食", "弁当").
+			AddRow("2025-02-16", 2, "夕食", "家"))
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/calendar/meals/3.ics?token=secret", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, strings.Count(w.Body.String(), "BEGIN:VEVENT"))
+	assert.Contains(t, w.Body.String(), "UID:meal-3-2025-02-16-1@meal-schedule\r\nDTSTAMP:")
+	assert.Contains(t, w.Body.String(), "SUMMARY:昼食: 弁当\r\n")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestICSFormatting verifies escaping and folding at 75 octets without splitting characters.
+func TestICSFormatting(t *testing.T) {
+	assert.Equal(t, `a\, b\; c\\d\ne`, icsEscape("a, b; c\\d\ne"))
+
+	folded := icsFold("SUMMARY:" + strings.Repeat("あ", 40))
+	for _, l := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(l), 75)
+	}
+	assert.Equal(t, "SUMMARY:"+strings.Repeat("あ", 40), strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", ""))
+
+	cal := icsCalendar("x", nil, time.Date(2025, 2, 16, 0, 0, 0, 0, time.UTC))
+	assert.True(t, strings.HasPrefix(cal, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(cal, "END:VCALENDAR\r\n"))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CookReassign is the "reassign" field of a change that revokes is_cook or deactivates a member.
+// The member's remaining assignments move to CookUserID, or to 各自 when it is nil.
+type CookReassign struct {
+	CookUserID *int `json:"cook_user_id"`
+}
+
+// CookSlot is one date override naming a cook.
+type CookSlot struct {
+	Date       string `json:"date"`
+	MealPeriod int    `json:"meal_period"`
+}
+
+// CookWeekdaySlot is one weekday default naming a cook.
+type CookWeekdaySlot struct {
+	DayOfWeek  int `json:"day_of_week"`
+	MealPeriod int `json:"meal_period"`
+}
+
+// CookRoleConflict lists the assignments that still name a member whose is_cook is
+// being turned off: date overrides from today on, and weekday defaults.
+type CookRoleConflict struct {
+	CookSchedules        []CookSlot        `json:"cook_schedules"`
+	CookDefaultSchedules []CookWeekdaySlot `json:"cook_default_schedules"`
+}
+
+// futureCookSchedulesQuery lists the overrides from today on that name cook $1.
+// Past overrides are history and are left alone.
+const futureCookSchedulesQuery = `SELECT TO_CHAR(date, 'YYYY-MM-DD'), meal_period
+FROM cook_schedules
+WHERE cook_user_id = $1 AND date >= CURRENT_DATE
+ORDER BY date, meal_period`
+
+// cookDefaultSchedulesOfQuery lists the weekday defaults that name cook $1.
+const cookDefaultSchedulesOfQuery = `SELECT day_of_week, meal_period
+FROM cook_default_schedules
+WHERE cook_user_id = $1
+ORDER BY day_of_week, meal_period`
+
+// reassignCookSchedulesStmt moves cook $1's overrides from today on to $2 (NULL = 各自)
+// and logs each to cook_schedule_changes, as PUT /api/cook-schedules would.
+// $3 is the actor and $4 the source.
+const reassignCookSchedulesStmt = `WITH moved AS (
+    UPDATE cook_schedules SET cook_user_id = $2::int
+    WHERE cook_user_id = $1::int AND date >= CURRENT_DATE
+    RETURNING date, meal_period
+)
+INSERT INTO cook_schedule_changes
+    (date, meal_period, old_override, old_cook_user_id, new_override, new_cook_user_id, actor_id, source)
+SELECT date, meal_period, true, $1::int, true, $2::int, $3::int, $4::text FROM moved`
+
+// reassignCookDefaultSchedulesStmt moves cook $1's weekday defaults to $2 (NULL = 各自)
+// and logs each to cook_default_schedule_changes, as PUT /api/cook-default-schedules
+// would. $3 is the actor and $4 the source.
+const reassignCookDefaultSchedulesStmt = `WITH moved AS (
+    UPDATE cook_default_schedules SET cook_user_id = $2::int
+    WHERE cook_user_id = $1::int
+    RETURNING day_of_week, meal_period
+)
+INSERT INTO cook_default_schedule_changes
+    (day_of_week, meal_period, old_cook_user_id, new_cook_user_id, actor_id, source)
+SELECT day_of_week, meal_period, $1::int, $2::int, $3::int, $4::text FROM moved`
+
+// validReassignTarget writes a 422 unless reassign is absent, 各自, or another active cook.
+func validReassignTarget(c *gin.Context, userID string, reassign *CookReassign) bool {
+	if reassign == nil || reassign.CookUserID == nil {
+		return true
+	}
+	users, err := loadUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	u, ok := users[*reassign.CookUserID]
+	if !ok || !u.Active || !u.IsCook || strconv.Itoa(u.ID) == userID {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "reassign.cook_user_id must be another active cook"})
+		return false
+	}
+	return true
+}
+
+// releaseCookAssignments runs in the transaction that turns is_cook or active off for userID.
+// Without reassign it returns the member's remaining assignments, and the caller must
+// roll back when there are any; with reassign it moves them and returns nil.
+func releaseCookAssignments(tx *sql.Tx, userID string, reassign *CookReassign, actorID int, source string) (*CookRoleConflict, error) {
+	if reassign != nil {
+		to := nullableInt(reassign.CookUserID)
+		if _, err := tx.Exec(reassignCookSchedulesStmt, userID, to, actorID, source); err != nil {
+			return nil, err
+		}
+		_, err := tx.Exec(reassignCookDefaultSchedulesStmt, userID, to, actorID, source)
+		return nil, err
+	}
+
+	conflict := &CookRoleConflict{CookSchedules: []CookSlot{}, CookDefaultSchedules: []CookWeekdaySlot{}}
+	rows, err := tx.Query(futureCookSchedulesQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var s CookSlot
+		if err := rows.Scan(&s.Date, &s.MealPeriod); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		conflict.CookSchedules = append(conflict.CookSchedules, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows, err = tx.Query(cookDefaultSchedulesOfQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s CookWeekdaySlot
+		if err := rows.Scan(&s.DayOfWeek, &s.MealPeriod); err != nil {
+			return nil, err
+		}
+		conflict.CookDefaultSchedules = append(conflict.CookDefaultSchedules, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(conflict.CookSchedules) == 0 && len(conflict.CookDefaultSchedules) == 0 {
+		return nil, nil
+	}
+	return conflict, nil
+}
+
+// cookRoleConflictResponse writes the 409 for a role change blocked by assignments.
+func cookRoleConflictResponse(c *gin.Context, conflict *CookRoleConflict) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":                  "user still has cook assignments; pass reassign to move them",
+		"cook_schedules":         conflict.CookSchedules,
+		"cook_default_schedules": conflict.CookDefaultSchedules,
+	})
+}
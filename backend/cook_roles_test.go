@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUpdateUserRolesCookConflict verifies revoking is_cook from a member who still
+// has assignments is rolled back with a 409 listing them.
+func TestUpdateUserRolesCookConflict(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateUserRolesStmt)).WithArgs(false, true, nil, "5").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(futureCookSchedulesQuery)).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period"}).AddRow("2026-10-20", 2))
+	mock.ExpectQuery(regexp.QuoteMeta(cookDefaultSchedulesOfQuery)).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"day_of_week", "meal_period"}).AddRow(1, 2).AddRow(3, 2))
+	mock.ExpectRollback()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/users/5/roles", bytes.NewBufferString(`{"is_cook":false,"is_eater":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{
+		"error": "user still has cook assignments; pass reassign to move them",
+		"cook_schedules": [{"date":"2026-10-20","meal_period":2}],
+		"cook_default_schedules": [{"day_of_week":1,"meal_period":2},{"day_of_week":3,"meal_period":2}]
+	}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateUserRolesCookReassign verifies reassign moves the assignments in the same
+// transaction, and that the target must be another active cook.
+func TestUpdateUserRolesCookReassign(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/users/5/roles",
+		bytes.NewBufferString(`{"is_cook":false,"is_eater":true,"reassign":{"cook_user_id":2}}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateUserRolesStmt)).WithArgs(false, true, nil, "5").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(reassignCookSchedulesStmt)).WithArgs("5", 1, 1, "PUT /api/users/:user_id/roles").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(reassignCookDefaultSchedulesStmt)).WithArgs("5", 1, 1, "PUT /api/users/:user_id/roles").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/users/5/roles",
+		bytes.NewBufferString(`{"is_cook":false,"is_eater":true,"reassign":{"cook_user_id":1}}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeactivateCookConflict verifies deactivating a member, by PATCH or DELETE, is
+// held to the same rule as revoking is_cook: a 409 while assignments remain, and
+// reassign moves them in the same transaction.
+func TestDeactivateCookConflict(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(updateUserStmt)).WithArgs(nil, nil, nil, nil, false, "5").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}).
+			AddRow(5, "Mother", true, true, 5, false, false))
+	mock.ExpectQuery(regexp.QuoteMeta(futureCookSchedulesQuery)).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period"}))
+	mock.ExpectQuery(regexp.QuoteMeta(cookDefaultSchedulesOfQuery)).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"day_of_week", "meal_period"}).AddRow(1, 2))
+	mock.ExpectRollback()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/api/users/5", bytes.NewBufferString(`{"active":false}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"cook_default_schedules":[{"day_of_week":1,"meal_period":2}]`)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(deactivateUserStmt)).WithArgs("5").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(futureCookSchedulesQuery)).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period"}).AddRow("2026-10-20", 2))
+	mock.ExpectQuery(regexp.QuoteMeta(cookDefaultSchedulesOfQuery)).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"day_of_week", "meal_period"}))
+	mock.ExpectRollback()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/users/5", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"cook_schedules":[{"date":"2026-10-20","meal_period":2}]`)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(deactivateUserStmt)).WithArgs("5").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(reassignCookSchedulesStmt)).WithArgs("5", 1, 1, "DELETE /api/users/:user_id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(reassignCookDefaultSchedulesStmt)).WithArgs("5", 1, 1, "DELETE /api/users/:user_id").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(futureHostedGuestsQuery)).WithArgs("5").WillReturnRows(mealGuestRows())
+	mock.ExpectCommit()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/users/5", bytes.NewBufferString(`{"reassign":{"cook_user_id":1}}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"User deactivated"}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRotationDays bounds one generation run.
+const maxRotationDays = 366
+
+// Rotation modes: a preview only returns the proposal, a commit also writes it.
+const (
+	rotationPreview = "preview"
+	rotationCommit  = "commit"
+)
+
+// RotationCook is one participant of POST /api/cook-schedules/generate.
+// Weekdays (0 = Sunday) limits the days they can cook; empty means every day.
+type RotationCook struct {
+	UserID        int      `json:"user_id"`
+	Weekdays      []int    `json:"weekdays"`
+	BlackoutDates []string `json:"blackout_dates"`
+}
+
+// RotationRequest is the request body for POST /api/cook-schedules/generate.
+// MealPeriods defaults to every active period; MaxPerWeek 0 means no limit.
+type RotationRequest struct {
+	From             string         `json:"from"`
+	To               string         `json:"to"`
+	MealPeriods      []int          `json:"meal_periods"`
+	Cooks            []RotationCook `json:"cooks"`
+	MaxPerWeek       int            `json:"max_per_week"`
+	ReplaceOverrides bool           `json:"replace_overrides"`
+}
+
+// RotationSlot is one date and period of a rotation. CookUserID is nil for 各自
+// (kept overrides) and for slots nobody could take.
+type RotationSlot struct {
+	Date         string  `json:"date"`
+	MealPeriod   int     `json:"meal_period"`
+	CookUserID   *int    `json:"cook_user_id"`
+	CookUserName *string `json:"cook_user_name"`
+}
+
+// RotationResult is the response of POST /api/cook-schedules/generate. Assignments are
+// the overrides the run writes; Kept are existing overrides it left alone; Unfilled
+// are slots no participant could take, written as 各自. Counts is each participant's
+// total, kept overrides included.
+type RotationResult struct {
+	Mode        string         `json:"mode"`
+	Assignments []RotationSlot `json:"assignments"`
+	Kept        []RotationSlot `json:"kept"`
+	Unfilled    []RotationSlot `json:"unfilled"`
+	Counts      map[int]int    `json:"counts"`
+}
+
+// getCookOverridesQuery lists the date overrides in [$1, $2]; a NULL cook is 各自.
+const getCookOverridesQuery = `SELECT TO_CHAR(date, 'YYYY-MM-DD'), meal_period, cook_user_id
+FROM cook_schedules
+WHERE date BETWEEN $1 AND $2`
+
+// generateCookSchedules proposes cook_schedules rows that spread the given periods of
+// a date range evenly over the participating cooks, honouring their weekdays,
+// blackout dates and max_per_week. Existing overrides are kept (and counted) unless
+// replace_overrides is set. mode=preview (the default) only returns the proposal;
+// mode=commit writes it in one transaction, logged like PUT /api/cook-schedules.
+// Unfilled slots are committed as explicit 各自 overrides, so they neither keep a
+// replaced override nor fall back to a weekday default cook who may be blacked out.
+func generateCookSchedules(c *gin.Context) {
+	mode := c.DefaultQuery("mode", rotationPreview)
+	if mode != rotationPreview && mode != rotationCommit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode. Use preview or commit."})
+		return
+	}
+	var req RotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, errFrom := time.Parse("2006-01-02", req.From)
+	to, errTo := time.Parse("2006-01-02", req.To)
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	if to.Before(from) || to.Sub(from) >= maxRotationDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be on or after from, within 366 days"})
+		return
+	}
+	if len(req.Cooks) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cooks must not be empty"})
+		return
+	}
+	if req.MaxPerWeek < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_per_week must not be negative"})
+		return
+	}
+
+	// Participants are reported by their position in cooks.
+	v, err := newValidator(validatePeriods | validateUsers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	seen := map[int]bool{}
+	for i, rc := range req.Cooks {
+		id := rc.UserID
+		v.cook(i, "user_id", &id)
+		if seen[id] {
+			v.fail(i, "user_id", "user %d is listed twice", id)
+		}
+		seen[id] = true
+		for _, d := range rc.Weekdays {
+			v.dayOfWeek(i, "weekdays", d)
+		}
+		for _, d := range rc.BlackoutDates {
+			v.date(i, "blackout_dates", d)
+		}
+	}
+	for i, p := range req.MealPeriods {
+		v.period(i, "meal_periods", p, false)
+	}
+	if v.respond(c) {
+		return
+	}
+
+	periods, err := queryMealPeriods(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.MealPeriods) > 0 {
+		selected := map[int]bool{}
+		for _, p := range req.MealPeriods {
+			selected[p] = true
+		}
+		var kept []MealPeriod
+		for _, p := range periods {
+			if selected[p.ID] {
+				kept = append(kept, p)
+			}
+		}
+		periods = kept
+	}
+
+	overrides := map[CookSlot]*int{}
+	rows, err := db.Query(getCookOverridesQuery, req.From, req.To)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s CookSlot
+		var cook sql.NullInt64
+		if err := rows.Scan(&s.Date, &s.MealPeriod, &cook); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		overrides[s] = nullInt(cook)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ReplaceOverrides {
+		overrides = nil
+	}
+
+	result := planRotation(req, from, to, periods, overrides, v.users)
+	result.Mode = mode
+	if mode == rotationPreview {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	caller, source := currentUser(c), changeSource(c)
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	stmt, err := tx.Prepare(bulkUpdateCookSchedulesStmt)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer stmt.Close()
+	changed := 0
+	for _, a := range append(append([]RotationSlot{}, result.Assignments...), result.Unfilled...) {
+		res, err := stmt.Exec(a.Date, a.MealPeriod, nullableInt(a.CookUserID), caller.ID, source)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			changed++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if changed > 0 {
+		go dispatch.publish(Event{Kind: eventCookScheduleChange, Messages: []string{fmt.Sprintf(
+			"%s さんが %s〜%s の料理担当を自動で割り当てました（%d件変更）", caller.Name, req.From, req.To, changed)}})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// planRotation fills every period of every day in [from, to] that has no override in
+// overrides (nil = none kept). Each slot goes to the eligible participant with the
+// fewest assignments so far; ties prefer someone not already cooking that day, then
+// the fewest of that period, then whoever cooked longest ago, then the lower id.
+func planRotation(req RotationRequest, from, to time.Time, periods []MealPeriod, overrides map[CookSlot]*int, users map[int]User) RotationResult {
+	type cookState struct {
+		RotationCook
+		weekdays  map[int]bool
+		blackout  map[string]bool
+		total     int
+		perPeriod map[int]int
+		perWeek   map[[2]int]int
+		days      map[string]bool
+		last      string
+	}
+	cooks := make([]*cookState, len(req.Cooks))
+	byID := map[int]*cookState{}
+	for i, rc := range req.Cooks {
+		s := &cookState{RotationCook: rc, weekdays: map[int]bool{}, blackout: map[string]bool{},
+			perPeriod: map[int]int{}, perWeek: map[[2]int]int{}, days: map[string]bool{}}
+		for _, d := range rc.Weekdays {
+			s.weekdays[d] = true
+		}
+		for _, d := range rc.BlackoutDates {
+			s.blackout[d] = true
+		}
+		cooks[i], byID[rc.UserID] = s, s
+	}
+	weekOf := func(d time.Time) [2]int {
+		y, w := d.ISOWeek()
+		return [2]int{y, w}
+	}
+	assign := func(s *cookState, date string, week [2]int, periodID int) {
+		s.total++
+		s.perPeriod[periodID]++
+		s.perWeek[week]++
+		s.days[date] = true
+		s.last = date
+	}
+	slot := func(date string, periodID int, cookID *int) RotationSlot {
+		rs := RotationSlot{Date: date, MealPeriod: periodID, CookUserID: cookID}
+		if cookID != nil {
+			name := users[*cookID].Name
+			rs.CookUserName = &name
+		}
+		return rs
+	}
+
+	result := RotationResult{Assignments: []RotationSlot{}, Kept: []RotationSlot{}, Unfilled: []RotationSlot{}, Counts: map[int]int{}}
+	// Kept overrides count towards their cook's load before anything is planned.
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		for _, p := range periods {
+			if cookID, ok := overrides[CookSlot{date, p.ID}]; ok && cookID != nil {
+				if s := byID[*cookID]; s != nil {
+					assign(s, date, weekOf(d), p.ID)
+				}
+			}
+		}
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		date, week := d.Format("2006-01-02"), weekOf(d)
+		for _, p := range periods {
+			if cookID, ok := overrides[CookSlot{date, p.ID}]; ok {
+				result.Kept = append(result.Kept, slot(date, p.ID, cookID))
+				continue
+			}
+			var eligible []*cookState
+			for _, s := range cooks {
+				if (len(s.weekdays) > 0 && !s.weekdays[int(d.Weekday())]) || s.blackout[date] ||
+					(req.MaxPerWeek > 0 && s.perWeek[week] >= req.MaxPerWeek) {
+					continue
+				}
+				eligible = append(eligible, s)
+			}
+			if len(eligible) == 0 {
+				result.Unfilled = append(result.Unfilled, slot(date, p.ID, nil))
+				continue
+			}
+			sort.SliceStable(eligible, func(i, j int) bool {
+				a, b := eligible[i], eligible[j]
+				switch {
+				case a.total != b.total:
+					return a.total < b.total
+				case a.days[date] != b.days[date]:
+					return !a.days[date]
+				case a.perPeriod[p.ID] != b.perPeriod[p.ID]:
+					return a.perPeriod[p.ID] < b.perPeriod[p.ID]
+				case a.last != b.last:
+					return a.last < b.last
+				}
+				return a.UserID < b.UserID
+			})
+			s := eligible[0]
+			assign(s, date, week, p.ID)
+			id := s.UserID
+			result.Assignments = append(result.Assignments, slot(date, p.ID, &id))
+		}
+	}
+	for _, s := range cooks {
+		result.Counts[s.UserID] = s.total
+	}
+	return result
+}
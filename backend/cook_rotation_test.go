@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPlanRotation verifies load balancing around a kept override, weekdays,
+// blackout dates and max_per_week.
+func TestPlanRotation(t *testing.T) {
+	from, _ := time.Parse("2006-01-02", "2026-11-02") // Monday
+	to, _ := time.Parse("2006-01-02", "2026-11-08")
+	dinner := []MealPeriod{{ID: 2, Name: "夕食", Active: true}}
+	mother := 5
+	overrides := map[CookSlot]*int{{Date: "2026-11-03", MealPeriod: 2}: &mother}
+	users := map[int]User{1: {ID: 1, Name: "John"}, 5: {ID: 5, Name: "Mother"}}
+	req := RotationRequest{Cooks: []RotationCook{
+		{UserID: 1},
+		{UserID: 5, Weekdays: []int{1, 2, 3, 4, 5}, BlackoutDates: []string{"2026-11-04"}},
+	}}
+
+	cooksOf := func(slots []RotationSlot) map[string]int {
+		m := map[string]int{}
+		for _, s := range slots {
+			m[s.Date] = *s.CookUserID
+		}
+		return m
+	}
+
+	res := planRotation(req, from, to, dinner, overrides, users)
+	assert.Equal(t, map[string]int{
+		"2026-11-02": 1, "2026-11-04": 1, "2026-11-05": 5, "2026-11-06": 1, "2026-11-07": 1, "2026-11-08": 1,
+	}, cooksOf(res.Assignments))
+	assert.Equal(t, "2026-11-03", res.Kept[0].Date)
+	assert.Equal(t, map[int]int{1: 5, 5: 2}, res.Counts)
+	assert.Empty(t, res.Unfilled)
+
+	req.MaxPerWeek = 3
+	res = planRotation(req, from, to, dinner, overrides, users)
+	assert.Equal(t, map[string]int{"2026-11-02": 1, "2026-11-04": 1, "2026-11-05": 5, "2026-11-06": 1}, cooksOf(res.Assignments))
+	if assert.Len(t, res.Unfilled, 2) {
+		assert.Equal(t, "2026-11-07", res.Unfilled[0].Date)
+		assert.Nil(t, res.Unfilled[0].CookUserID)
+	}
+}
+
+// TestGenerateCookSchedules verifies a preview writes nothing, a commit writes every
+// assignment through bulkUpdateCookSchedulesStmt, and non-cooks are refused.
+func TestGenerateCookSchedules(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	body := `{"from":"2026-11-02","to":"2026-11-03","meal_periods":[2],"cooks":[{"user_id":1},{"user_id":5}]}`
+	expectPlan := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+		mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+		mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(false).WillReturnRows(mealPeriodRows())
+		mock.ExpectQuery(regexp.QuoteMeta(getCookOverridesQuery)).WithArgs("2026-11-02", "2026-11-03").
+			WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id"}))
+	}
+	r := setupRouter()
+
+	expectPlan()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/cook-schedules/generate", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"mode":"preview","assignments":[
+		{"date":"2026-11-02","meal_period":2,"cook_user_id":1,"cook_user_name":"John"},
+		{"date":"2026-11-03","meal_period":2,"cook_user_id":5,"cook_user_name":"Mother"}
+	],"kept":[],"unfilled":[],"counts":{"1":1,"5":1}}`, w.Body.String())
+
+	expectPlan()
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(bulkUpdateCookSchedulesStmt))
+	src := "POST /api/cook-schedules/generate"
+	prep.ExpectExec().WithArgs("2026-11-02", 2, 1, 1, src).WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WithArgs("2026-11-03", 2, 5, 1, src).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/cook-schedules/generate?mode=commit", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/cook-schedules/generate",
+		bytes.NewBufferString(`{"from":"2026-11-02","to":"2026-11-03","cooks":[{"user_id":2,"weekdays":[7]}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[
+		{"index":0,"field":"user_id","message":"user 2 is not a cook"},
+		{"index":0,"field":"weekdays","message":"must be 0 (Sunday) to 6 (Saturday)"}
+	]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGenerateCookSchedulesUnfilled verifies a commit writes unfilled slots as 各自,
+// replacing the existing override instead of leaving it or the weekday default in place.
+func TestGenerateCookSchedulesUnfilled(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(false).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getCookOverridesQuery)).WithArgs("2026-11-02", "2026-11-03").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id"}).AddRow("2026-11-03", 2, 5))
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(bulkUpdateCookSchedulesStmt))
+	src := "POST /api/cook-schedules/generate"
+	prep.ExpectExec().WithArgs("2026-11-02", 2, 5, 1, src).WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WithArgs("2026-11-03", 2, nil, 1, src).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/cook-schedules/generate?mode=commit", bytes.NewBufferString(
+		`{"from":"2026-11-02","to":"2026-11-03","meal_periods":[2],"replace_overrides":true,
+		  "cooks":[{"user_id":5,"blackout_dates":["2026-11-03"]}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"mode":"commit","assignments":[
+		{"date":"2026-11-02","meal_period":2,"cook_user_id":5,"cook_user_name":"Mother"}
+	],"kept":[],"unfilled":[
+		{"date":"2026-11-03","meal_period":2,"cook_user_id":null,"cook_user_name":null}
+	],"counts":{"5":1}}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"time"
+)
+
+// Cutoff modes decide what happens to a change made after a period's cutoff_time.
+const (
+	cutoffReject      = "reject"      // refuse the whole request
+	cutoffAcknowledge = "acknowledge" // accept, but the cook has to acknowledge it
+	cutoffNotify      = "notify"      // accept and notify
+	// cutoffOverride is not a period setting: it marks a locked change the cook or
+	// an admin pushed through with override=true.
+	cutoffOverride = "override"
+)
+
+// validCutoffMode reports whether s can be stored in meal_periods.cutoff_mode.
+func validCutoffMode(s string) bool {
+	return s == cutoffReject || s == cutoffAcknowledge || s == cutoffNotify
+}
+
+// LockedRow reports one period of a bulk meal update that was past its cutoff.
+// Index is the element's position in the request body; Mode is the cutoff mode
+// that was applied, or "override".
+type LockedRow struct {
+	Index      int       `json:"index"`
+	UserID     int       `json:"user_id"`
+	Date       string    `json:"date"`
+	MealPeriod int       `json:"meal_period"`
+	Cutoff     time.Time `json:"cutoff"`
+	Mode       string    `json:"mode"`
+}
+
+// cutoffAt returns when changes to period p on date lock, in server local time.
+// ok is false when the period has no cutoff.
+func cutoffAt(date string, p MealPeriod) (t time.Time, ok bool) {
+	if p.CutoffTime == nil {
+		return t, false
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", date+" "+*p.CutoffTime, time.Local)
+	return t, err == nil
+}
+
+// lockedMealUpdates finds the changed periods of a bulk meal update that are past
+// their cutoff at now. With override, locked rows are let through when the caller is
+// an admin or the cook for that date; any other locked row is returned in denied.
+// Dates must already be validated.
+func lockedMealUpdates(caller User, updates []MealUpdate, periods map[int]MealPeriod, override bool, now time.Time) (locked []LockedRow, denied []ForbiddenRow, err error) {
+	var from, to string
+	for i, m := range updates {
+		for _, periodID := range sortedPeriodIDs(m.Options) {
+			if m.Options[periodID] == 0 {
+				continue
+			}
+			p := periods[periodID]
+			at, ok := cutoffAt(m.Date, p)
+			if !ok || now.Before(at) {
+				continue
+			}
+			locked = append(locked, LockedRow{Index: i, UserID: m.UserID, Date: m.Date, MealPeriod: periodID, Cutoff: at, Mode: p.CutoffMode})
+			if from == "" || m.Date < from {
+				from = m.Date
+			}
+			if to == "" || m.Date > to {
+				to = m.Date
+			}
+		}
+	}
+	if !override || len(locked) == 0 {
+		return locked, nil, nil
+	}
+
+	var cooks map[string]DailyCookSchedule
+	if !caller.IsAdmin {
+		if cooks, err = loadCookAssignments(from, to); err != nil {
+			return nil, nil, err
+		}
+	}
+	for i, l := range locked {
+		if !caller.IsAdmin && !cooks[l.Date].isCookOn(caller.ID) {
+			if len(denied) > 0 && denied[len(denied)-1].Index == l.Index {
+				continue // one explanation per request element
+			}
+			denied = append(denied, ForbiddenRow{
+				Index:  l.Index,
+				UserID: l.UserID,
+				Date:   l.Date,
+				Reason: "only an admin or the cook for " + l.Date + " can override the cutoff",
+			})
+			continue
+		}
+		locked[i].Mode = cutoffOverride
+	}
+	return locked, denied, nil
+}
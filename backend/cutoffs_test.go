@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// rejectPeriodRows is mealPeriodRows with dinner locking in reject mode.
+func rejectPeriodRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "sort_order", "cutoff_time", "cutoff_mode", "packs_bento", "active"}).
+		AddRow(1, "昼食", 2, nil, "notify", true, true).
+		AddRow(2, "夕食", 3, "15:00", "reject", false, true)
+}
+
+// expectBulkUpdatePrelude mocks the lookups bulkUpdateMeals makes before writing.
+func expectBulkUpdatePrelude(mock sqlmock.Sqlmock, periods *sqlmock.Rows) {
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(periods)
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(true).WillReturnRows(mealOptionRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+}
+
+// TestCutoffAt verifies that cutoffs are evaluated on the meal's date in local time.
+func TestCutoffAt(t *testing.T) {
+	cutoff := "15:00"
+	at, ok := cutoffAt("2025-02-16", MealPeriod{CutoffTime: &cutoff})
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2025, 2, 16, 15, 0, 0, 0, time.Local), at)
+
+	_, ok = cutoffAt("2025-02-16", MealPeriod{})
+	assert.False(t, ok)
+}
+
+// TestBulkUpdateMealsCutoffReject verifies that a locked row in reject mode fails the
+// whole request with the locked rows listed, while future rows alone would pass.
+func TestBulkUpdateMealsCutoffReject(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	expectBulkUpdatePrelude(mock, rejectPeriodRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/meals/bulk-update", bytes.NewBufferString(`[
+		{"user_id":1,"date":"2999-01-01","options":{"2":3}},
+		{"user_id":1,"date":"2024-02-04","options":{"1":3,"2":1}}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"index":1,"user_id":1,"date":"2024-02-04","meal_period":2`)
+	assert.Contains(t, w.Body.String(), `"mode":"reject"`)
+	assert.NotContains(t, w.Body.String(), `"meal_period":1`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBulkUpdateMealsCutoffOverride verifies that override=true is refused for a
+// member who is not the cook, and lets an admin write the row marked as an override.
+func TestBulkUpdateMealsCutoffOverride(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	payload := `[{"user_id":1,"date":"2024-02-04","options":{"2":1}}]`
+	r := setupRouter()
+
+	asCaller(t, User{ID: 1, Name: "John", IsEater: true, Active: true})
+	expectBulkUpdatePrelude(mock, rejectPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getCookSchedulesQuery)).WithArgs("2024-02-04", "2024-02-04").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name"}).
+			AddRow("2024-02-04", 2, 5, "Mother"))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/meals/bulk-update?override=true", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "override requires the cook or an admin")
+
+	testCallerUser.IsAdmin = true
+	expectBulkUpdatePrelude(mock, rejectPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getCookSchedulesQuery)).WithArgs("2024-02-04", "2024-02-04").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name"}).
+			AddRow("2024-02-04", 2, 5, "Mother"))
+	mock.ExpectBegin()
+	mock.ExpectPrepare(regexp.QuoteMeta(bulkUpdateMealsStmt)).ExpectQuery().
+		WithArgs(1, "2024-02-04", 2, 1, 1, "PUT /api/meals/bulk-update", cutoffOverride, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"changed"}).AddRow(true))
+	mock.ExpectCommit()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/meals/bulk-update?override=true", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"mode":"override"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCutoffConfigAdminOnly verifies that a member who hits a reject-mode cutoff
+// cannot loosen it: changing or adding cutoffs is for admins only.
+func TestCutoffConfigAdminOnly(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+
+	r := setupRouter()
+	for _, tc := range []struct{ method, path, body string }{
+		{"PATCH", "/api/meal-periods/2", `{"cutoff_mode":"notify"}`},
+		{"PATCH", "/api/meal-periods/2", `{"cutoff_time":""}`},
+		{"POST", "/api/meal-periods", `{"name":"夜食","cutoff_time":"21:00","cutoff_mode":"notify"}`},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, tc.body)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
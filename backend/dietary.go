@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// DietaryProfile is a member's dietary needs, read and written by
+// GET/PUT /api/users/:user_id/dietary. VegetarianDays are weekdays (0 = Sunday).
+// A member without a profile gets empty lists and nil notes.
+type DietaryProfile struct {
+	UserID         int      `json:"user_id"`
+	Allergies      []string `json:"allergies"`
+	Dislikes       []string `json:"dislikes"`
+	VegetarianDays []int    `json:"vegetarian_days"`
+	Notes          *string  `json:"notes"`
+}
+
+// DietaryEater is one person eating at home in a period who has dietary needs.
+// UserID is nil for guests, whose only need is their free-text dietary_notes.
+type DietaryEater struct {
+	UserID     *int     `json:"user_id"`
+	Name       string   `json:"name"`
+	Allergies  []string `json:"allergies"`
+	Dislikes   []string `json:"dislikes"`
+	Vegetarian bool     `json:"vegetarian"`
+	Notes      *string  `json:"notes"`
+}
+
+// PeriodDietary is the "what to avoid" part of a PeriodSummary: the allergies of
+// everyone eating at home (deduplicated and sorted), whether anyone eats vegetarian
+// that day, and the eaters behind them.
+type PeriodDietary struct {
+	Allergies  []string       `json:"allergies"`
+	Vegetarian bool           `json:"vegetarian"`
+	Eaters     []DietaryEater `json:"eaters"`
+}
+
+// getUserDietaryQuery returns member $1's profile, or empty values when they have none.
+const getUserDietaryQuery = `SELECT u.id, COALESCE(d.allergies, '{}'), COALESCE(d.dislikes, '{}'),
+    COALESCE(d.vegetarian_days, '{}'), d.notes
+FROM users u
+LEFT JOIN user_dietary d ON d.user_id = u.id
+WHERE u.id = $1`
+
+const updateUserDietaryStmt = `INSERT INTO user_dietary (user_id, allergies, dislikes, vegetarian_days, notes)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE SET
+    allergies = EXCLUDED.allergies, dislikes = EXCLUDED.dislikes,
+    vegetarian_days = EXCLUDED.vegetarian_days, notes = EXCLUDED.notes, updated_at = now()`
+
+// getUserDietary returns a member's dietary profile. Everyone may read it, since the
+// cook of the day needs it.
+func getUserDietary(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	var p DietaryProfile
+	var days pq.Int64Array
+	var notes sql.NullString
+	err = db.QueryRow(getUserDietaryQuery, userID).Scan(&p.UserID,
+		pq.Array(&p.Allergies), pq.Array(&p.Dislikes), &days, &notes)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	p.VegetarianDays = make([]int, len(days))
+	for i, d := range days {
+		p.VegetarianDays[i] = int(d)
+	}
+	p.Notes = nullString(notes)
+	if p.Allergies == nil {
+		p.Allergies = []string{}
+	}
+	if p.Dislikes == nil {
+		p.Dislikes = []string{}
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// updateUserDietary replaces a member's dietary profile. Members may only change their
+// own; admins may change anyone's. Items are trimmed and deduplicated, blanks dropped.
+func updateUserDietary(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if caller := currentUser(c); !caller.IsAdmin && userID != caller.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own dietary profile"})
+		return
+	}
+	var req DietaryProfile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	v, err := newValidator(validateUsers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := v.users[userID]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	days := map[int]bool{}
+	for _, d := range req.VegetarianDays {
+		v.dayOfWeek(0, "vegetarian_days", d)
+		days[d] = true
+	}
+	if v.respond(c) {
+		return
+	}
+	p := DietaryProfile{
+		UserID:         userID,
+		Allergies:      textItems(req.Allergies),
+		Dislikes:       textItems(req.Dislikes),
+		VegetarianDays: []int{},
+	}
+	for d := 0; d <= 6; d++ {
+		if days[d] {
+			p.VegetarianDays = append(p.VegetarianDays, d)
+		}
+	}
+	var notes interface{}
+	if req.Notes != nil {
+		if t := strings.TrimSpace(*req.Notes); t != "" {
+			p.Notes, notes = &t, t
+		}
+	}
+	vegetarianDays := make(pq.Int64Array, len(p.VegetarianDays))
+	for i, d := range p.VegetarianDays {
+		vegetarianDays[i] = int64(d)
+	}
+	if _, err := db.Exec(updateUserDietaryStmt, userID, pq.Array(p.Allergies), pq.Array(p.Dislikes),
+		vegetarianDays, notes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// textItems trims items, drops blanks and duplicates, and keeps the given order.
+func textItems(items []string) []string {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, s := range items {
+		s = strings.TrimSpace(s)
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		result = append(result, s)
+	}
+	return result
+}
+
+// newPeriodDietary builds the dietary section of one period from its eaters, in
+// the order getSummaryQuery lists them.
+func newPeriodDietary(eaters []DietaryEater) *PeriodDietary {
+	pd := emptyPeriodDietary()
+	for _, e := range eaters {
+		if e.Allergies == nil {
+			e.Allergies = []string{}
+		}
+		if e.Dislikes == nil {
+			e.Dislikes = []string{}
+		}
+		pd.Eaters = append(pd.Eaters, e)
+		pd.Vegetarian = pd.Vegetarian || e.Vegetarian
+		for _, a := range e.Allergies {
+			if i := sort.SearchStrings(pd.Allergies, a); i == len(pd.Allergies) || pd.Allergies[i] != a {
+				pd.Allergies = append(pd.Allergies[:i], append([]string{a}, pd.Allergies[i:]...)...)
+			}
+		}
+	}
+	return pd
+}
+
+func emptyPeriodDietary() *PeriodDietary {
+	return &PeriodDietary{Allergies: []string{}, Eaters: []DietaryEater{}}
+}
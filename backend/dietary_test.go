@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetUserDietary verifies a stored profile is returned and a member without one
+// gets empty values, while unknown members are a 404.
+func TestGetUserDietary(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	columns := []string{"id", "allergies", "dislikes", "vegetarian_days", "notes"}
+	mock.ExpectQuery(regexp.QuoteMeta(getUserDietaryQuery)).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(4, "{ピーナッツ}", "{ピーマン}", "{1,5}", "エピペンは冷蔵庫の上"))
+	mock.ExpectQuery(regexp.QuoteMeta(getUserDietaryQuery)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "{}", "{}", "{}", nil))
+	mock.ExpectQuery(regexp.QuoteMeta(getUserDietaryQuery)).WithArgs(42).WillReturnRows(sqlmock.NewRows(columns))
+
+	r := setupRouter()
+	for _, tc := range []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/users/4/dietary", http.StatusOK,
+			`{"user_id":4,"allergies":["ピーナッツ"],"dislikes":["ピーマン"],"vegetarian_days":[1,5],"notes":"エピペンは冷蔵庫の上"}`},
+		{"/api/users/2/dietary", http.StatusOK,
+			`{"user_id":2,"allergies":[],"dislikes":[],"vegetarian_days":[],"notes":null}`},
+		{"/api/users/42/dietary", http.StatusNotFound, `{"error":"user not found"}`},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.path)
+		assert.JSONEq(t, tc.body, w.Body.String(), tc.path)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateUserDietary verifies items are trimmed and deduplicated, weekdays sorted,
+// and blank notes stored as NULL.
+func TestUpdateUserDietary(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 4, Name: "Hanako", IsEater: true, Active: true})
+
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	mock.ExpectExec(regexp.QuoteMeta(updateUserDietaryStmt)).
+		WithArgs(4, pq.Array([]string{"ピーナッツ"}), pq.Array([]string{}), pq.Int64Array{1, 5}, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/users/4/dietary",
+		bytes.NewBufferString(`{"allergies":[" ピーナッツ","ピーナッツ",""],"vegetarian_days":[5,1,5],"notes":"  "}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":4,"allergies":["ピーナッツ"],"dislikes":[],"vegetarian_days":[1,5],"notes":null}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateUserDietaryRejected verifies members cannot edit others' profiles and
+// weekdays outside 0-6 are a 422.
+func TestUpdateUserDietaryRejected(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/users/4/dietary", bytes.NewBufferString(`{"vegetarian_days":[7]}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[
+		{"index":0,"field":"vegetarian_days","message":"must be 0 (Sunday) to 6 (Saturday)"}
+	]}`, w.Body.String())
+
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/users/4/dietary", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Export sources say where a resolved choice came from.
+const (
+	sourceMeals    = "meals"         // an explicit meals row
+	sourceDefaults = "user_defaults" // the weekday default (なし when there is none)
+)
+
+// ExportedMeal is one period of an ExportedDay.
+type ExportedMeal struct {
+	MealOption int    `json:"meal_option"`
+	Label      string `json:"label"`
+	Source     string `json:"source"`
+}
+
+// ExportedDay is one member's resolved meals for one date, as exported by
+// GET /api/export/meals. Periods is keyed by meal period id; HomeMeals counts the
+// periods whose option has eats_at_home set.
+type ExportedDay struct {
+	Date      string               `json:"date"`
+	UserID    int                  `json:"user_id"`
+	UserName  string               `json:"user_name"`
+	Periods   map[int]ExportedMeal `json:"periods"`
+	HomeMeals int                  `json:"home_meals"`
+}
+
+// exportMeals streams the resolved meal matrix of getMealsQuery, one record per member
+// per date, as CSV (default) or a JSON array. Rows are written as they are read, so
+// long ranges are not held in memory.
+func exportMeals(c *gin.Context) {
+	startDate, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter. Must be a positive integer."})
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Use csv or json."})
+		return
+	}
+	from := startDate.Format("2006-01-02")
+	to := startDate.AddDate(0, 0, days-1).Format("2006-01-02")
+
+	periods, err := queryMealPeriods(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	options, err := loadMealOptions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rows, err := db.Query(getMealsQuery, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	var write func(ExportedDay) error
+	var finish func() error
+	filename := "meals_" + from + "_" + to + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		// A BOM lets spreadsheet apps detect UTF-8 for the Japanese labels.
+		c.Writer.WriteString("\ufeff")
+		w := csv.NewWriter(c.Writer)
+		header := []string{"date", "user_id", "user_name"}
+		for _, p := range periods {
+			header = append(header, p.Name, p.Name+"_source")
+		}
+		w.Write(append(header, "home_meals"))
+		write = func(d ExportedDay) error {
+			record := []string{d.Date, strconv.Itoa(d.UserID), d.UserName}
+			for _, p := range periods {
+				m := d.Periods[p.ID]
+				record = append(record, m.Label, m.Source)
+			}
+			w.Write(append(record, strconv.Itoa(d.HomeMeals)))
+			w.Flush()
+			return w.Error()
+		}
+		finish = func() error { w.Flush(); return w.Error() }
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("[")
+		first := true
+		write = func(d ExportedDay) error {
+			b, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			if !first {
+				c.Writer.WriteString(",")
+			}
+			first = false
+			_, err = c.Writer.Write(b)
+			return err
+		}
+		finish = func() error { _, err := c.Writer.WriteString("]"); return err }
+	}
+
+	// Rows are ordered by date and user, so a member's periods for a day are contiguous.
+	// The status is already sent; on failure a truncated body is the only signal left.
+	var cur *ExportedDay
+	for rows.Next() {
+		var userID, periodID, option, defaultOption int
+		var userName, dateStr string
+		if err := rows.Scan(&userID, &userName, &dateStr, &periodID, &option, &defaultOption); err != nil {
+			log.Printf("export aborted: %v", err)
+			return
+		}
+		if cur == nil || cur.Date != dateStr || cur.UserID != userID {
+			if cur != nil {
+				if err := write(*cur); err != nil {
+					log.Printf("export aborted: %v", err)
+					return
+				}
+			}
+			cur = &ExportedDay{Date: dateStr, UserID: userID, UserName: userName, Periods: map[int]ExportedMeal{}}
+		}
+		m := ExportedMeal{MealOption: option, Source: sourceMeals}
+		if option == 0 {
+			m = ExportedMeal{MealOption: defaultOption, Source: sourceDefaults}
+		}
+		m.Label = options[m.MealOption].Label
+		if options[m.MealOption].EatsAtHome {
+			cur.HomeMeals++
+		}
+		cur.Periods[periodID] = m
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("export aborted: %v", err)
+		return
+	}
+	if cur != nil {
+		if err := write(*cur); err != nil {
+			log.Printf("export aborted: %v", err)
+			return
+		}
+	}
+	if err := finish(); err != nil {
+		log.Printf("export aborted: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectExportQueries mocks the lookups and the getMealsQuery rows exportMeals streams:
+// John has an explicit lunch and a default dinner, Paul falls back to なし for lunch.
+func expectExportQueries(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(false).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(true).WillReturnRows(mealOptionRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealsQuery)).WithArgs("2024-02-04", "2024-02-04").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_name", "date", "meal_period", "meal_option", "default_option"}).
+			AddRow(1, "John", "2024-02-04", 1, 3, 2).
+			AddRow(1, "John", "2024-02-04", 2, 0, 2).
+			AddRow(2, "Paul", "2024-02-04", 1, 0, 1).
+			AddRow(2, "Paul", "2024-02-04", 2, 2, 1))
+}
+
+// TestExportMealsCSV verifies one CSV record per member per date with a source column per period.
+func TestExportMealsCSV(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	expectExportQueries(mock)
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/export/meals?date=2024-02-04&days=1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="meals_2024-02-04_2024-02-04.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "\ufeff"+
+		"date,user_id,user_name,昼食,昼食_source,夕食,夕食_source,home_meals\n"+
+		"2024-02-04,1,John,弁当,meals,家,user_defaults,1\n"+
+		"2024-02-04,2,Paul,なし,user_defaults,家,meals,1\n", w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestExportMealsJSON verifies the JSON array form carries the same records.
+func TestExportMealsJSON(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	expectExportQueries(mock)
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/export/meals?date=2024-02-04&days=1&format=json", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"date":"2024-02-04","user_id":1,"user_name":"John","home_meals":1,"periods":{
+			"1":{"meal_option":3,"label":"弁当","source":"meals"},
+			"2":{"meal_option":2,"label":"家","source":"user_defaults"}}},
+		{"date":"2024-02-04","user_id":2,"user_name":"Paul","home_meals":1,"periods":{
+			"1":{"meal_option":1,"label":"なし","source":"user_defaults"},
+			"2":{"meal_option":2,"label":"家","source":"meals"}}}
+	]`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestExportMealsInvalidParams verifies validation happens before any query.
+func TestExportMealsInvalidParams(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	for _, q := range []string{"date=2024-02-04", "date=bad&days=1", "date=2024-02-04&days=1&format=xlsx"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/export/meals?"+q, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, q)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
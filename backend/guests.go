@@ -0,0 +1,367 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MealGuest is one row of meal_guests: visitors who are not members eating one period
+// of one day. HostUserID is the member who invited them.
+type MealGuest struct {
+	ID           int64   `json:"id"`
+	Date         string  `json:"date"`
+	MealPeriod   int     `json:"meal_period"`
+	Count        int     `json:"count"`
+	Name         *string `json:"name"`
+	HostUserID   int     `json:"host_user_id"`
+	HostUserName string  `json:"host_user_name"`
+	DietaryNotes *string `json:"dietary_notes"`
+}
+
+// MealGuestCreate is the request body for POST /api/meal-guests.
+// HostUserID defaults to the caller.
+type MealGuestCreate struct {
+	Date         string  `json:"date"`
+	MealPeriod   int     `json:"meal_period"`
+	Count        int     `json:"count"`
+	Name         *string `json:"name"`
+	HostUserID   *int    `json:"host_user_id"`
+	DietaryNotes *string `json:"dietary_notes"`
+}
+
+// MealGuestPatch is the request body for PATCH /api/meal-guests/:id.
+// Nil fields are left unchanged; "" clears name and dietary_notes.
+type MealGuestPatch struct {
+	Date         *string `json:"date"`
+	MealPeriod   *int    `json:"meal_period"`
+	Count        *int    `json:"count"`
+	Name         *string `json:"name"`
+	HostUserID   *int    `json:"host_user_id"`
+	DietaryNotes *string `json:"dietary_notes"`
+}
+
+// mealGuestColumns are the columns scanMealGuest reads, from meal_guests g joined to
+// its host h.
+const mealGuestColumns = `g.id, TO_CHAR(g.date, 'YYYY-MM-DD'), g.meal_period, g.count, g.name,
+    g.host_user_id, h.name, g.dietary_notes`
+
+// getMealGuestsQuery lists the guests in [$1, $2] by date and period display order.
+const getMealGuestsQuery = `SELECT ` + mealGuestColumns + `
+FROM meal_guests g
+JOIN users h ON h.id = g.host_user_id
+JOIN meal_periods p ON p.id = g.meal_period
+WHERE g.date BETWEEN $1 AND $2
+ORDER BY g.date, p.sort_order, g.meal_period, g.id`
+
+// getGuestCountsQuery totals the guests in [$1, $2] per host, date and period, for
+// folding into GET /api/meals.
+const getGuestCountsQuery = `SELECT host_user_id, TO_CHAR(date, 'YYYY-MM-DD'), meal_period, SUM(count)
+FROM meal_guests
+WHERE date BETWEEN $1 AND $2
+GROUP BY host_user_id, date, meal_period`
+
+// futureHostedGuestsQuery lists the guests from today on that member $1 hosts.
+const futureHostedGuestsQuery = `SELECT ` + mealGuestColumns + `
+FROM meal_guests g
+JOIN users h ON h.id = g.host_user_id
+JOIN meal_periods p ON p.id = g.meal_period
+WHERE g.host_user_id = $1 AND g.date >= CURRENT_DATE
+ORDER BY g.date, p.sort_order, g.meal_period, g.id`
+
+// getMealGuestHostQuery returns who hosts guest row $1, for authorization.
+const getMealGuestHostQuery = "SELECT host_user_id FROM meal_guests WHERE id = $1"
+
+const createMealGuestStmt = `WITH g AS (
+    INSERT INTO meal_guests (date, meal_period, count, name, host_user_id, dietary_notes)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    RETURNING *
+)
+SELECT ` + mealGuestColumns + ` FROM g JOIN users h ON h.id = g.host_user_id`
+
+const updateMealGuestStmt = `WITH g AS (
+    UPDATE meal_guests SET
+        date          = COALESCE($1::date, date),
+        meal_period   = COALESCE($2::int, meal_period),
+        count         = COALESCE($3::int, count),
+        name          = CASE WHEN $4::bool THEN $5::text ELSE name END,
+        host_user_id  = COALESCE($6::int, host_user_id),
+        dietary_notes = CASE WHEN $7::bool THEN $8::text ELSE dietary_notes END
+    WHERE id = $9
+    RETURNING *
+)
+SELECT ` + mealGuestColumns + ` FROM g JOIN users h ON h.id = g.host_user_id`
+
+const deleteMealGuestStmt = "DELETE FROM meal_guests WHERE id = $1"
+
+// getMealGuests lists the guests of a date range (?date=&days= as GET /api/meals).
+func getMealGuests(c *gin.Context) {
+	startDate, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter. Must be a positive integer."})
+		return
+	}
+	endDate := startDate.AddDate(0, 0, days-1).Format("2006-01-02")
+
+	rows, err := db.Query(getMealGuestsQuery, startDate.Format("2006-01-02"), endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	guests := []MealGuest{}
+	for rows.Next() {
+		g, err := scanMealGuest(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		guests = append(guests, g)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, guests)
+}
+
+// createMealGuest records guests for one period of one day. Members may only host
+// guests themselves; admins may name any host.
+func createMealGuest(c *gin.Context) {
+	var req MealGuestCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	caller := currentUser(c)
+	host := caller.ID
+	if req.HostUserID != nil {
+		host = *req.HostUserID
+	}
+	if !caller.IsAdmin && host != caller.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only host guests yourself"})
+		return
+	}
+	v, err := newValidator(validatePeriods | validateUsers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	v.date(0, "date", req.Date)
+	v.period(0, "meal_period", req.MealPeriod, false)
+	v.guestCount(0, req.Count)
+	v.host(0, host)
+	if v.respond(c) {
+		return
+	}
+	row := db.QueryRow(createMealGuestStmt, req.Date, req.MealPeriod, req.Count,
+		optionalText(req.Name), host, optionalText(req.DietaryNotes))
+	g, err := scanMealGuest(row)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, g)
+}
+
+// updateMealGuest changes a guest row. Members may only change guests they host and
+// may not hand them to someone else.
+func updateMealGuest(c *gin.Context) {
+	id, ok := mealGuestID(c)
+	if !ok {
+		return
+	}
+	var req MealGuestPatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !authorizeMealGuest(c, id) {
+		return
+	}
+	if caller := currentUser(c); !caller.IsAdmin && req.HostUserID != nil && *req.HostUserID != caller.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only host guests yourself"})
+		return
+	}
+	v, err := newValidator(validatePeriods | validateUsers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Date != nil {
+		v.date(0, "date", *req.Date)
+	}
+	if req.MealPeriod != nil {
+		v.period(0, "meal_period", *req.MealPeriod, false)
+	}
+	if req.Count != nil {
+		v.guestCount(0, *req.Count)
+	}
+	if req.HostUserID != nil {
+		v.host(0, *req.HostUserID)
+	}
+	if v.respond(c) {
+		return
+	}
+	var date interface{}
+	if req.Date != nil {
+		date = *req.Date
+	}
+	row := db.QueryRow(updateMealGuestStmt, date, nullableInt(req.MealPeriod), nullableInt(req.Count),
+		req.Name != nil, optionalText(req.Name), nullableInt(req.HostUserID),
+		req.DietaryNotes != nil, optionalText(req.DietaryNotes), id)
+	g, err := scanMealGuest(row)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "meal guest not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, g)
+}
+
+// deleteMealGuest removes a guest row. Members may only remove guests they host.
+func deleteMealGuest(c *gin.Context) {
+	id, ok := mealGuestID(c)
+	if !ok {
+		return
+	}
+	if !authorizeMealGuest(c, id) {
+		return
+	}
+	if _, err := db.Exec(deleteMealGuestStmt, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Meal guest deleted"})
+}
+
+func mealGuestID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid meal guest id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// authorizeMealGuest writes a 404 when guest row id does not exist, or a 403 when the
+// caller is neither its host nor an admin.
+func authorizeMealGuest(c *gin.Context, id int64) bool {
+	var host int
+	err := db.QueryRow(getMealGuestHostQuery, id).Scan(&host)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "meal guest not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if caller := currentUser(c); !caller.IsAdmin && host != caller.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only change guests you host"})
+		return false
+	}
+	return true
+}
+
+// guestCount checks the number of guests in a row.
+func (v *validator) guestCount(i int, n int) {
+	if n < 1 {
+		v.fail(i, "count", "must be at least 1")
+	}
+}
+
+// host checks a guest host: an active eater, so the guests show up in GET /api/meals.
+func (v *validator) host(i int, id int) {
+	if v.member(i, "host_user_id", id) && !v.users[id].IsEater {
+		v.fail(i, "host_user_id", "user %d is not an eater", id)
+	}
+}
+
+// optionalText trims an optional text field; nil and blank values are stored as NULL.
+func optionalText(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	if t := strings.TrimSpace(*s); t != "" {
+		return t
+	}
+	return nil
+}
+
+// hostedGuests runs in the transaction that turns is_eater or active off for userID and
+// returns the guests they host from today on. GET /api/meals shows guests under their
+// host, so the caller must roll back when there are any. Past guests are history.
+func hostedGuests(tx *sql.Tx, userID string) ([]MealGuest, error) {
+	rows, err := tx.Query(futureHostedGuestsQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var guests []MealGuest
+	for rows.Next() {
+		g, err := scanMealGuest(rows)
+		if err != nil {
+			return nil, err
+		}
+		guests = append(guests, g)
+	}
+	return guests, rows.Err()
+}
+
+// hostedGuestsConflictResponse writes the 409 for a change that would hide a member's
+// guests from GET /api/meals.
+func hostedGuestsConflictResponse(c *gin.Context, guests []MealGuest) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":       "user still hosts guests; move them to another host or remove them first",
+		"meal_guests": guests,
+	})
+}
+
+// loadGuestCounts totals the guests in [from, to] by host, then date, then period id.
+func loadGuestCounts(from, to string) (map[int]map[string]map[int]int, error) {
+	rows, err := db.Query(getGuestCountsQuery, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := map[int]map[string]map[int]int{}
+	for rows.Next() {
+		var host, periodID, count int
+		var date string
+		if err := rows.Scan(&host, &date, &periodID, &count); err != nil {
+			return nil, err
+		}
+		if result[host] == nil {
+			result[host] = map[string]map[int]int{}
+		}
+		if result[host][date] == nil {
+			result[host][date] = map[int]int{}
+		}
+		result[host][date][periodID] = count
+	}
+	return result, rows.Err()
+}
+
+func scanMealGuest(row rowScanner) (MealGuest, error) {
+	var g MealGuest
+	var name, notes sql.NullString
+	if err := row.Scan(&g.ID, &g.Date, &g.MealPeriod, &g.Count, &name,
+		&g.HostUserID, &g.HostUserName, &notes); err != nil {
+		return g, err
+	}
+	g.Name, g.DietaryNotes = nullString(name), nullString(notes)
+	return g, nil
+}
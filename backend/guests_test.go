@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// mealGuestRows returns the columns scanned by scanMealGuest.
+func mealGuestRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "date", "meal_period", "count", "name", "host_user_id", "host_user_name", "dietary_notes"})
+}
+
+// TestGetMealGuests verifies GET /api/meal-guests lists the guests of the range.
+func TestGetMealGuests(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealGuestsQuery)).WithArgs("2025-02-22", "2025-02-28").
+		WillReturnRows(mealGuestRows().
+			AddRow(7, "2025-02-22", 2, 1, "Grandma", 3, "Taro", "no shellfish").
+			AddRow(8, "2025-02-23", 1, 2, nil, 1, "John", nil))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/meal-guests?date=2025-02-22&days=7", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"id":7,"date":"2025-02-22","meal_period":2,"count":1,"name":"Grandma","host_user_id":3,"host_user_name":"Taro","dietary_notes":"no shellfish"},
+		{"id":8,"date":"2025-02-23","meal_period":1,"count":2,"name":null,"host_user_id":1,"host_user_name":"John","dietary_notes":null}
+	]`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCreateMealGuest verifies a member's guests default to them as host and blank
+// text fields are stored as NULL.
+func TestCreateMealGuest(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	mock.ExpectQuery(regexp.QuoteMeta(createMealGuestStmt)).
+		WithArgs("2025-02-22", 2, 1, "Grandma", 3, nil).
+		WillReturnRows(mealGuestRows().AddRow(7, "2025-02-22", 2, 1, "Grandma", 3, "Taro", nil))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/meal-guests",
+		bytes.NewBufferString(`{"date":"2025-02-22","meal_period":2,"count":1,"name":" Grandma ","dietary_notes":" "}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":7,"date":"2025-02-22","meal_period":2,"count":1,"name":"Grandma","host_user_id":3,"host_user_name":"Taro","dietary_notes":null}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCreateMealGuestValidation verifies every invalid field is reported in one 422,
+// and that members cannot name another host.
+func TestCreateMealGuestValidation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/meal-guests",
+		bytes.NewBufferString(`{"date":"2025-02-30","meal_period":9,"count":0,"host_user_id":42}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[
+		{"index":0,"field":"date","message":"must be a date in YYYY-MM-DD format"},
+		{"index":0,"field":"meal_period","message":"unknown meal period 9"},
+		{"index":0,"field":"count","message":"must be at least 1"},
+		{"index":0,"field":"host_user_id","message":"unknown user 42"}
+	]}`, w.Body.String())
+
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/meal-guests",
+		bytes.NewBufferString(`{"date":"2025-02-22","meal_period":2,"count":1,"host_user_id":4}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateMealGuest verifies PATCH /api/meal-guests/:id passes only the given fields
+// and that "" clears a text field.
+func TestUpdateMealGuest(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealGuestHostQuery)).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"host_user_id"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	mock.ExpectQuery(regexp.QuoteMeta(updateMealGuestStmt)).
+		WithArgs(nil, nil, 3, true, nil, nil, false, nil, 7).
+		WillReturnRows(mealGuestRows().AddRow(7, "2025-02-22", 2, 3, nil, 3, "Taro", "no shellfish"))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/api/meal-guests/7", bytes.NewBufferString(`{"count":3,"name":""}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":7,"date":"2025-02-22","meal_period":2,"count":3,"name":null,"host_user_id":3,"host_user_name":"Taro","dietary_notes":"no shellfish"}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeleteMealGuest verifies only the host or an admin may remove guests.
+func TestDeleteMealGuest(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 4, Name: "Hanako", IsEater: true, Active: true})
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealGuestHostQuery)).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"host_user_id"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(getMealGuestHostQuery)).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"host_user_id"}).AddRow(4))
+	mock.ExpectExec(regexp.QuoteMeta(deleteMealGuestStmt)).WithArgs(8).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(getMealGuestHostQuery)).WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"host_user_id"}))
+
+	r := setupRouter()
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/api/meal-guests/7", http.StatusForbidden},
+		{"/api/meal-guests/8", http.StatusOK},
+		{"/api/meal-guests/9", http.StatusNotFound},
+		{"/api/meal-guests/x", http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", tc.path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.path)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestHostedGuestsConflict verifies a member who hosts guests from today on cannot stop
+// being an eater or be deactivated, since their guests would drop out of GET /api/meals.
+func TestHostedGuestsConflict(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	hosted := func() *sqlmock.Rows {
+		return mealGuestRows().AddRow(7, "2026-10-24", 2, 1, "Grandma", 3, "Taro", nil)
+	}
+	const want = `{"error":"user still hosts guests; move them to another host or remove them first","meal_guests":[
+		{"id":7,"date":"2026-10-24","meal_period":2,"count":1,"name":"Grandma","host_user_id":3,"host_user_name":"Taro","dietary_notes":null}
+	]}`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(updateUserStmt)).WithArgs(nil, nil, false, nil, nil, "3").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}).
+			AddRow(3, "Taro", false, false, 3, true, false))
+	mock.ExpectQuery(regexp.QuoteMeta(futureHostedGuestsQuery)).WithArgs("3").WillReturnRows(hosted())
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateUserRolesStmt)).WithArgs(false, false, nil, "3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(futureCookSchedulesQuery)).WithArgs("3").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period"}))
+	mock.ExpectQuery(regexp.QuoteMeta(cookDefaultSchedulesOfQuery)).WithArgs("3").
+		WillReturnRows(sqlmock.NewRows([]string{"day_of_week", "meal_period"}))
+	mock.ExpectQuery(regexp.QuoteMeta(futureHostedGuestsQuery)).WithArgs("3").WillReturnRows(hosted())
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(deactivateUserStmt)).WithArgs("3").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(futureCookSchedulesQuery)).WithArgs("3").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period"}))
+	mock.ExpectQuery(regexp.QuoteMeta(cookDefaultSchedulesOfQuery)).WithArgs("3").
+		WillReturnRows(sqlmock.NewRows([]string{"day_of_week", "meal_period"}))
+	mock.ExpectQuery(regexp.QuoteMeta(futureHostedGuestsQuery)).WithArgs("3").WillReturnRows(hosted())
+	mock.ExpectRollback()
+
+	r := setupRouter()
+	for _, tc := range []struct{ method, path, body string }{
+		{"PATCH", "/api/users/3", `{"is_eater":false}`},
+		{"PUT", "/api/users/3/roles", `{"is_cook":false,"is_eater":false}`},
+		{"DELETE", "/api/users/3", ""},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code, tc.method)
+		assert.JSONEq(t, want, w.Body.String(), tc.method)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// MealChange is one row of the meal_changes audit log.
+// OldOption is nil when the member had no explicit choice (their default applied).
+type MealChange struct {
+	ID         int64     `json:"id"`
+	Date       string    `json:"date"`
+	UserID     int       `json:"user_id"`
+	UserName   string    `json:"user_name"`
+	MealPeriod int       `json:"meal_period"`
+	OldOption  *int      `json:"old_option"`
+	NewOption  int       `json:"new_option"`
+	ActorID    *int      `json:"actor_id"` // nil once the actor has been deleted
+	ActorName  *string   `json:"actor_name"`
+	Source     string    `json:"source"`
+	CutoffMode *string   `json:"cutoff_mode"` // set when the change was made past the cutoff
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// CookScheduleChange is one row of the cook_schedule_changes audit log.
+// *Override is false when no cook_schedules row existed (the weekday default applied);
+// an override with a nil cook is an explicit 各自.
+type CookScheduleChange struct {
+	ID              int64     `json:"id"`
+	Date            string    `json:"date"`
+	MealPeriod      int       `json:"meal_period"`
+	OldOverride     bool      `json:"old_override"`
+	OldCookUserID   *int      `json:"old_cook_user_id"`
+	OldCookUserName *string   `json:"old_cook_user_name"`
+	NewOverride     bool      `json:"new_override"`
+	NewCookUserID   *int      `json:"new_cook_user_id"`
+	NewCookUserName *string   `json:"new_cook_user_name"`
+	ActorID         *int      `json:"actor_id"`
+	ActorName       *string   `json:"actor_name"`
+	Source          string    `json:"source"`
+	ChangedAt       time.Time `json:"changed_at"`
+}
+
+// CookDefaultScheduleChange is one row of the cook_default_schedule_changes audit log.
+// A nil cook is 各自.
+type CookDefaultScheduleChange struct {
+	ID              int64     `json:"id"`
+	DayOfWeek       int       `json:"day_of_week"`
+	MealPeriod      int       `json:"meal_period"`
+	OldCookUserID   *int      `json:"old_cook_user_id"`
+	OldCookUserName *string   `json:"old_cook_user_name"`
+	NewCookUserID   *int      `json:"new_cook_user_id"`
+	NewCookUserName *string   `json:"new_cook_user_name"`
+	ActorID         *int      `json:"actor_id"`
+	ActorName       *string   `json:"actor_name"`
+	Source          string    `json:"source"`
+	ChangedAt       time.Time `json:"changed_at"`
+}
+
+// History is the response body for GET /api/history.
+type History struct {
+	Meals                []MealChange                `json:"meals"`
+	CookSchedules        []CookScheduleChange        `json:"cook_schedules"`
+	CookDefaultSchedules []CookDefaultScheduleChange `json:"cook_default_schedules"`
+}
+
+// getMealChangesQuery lists meal changes for meal dates in [$1, $2], newest first.
+// A NULL $3 includes every member.
+const getMealChangesQuery = `SELECT mc.id, TO_CHAR(mc.date, 'YYYY-MM-DD'), mc.user_id, u.name, mc.meal_period,
+    mc.old_option, mc.new_option, mc.actor_id, a.name, mc.source, mc.cutoff_mode, mc.changed_at
+FROM meal_changes mc
+JOIN users u ON u.id = mc.user_id
+LEFT JOIN users a ON a.id = mc.actor_id
+WHERE mc.date BETWEEN $1 AND $2 AND ($3::int IS NULL OR mc.user_id = $3)
+ORDER BY mc.changed_at DESC, mc.id DESC`
+
+// getCookScheduleChangesQuery lists cook assignment changes for dates in [$1, $2], newest first.
+// A non-NULL $3 keeps only changes that assigned or unassigned that member.
+const getCookScheduleChangesQuery = `SELECT csc.id, TO_CHAR(csc.date, 'YYYY-MM-DD'), csc.meal_period,
+    csc.old_override, csc.old_cook_user_id, o.name, csc.new_override, csc.new_cook_user_id, n.name,
+    csc.actor_id, a.name, csc.source, csc.changed_at
+FROM cook_schedule_changes csc
+LEFT JOIN users o ON o.id = csc.old_cook_user_id
+LEFT JOIN users n ON n.id = csc.new_cook_user_id
+LEFT JOIN users a ON a.id = csc.actor_id
+WHERE csc.date BETWEEN $1 AND $2
+    AND ($3::int IS NULL OR csc.old_cook_user_id = $3 OR csc.new_cook_user_id = $3)
+ORDER BY csc.changed_at DESC, csc.id DESC`
+
+// getCookDefaultScheduleChangesQuery lists weekday default changes for the weekdays in
+// $1 made before the end of day $2, newest first: those are the changes that can
+// have decided the cook of a date in the range. $3 filters by member as above.
+const getCookDefaultScheduleChangesQuery = `SELECT cdsc.id, cdsc.day_of_week, cdsc.meal_period,
+    cdsc.old_cook_user_id, o.name, cdsc.new_cook_user_id, n.name,
+    cdsc.actor_id, a.name, cdsc.source, cdsc.changed_at
+FROM cook_default_schedule_changes cdsc
+LEFT JOIN users o ON o.id = cdsc.old_cook_user_id
+LEFT JOIN users n ON n.id = cdsc.new_cook_user_id
+LEFT JOIN users a ON a.id = cdsc.actor_id
+WHERE cdsc.day_of_week = ANY($1::int[]) AND cdsc.changed_at < $2::date + 1
+    AND ($3::int IS NULL OR cdsc.old_cook_user_id = $3 OR cdsc.new_cook_user_id = $3)
+ORDER BY cdsc.changed_at DESC, cdsc.id DESC`
+
+// getHistory returns the audit log for meals and cook assignments whose date falls in
+// the requested range, so "who changed Taro's dinner?" can be answered per day, plus
+// the weekday default changes behind those dates.
+func getHistory(c *gin.Context) {
+	startDate, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter. Must be a positive integer."})
+		return
+	}
+	var userID interface{}
+	if s := c.Query("user_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+		userID = id
+	}
+	from := startDate.Format("2006-01-02")
+	to := startDate.AddDate(0, 0, days-1).Format("2006-01-02")
+
+	var weekdays []int64
+	for i := 0; i < days && i < 7; i++ {
+		weekdays = append(weekdays, int64(startDate.AddDate(0, 0, i).Weekday()))
+	}
+
+	history := History{Meals: []MealChange{}, CookSchedules: []CookScheduleChange{},
+		CookDefaultSchedules: []CookDefaultScheduleChange{}}
+	rows, err := db.Query(getMealChangesQuery, from, to, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var mc MealChange
+		var oldOption, actorID sql.NullInt64
+		var actorName, cutoffMode sql.NullString
+		if err := rows.Scan(&mc.ID, &mc.Date, &mc.UserID, &mc.UserName, &mc.MealPeriod,
+			&oldOption, &mc.NewOption, &actorID, &actorName, &mc.Source, &cutoffMode, &mc.ChangedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		mc.OldOption = nullInt(oldOption)
+		mc.ActorID = nullInt(actorID)
+		mc.ActorName = nullString(actorName)
+		mc.CutoffMode = nullString(cutoffMode)
+		history.Meals = append(history.Meals, mc)
+	}
+
+	cookRows, err := db.Query(getCookScheduleChangesQuery, from, to, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer cookRows.Close()
+	for cookRows.Next() {
+		var cc CookScheduleChange
+		var oldCook, newCook, actorID sql.NullInt64
+		var oldName, newName, actorName sql.NullString
+		if err := cookRows.Scan(&cc.ID, &cc.Date, &cc.MealPeriod,
+			&cc.OldOverride, &oldCook, &oldName, &cc.NewOverride, &newCook, &newName,
+			&actorID, &actorName, &cc.Source, &cc.ChangedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		cc.OldCookUserID, cc.OldCookUserName = nullInt(oldCook), nullString(oldName)
+		cc.NewCookUserID, cc.NewCookUserName = nullInt(newCook), nullString(newName)
+		cc.ActorID, cc.ActorName = nullInt(actorID), nullString(actorName)
+		history.CookSchedules = append(history.CookSchedules, cc)
+	}
+
+	defaultRows, err := db.Query(getCookDefaultScheduleChangesQuery, pq.Array(weekdays), to, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer defaultRows.Close()
+	for defaultRows.Next() {
+		var dc CookDefaultScheduleChange
+		var oldCook, newCook, actorID sql.NullInt64
+		var oldName, newName, actorName sql.NullString
+		if err := defaultRows.Scan(&dc.ID, &dc.DayOfWeek, &dc.MealPeriod, &oldCook, &oldName, &newCook, &newName,
+			&actorID, &actorName, &dc.Source, &dc.ChangedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		dc.OldCookUserID, dc.OldCookUserName = nullInt(oldCook), nullString(oldName)
+		dc.NewCookUserID, dc.NewCookUserName = nullInt(newCook), nullString(newName)
+		dc.ActorID, dc.ActorName = nullInt(actorID), nullString(actorName)
+		history.CookDefaultSchedules = append(history.CookDefaultSchedules, dc)
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// changeSource identifies the endpoint that made a change, e.g. "PUT /api/meals/bulk-update".
+func changeSource(c *gin.Context) string {
+	return c.Request.Method + " " + c.FullPath()
+}
+
+// nullInt converts a scanned nullable integer to a JSON-friendly pointer.
+func nullInt(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	i := int(n.Int64)
+	return &i
+}
+
+// nullString converts a scanned nullable string to a JSON-friendly pointer.
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetHistory verifies GET /api/history returns every log with names resolved.
+func TestGetHistory(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	at := time.Date(2025, 2, 15, 9, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(getMealChangesQuery)).WithArgs("2025-02-16", "2025-02-17", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "date", "user_id", "user_name", "meal_period",
+			"old_option", "new_option", "actor_id", "actor_name", "source", "cutoff_mode", "changed_at"}).
+			AddRow(2, "2025-02-16", 3, "Taro", 2, 2, 1, 3, "Taro", "PUT /api/meals/bulk-update", "notify", at).
+			AddRow(1, "2025-02-16", 3, "Taro", 2, nil, 2, nil, nil, "PUT /api/meals/bulk-update", nil, at))
+	mock.ExpectQuery(regexp.QuoteMeta(getCookScheduleChangesQuery)).WithArgs("2025-02-16", "2025-02-17", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "date", "meal_period", "old_override", "old_cook_user_id", "old_cook_user_name",
+			"new_override", "new_cook_user_id", "new_cook_user_name", "actor_id", "actor_name", "source", "changed_at"}).
+			AddRow(5, "2025-02-17", 2, false, nil, nil, true, 3, "Taro", 5, "Mother", "PUT /api/cook-schedules", at))
+	// The 16th and 17th are a Sunday and a Monday.
+	mock.ExpectQuery(regexp.QuoteMeta(getCookDefaultScheduleChangesQuery)).WithArgs(pq.Array([]int64{0, 1}), "2025-02-17", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "day_of_week", "meal_period", "old_cook_user_id", "old_cook_user_name",
+			"new_cook_user_id", "new_cook_user_name", "actor_id", "actor_name", "source", "changed_at"}).
+			AddRow(7, 1, 1, 3, "Taro", nil, nil, 1, "John", "PUT /api/cook-default-schedules", at))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/history?date=2025-02-16&days=2&user_id=3", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"meals": [
+			{"id":2,"date":"2025-02-16","user_id":3,"user_name":"Taro","meal_period":2,"old_option":2,"new_option":1,
+			 "actor_id":3,"actor_name":"Taro","source":"PUT /api/meals/bulk-update","cutoff_mode":"notify","changed_at":"2025-02-15T09:30:00Z"},
+			{"id":1,"date":"2025-02-16","user_id":3,"user_name":"Taro","meal_period":2,"old_option":null,"new_option":2,
+			 "actor_id":null,"actor_name":null,"source":"PUT /api/meals/bulk-update","cutoff_mode":null,"changed_at":"2025-02-15T09:30:00Z"}
+		],
+		"cook_schedules": [
+			{"id":5,"date":"2025-02-17","meal_period":2,
+			 "old_override":false,"old_cook_user_id":null,"old_cook_user_name":null,
+			 "new_override":true,"new_cook_user_id":3,"new_cook_user_name":"Taro",
+			 "actor_id":5,"actor_name":"Mother","source":"PUT /api/cook-schedules","changed_at":"2025-02-15T09:30:00Z"}
+		],
+		"cook_default_schedules": [
+			{"id":7,"day_of_week":1,"meal_period":1,
+			 "old_cook_user_id":3,"old_cook_user_name":"Taro","new_cook_user_id":null,"new_cook_user_name":null,
+			 "actor_id":1,"actor_name":"John","source":"PUT /api/cook-default-schedules","changed_at":"2025-02-15T09:30:00Z"}
+		]
+	}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetHistoryInvalidParams verifies parameter validation happens before any query.
+func TestGetHistoryInvalidParams(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	for _, q := range []string{"date=2025-02-16", "date=bad&days=1", "date=2025-02-16&days=1&user_id=x"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/history?"+q, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, q)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
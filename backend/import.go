@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportBytes bounds an uploaded CSV; a term timetable is a few hundred lines.
+const maxImportBytes = 1 << 20
+
+// Import modes: a dry run only validates, a commit also writes.
+const (
+	importDryRun = "dry-run"
+	importCommit = "commit"
+)
+
+// ImportLine is the validation result for one data line of an imported CSV.
+// Line is the line number in the file, counting the header as line 1.
+type ImportLine struct {
+	Line      int         `json:"line"`
+	UserID    int         `json:"user_id,omitempty"`
+	Date      string      `json:"date,omitempty"`
+	DayOfWeek *int        `json:"day_of_week,omitempty"`
+	Options   map[int]int `json:"options,omitempty"`
+	Errors    []string    `json:"errors,omitempty"`
+}
+
+// ImportReport is the response of a dry run, and of a commit refused for invalid lines.
+// A refused commit also lists the problems as FieldErrors, like other write handlers;
+// their index is the line's position in Lines.
+type ImportReport struct {
+	Error   string       `json:"error,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+	Mode    string       `json:"mode"`
+	Valid   int          `json:"valid"`
+	Invalid int          `json:"invalid"`
+	Lines   []ImportLine `json:"lines"`
+}
+
+// importColumns says which CSV column holds what. Periods maps a column index to
+// the meal period id named in its header.
+type importColumns struct {
+	user    int
+	key     int
+	periods map[int]int
+}
+
+// importLookups resolves the user names and option labels an imported CSV may use.
+// Only active users, periods and options can be imported.
+type importLookups struct {
+	usersByID   map[int]User
+	usersByName map[string][]User
+	periods     []MealPeriod
+	options     []MealOption
+}
+
+// importMeals imports meal choices from CSV (user, date and one column per meal period).
+// mode=dry-run (the default) returns a per-line report; mode=commit writes every line
+// through the same path as PUT /api/meals/bulk-update, in one transaction.
+func importMeals(c *gin.Context) {
+	mode, ok := importMode(c)
+	if !ok {
+		return
+	}
+	records, lineNos, cols, lk, ok := readImport(c, "date")
+	if !ok {
+		return
+	}
+
+	report := ImportReport{Mode: mode, Lines: []ImportLine{}}
+	var updates []MealUpdate
+	var updateLines []int // report.Lines index of each update
+	for i, rec := range records {
+		l := lk.parseLine(rec, lineNos[i], cols)
+		if date := importCell(rec, cols.key); validDate(date) {
+			l.Date = date
+		} else {
+			l.Errors = append(l.Errors, fmt.Sprintf("invalid date %q", date))
+		}
+		report.Lines = append(report.Lines, l)
+		if len(l.Errors) == 0 {
+			updates = append(updates, MealUpdate{UserID: l.UserID, Date: l.Date, Options: l.Options})
+			updateLines = append(updateLines, len(report.Lines)-1)
+		}
+	}
+
+	// Report what bulk-update would refuse, so a dry run predicts the commit.
+	caller := currentUser(c)
+	denied, err := forbiddenMealUpdates(caller, updates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	periods := make(map[int]MealPeriod, len(lk.periods))
+	for _, p := range lk.periods {
+		periods[p.ID] = p
+	}
+	locked, deniedOverride, err := lockedMealUpdates(caller, updates, periods, c.Query("override") == "true", time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, d := range append(denied, deniedOverride...) {
+		l := &report.Lines[updateLines[d.Index]]
+		l.Errors = append(l.Errors, d.Reason)
+	}
+	for _, lr := range locked {
+		if lr.Mode == cutoffReject {
+			l := &report.Lines[updateLines[lr.Index]]
+			l.Errors = append(l.Errors, fmt.Sprintf("%s is past the cutoff (%s)", periods[lr.MealPeriod].Name, lr.Cutoff.Format("2006-01-02 15:04")))
+		}
+	}
+	if !report.finish(c) {
+		return
+	}
+	saveMealUpdates(c, updates)
+}
+
+// importUserDefaults imports weekday defaults from CSV (user, weekday and one column
+// per meal period). Modes work as in importMeals; a commit writes in one transaction.
+// Members may only import their own defaults.
+func importUserDefaults(c *gin.Context) {
+	mode, ok := importMode(c)
+	if !ok {
+		return
+	}
+	records, lineNos, cols, lk, ok := readImport(c, "weekday", "day_of_week")
+	if !ok {
+		return
+	}
+
+	caller := currentUser(c)
+	report := ImportReport{Mode: mode, Lines: []ImportLine{}}
+	for i, rec := range records {
+		l := lk.parseLine(rec, lineNos[i], cols)
+		if dow, ok := parseWeekday(importCell(rec, cols.key)); ok {
+			l.DayOfWeek = &dow
+		} else {
+			l.Errors = append(l.Errors, fmt.Sprintf("invalid weekday %q", importCell(rec, cols.key)))
+		}
+		if l.UserID != 0 && !caller.IsAdmin && l.UserID != caller.ID {
+			l.Errors = append(l.Errors, "you can only change your own defaults")
+		}
+		report.Lines = append(report.Lines, l)
+	}
+	if !report.finish(c) {
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	stmt, err := tx.Prepare(updateUserDefaultsStmt)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer stmt.Close()
+	for _, l := range report.Lines {
+		for _, periodID := range sortedPeriodIDs(l.Options) {
+			if _, err := stmt.Exec(l.UserID, *l.DayOfWeek, periodID, l.Options[periodID]); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User defaults imported", "lines": len(report.Lines)})
+}
+
+// importMode reads ?mode=, writing a 400 when it is neither dry-run nor commit.
+func importMode(c *gin.Context) (string, bool) {
+	mode := c.DefaultQuery("mode", importDryRun)
+	if mode != importDryRun && mode != importCommit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode. Use dry-run or commit."})
+		return "", false
+	}
+	return mode, true
+}
+
+// finish counts the report's lines and, for a dry run or a commit with invalid lines,
+// writes it as the response. A refused commit is a 422 like any other validation
+// failure. It returns true when the caller should go on to write.
+func (r *ImportReport) finish(c *gin.Context) bool {
+	for i, l := range r.Lines {
+		if len(l.Errors) > 0 {
+			r.Invalid++
+		} else {
+			r.Valid++
+		}
+		for _, e := range l.Errors {
+			r.Errors = append(r.Errors, FieldError{Index: i, Field: fmt.Sprintf("line %d", l.Line), Message: e})
+		}
+	}
+	if r.Mode == importCommit && r.Invalid > 0 {
+		r.Error = "validation failed"
+		c.JSON(http.StatusUnprocessableEntity, r)
+		return false
+	}
+	r.Errors = nil
+	if r.Mode == importDryRun {
+		c.JSON(http.StatusOK, r)
+		return false
+	}
+	return true
+}
+
+// readImport reads the uploaded CSV, loads the lookups and maps its header. keyNames
+// are the accepted headers of the second key column (date or weekday). On failure it
+// writes the error response and returns ok=false.
+func readImport(c *gin.Context, keyNames ...string) (records [][]string, lineNos []int, cols importColumns, lk importLookups, ok bool) {
+	body, err := importBody(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err == io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty CSV"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		line, _ := r.FieldPos(0)
+		records = append(records, rec)
+		lineNos = append(lineNos, line)
+	}
+
+	if lk, err = loadImportLookups(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if cols, err = lk.mapHeader(header, keyNames); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	return records, lineNos, cols, lk, true
+}
+
+// importBody returns the uploaded CSV: the "file" field of a multipart form, or the raw body.
+func importBody(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return io.ReadAll(c.Request.Body)
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// loadImportLookups loads the active users, periods and options.
+func loadImportLookups() (importLookups, error) {
+	lk := importLookups{usersByID: map[int]User{}, usersByName: map[string][]User{}}
+	users, err := loadUsers()
+	if err != nil {
+		return lk, err
+	}
+	for _, u := range users {
+		if u.Active {
+			lk.usersByID[u.ID] = u
+			lk.usersByName[u.Name] = append(lk.usersByName[u.Name], u)
+		}
+	}
+	if lk.periods, err = queryMealPeriods(false); err != nil {
+		return lk, err
+	}
+	lk.options, err = queryMealOptions(false)
+	return lk, err
+}
+
+// mapHeader finds the user column (user, user_id or user_name), the key column and one
+// column per meal period, named by period name or id. Columns GET /api/export/meals
+// adds (user_name beside user_id, *_source, home_meals) are ignored so an export can
+// be edited and imported back.
+func (lk importLookups) mapHeader(header []string, keyNames []string) (importColumns, error) {
+	cols := importColumns{user: -1, key: -1, periods: map[int]int{}}
+	userRank := 0 // user_id beats user beats user_name
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		switch {
+		case h == "user_id" || h == "user" || h == "user_name":
+			rank := map[string]int{"user_id": 3, "user": 2, "user_name": 1}[h]
+			if rank > userRank {
+				cols.user, userRank = i, rank
+			}
+			continue
+		case containsString(keyNames, h):
+			cols.key = i
+			continue
+		case strings.HasSuffix(h, "_source") || h == "home_meals":
+			continue
+		}
+		p, ok := lk.period(h)
+		if !ok {
+			return cols, fmt.Errorf("unknown column %q", h)
+		}
+		cols.periods[i] = p.ID
+	}
+	if cols.user < 0 {
+		return cols, fmt.Errorf("missing column: user")
+	}
+	if cols.key < 0 {
+		return cols, fmt.Errorf("missing column: %s", keyNames[0])
+	}
+	if len(cols.periods) == 0 {
+		return cols, fmt.Errorf("no meal period columns")
+	}
+	return cols, nil
+}
+
+// parseLine resolves the user and the meal period cells of one record. Empty cells
+// leave that period unchanged.
+func (lk importLookups) parseLine(rec []string, line int, cols importColumns) ImportLine {
+	l := ImportLine{Line: line, Options: map[int]int{}}
+	if u, err := lk.user(importCell(rec, cols.user)); err != nil {
+		l.Errors = append(l.Errors, err.Error())
+	} else {
+		l.UserID = u.ID
+	}
+	for _, i := range sortedPeriodIDs(cols.periods) { // column order, for stable errors
+		periodID := cols.periods[i]
+		v := importCell(rec, i)
+		if v == "" {
+			continue
+		}
+		o, ok := lk.option(v)
+		if !ok {
+			l.Errors = append(l.Errors, fmt.Sprintf("unknown meal option %q", v))
+			continue
+		}
+		l.Options[periodID] = o.ID
+	}
+	if len(l.Options) == 0 && len(l.Errors) == 0 {
+		l.Errors = append(l.Errors, "no meal choices")
+	}
+	return l
+}
+
+// user resolves an id or an unambiguous name.
+func (lk importLookups) user(s string) (User, error) {
+	if id, err := strconv.Atoi(s); err == nil {
+		if u, ok := lk.usersByID[id]; ok {
+			return u, nil
+		}
+	}
+	switch users := lk.usersByName[s]; len(users) {
+	case 0:
+		return User{}, fmt.Errorf("unknown user %q", s)
+	case 1:
+		return users[0], nil
+	default:
+		return User{}, fmt.Errorf("ambiguous user name %q, use the id", s)
+	}
+}
+
+// period resolves an active meal period by name or id.
+func (lk importLookups) period(s string) (MealPeriod, bool) {
+	for _, p := range lk.periods {
+		if p.Name == s || strconv.Itoa(p.ID) == s {
+			return p, true
+		}
+	}
+	return MealPeriod{}, false
+}
+
+// option resolves an active meal option by label or id.
+func (lk importLookups) option(s string) (MealOption, bool) {
+	for _, o := range lk.options {
+		if o.Label == s || strconv.Itoa(o.ID) == s {
+			return o, true
+		}
+	}
+	return MealOption{}, false
+}
+
+// parseWeekday accepts 0-6 (0 = Sunday), 日-土 (optionally with 曜 or 曜日) and
+// English day names or their three-letter abbreviations.
+func parseWeekday(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, n >= 0 && n <= 6
+	}
+	for i, ja := range weekdayNames {
+		if s == ja || s == ja+"曜" || s == ja+"曜日" {
+			return i, true
+		}
+		en := time.Weekday(i).String()
+		if strings.EqualFold(s, en) || strings.EqualFold(s, en[:3]) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// importCell returns a trimmed cell, or "" when the record is short.
+func importCell(rec []string, i int) string {
+	if i < 0 || i >= len(rec) {
+		return ""
+	}
+	return strings.TrimSpace(rec[i])
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectImportLookups expects the active users, periods and options an import resolves against.
+func expectImportLookups(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}).
+			AddRow(1, "John", true, true, 1, true, true).
+			AddRow(2, "Hanako", false, true, 2, true, false))
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(false).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(false).WillReturnRows(mealOptionRows())
+}
+
+// TestImportMealsDryRun verifies a dry run reports every line by its line number and
+// writes nothing. Export-only columns and a leading BOM are accepted.
+func TestImportMealsDryRun(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	expectImportLookups(mock)
+	csv := "\ufeffdate,user_id,user_name,昼食,昼食_source,2,home_meals\n" +
+		"2099-01-05,1,John,弁当,meals,家,1\n" +
+		"2099-01-05,Nobody,,3,,,0\n" +
+		"bad,2,Hanako,カレー,,,0\n"
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/import/meals", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"mode":"dry-run","valid":1,"invalid":2,"lines":[
+		{"line":2,"user_id":1,"date":"2099-01-05","options":{"1":3,"2":2}},
+		{"line":3,"date":"2099-01-05","options":{"1":3},"errors":["unknown user \"Nobody\""]},
+		{"line":4,"user_id":2,"errors":["unknown meal option \"カレー\"","invalid date \"bad\""]}
+	]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestImportMealsCommitInvalid verifies a commit with any invalid line writes nothing
+// and returns the report with 422 and the problems as field errors.
+func TestImportMealsCommitInvalid(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	expectImportLookups(mock)
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/import/meals?mode=commit",
+		strings.NewReader("user,date,昼食\nJohn,2099-01-05,家\nJohn,2099-01-06,\n"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"validation failed","errors":[{"index":1,"field":"line 3","message":"no meal choices"}]`)
+	assert.Contains(t, w.Body.String(), `{"line":3,"user_id":1,"date":"2099-01-06","errors":["no meal choices"]}`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestImportBadRequests verifies the mode and header are checked before anything is written.
+func TestImportBadRequests(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/import/meals?mode=apply", strings.NewReader("user,date,昼食\n"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	expectImportLookups(mock)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/import/meals", strings.NewReader("user,date,朝食\n"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"unknown column \"朝食\""}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestImportUserDefaults verifies members may only import their own defaults and a
+// valid commit upserts every given period in one transaction.
+func TestImportUserDefaults(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 2, Name: "Hanako", IsEater: true, Active: true})
+
+	expectImportLookups(mock)
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/import/user-defaults", strings.NewReader("user,weekday,昼食\nJohn,Mon,家\n"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"errors":["you can only change your own defaults"]`)
+
+	expectImportLookups(mock)
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(updateUserDefaultsStmt))
+	prep.ExpectExec().WithArgs(2, 1, 1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs(2, 1, 2, 3).WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs(2, 6, 2, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/import/user-defaults?mode=commit",
+		strings.NewReader("user,day_of_week,昼食,夕食\nHanako,月曜日,家,弁当\n2,土,,なし\n"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"User defaults imported","lines":2}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestParseWeekday verifies the accepted weekday spellings.
+func TestParseWeekday(t *testing.T) {
+	for s, want := range map[string]int{"0": 0, "6": 6, "日": 0, "水曜": 3, "土曜日": 6, "Mon": 1, "friday": 5} {
+		got, ok := parseWeekday(s)
+		assert.True(t, ok, s)
+		assert.Equal(t, want, got, s)
+	}
+	for _, s := range []string{"7", "-1", "", "Funday"} {
+		_, ok := parseWeekday(s)
+		assert.False(t, ok, s)
+	}
+}
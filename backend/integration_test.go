@@ -7,8 +7,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -78,18 +80,19 @@ func startPostgres(t *testing.T) func() {
 //
 // meals 2025-02-17:
 //   John lunch=3(弁当), dinner=1
-//   Paul dinner=2 only → lunch column returns 0 (not set)
+//   Paul dinner=2 only → lunch option returns 0 (not set)
 func seedGetMeals(t *testing.T) {
 	t.Helper()
 	_, err := db.Exec(`
 		INSERT INTO users (id, name) VALUES (1, 'John'), (2, 'Paul');
-		INSERT INTO meal_periods (id) VALUES (1), (2);
-		INSERT INTO meal_options (id) VALUES (1), (2), (3);
-		INSERT INTO user_defaults (user_id, day_of_week, lunch, dinner) VALUES
-			(1, 0, 2, 2),
-			(1, 1, 1, 2),
-			(2, 0, 2, 2),
-			(2, 1, 2, 2);
+		INSERT INTO meal_periods (id, name, sort_order, packs_bento) VALUES (1, '昼食', 1, true), (2, '夕食', 2, false);
+		INSERT INTO meal_options (id, label, eats_at_home, is_skip, is_bento) VALUES
+			(1, 'なし', false, true, false), (2, '家', true, false, false), (3, '弁当', false, false, true);
+		INSERT INTO user_defaults (user_id, day_of_week, meal_period, meal_option) VALUES
+			(1, 0, 1, 2), (1, 0, 2, 2),
+			(1, 1, 1, 1), (1, 1, 2, 2),
+			(2, 0, 1, 2), (2, 0, 2, 2),
+			(2, 1, 1, 2), (2, 1, 2, 2);
 		INSERT INTO meals (user_id, date, meal_period, meal_option) VALUES
 			(1, '2025-02-16', 1, 1),
 			(1, '2025-02-16', 2, 1),
@@ -105,8 +108,8 @@ func seedGetMeals(t *testing.T) {
 // TestGetMealsIntegration tests getMeals against a real PostgreSQL instance.
 // It validates the SQL behaviors that unit tests with sqlmock cannot cover:
 //   - generate_series expands the date range correctly
-//   - CASE WHEN pivots meal_period rows into lunch/dinner columns
-//   - LEFT JOIN with user_defaults provides per-weekday fallback values
+//   - CROSS JOIN meal_periods yields one row per period, grouped into options maps
+//   - LEFT JOIN with user_defaults provides per-weekday, per-period fallback values
 //   - COALESCE returns 0 for lunch when no meal record exists (Paul Mon)
 //   - TO_CHAR formats the date key as YYYY-MM-DD
 func TestGetMealsIntegration(t *testing.T) {
@@ -123,12 +126,12 @@ func TestGetMealsIntegration(t *testing.T) {
 
 	expected := `{
 		"2025-02-16": [
-			{"user_id": 1, "user_name": "John", "lunch": 1, "dinner": 1, "defaultLunch": 2, "defaultDinner": 2},
-			{"user_id": 2, "user_name": "Paul", "lunch": 1, "dinner": 1, "defaultLunch": 2, "defaultDinner": 2}
+			{"user_id": 1, "user_name": "John", "options": {"1": 1, "2": 1}, "defaults": {"1": 2, "2": 2}},
+			{"user_id": 2, "user_name": "Paul", "options": {"1": 1, "2": 1}, "defaults": {"1": 2, "2": 2}}
 		],
 		"2025-02-17": [
-			{"user_id": 1, "user_name": "John", "lunch": 3, "dinner": 1, "defaultLunch": 1, "defaultDinner": 2},
-			{"user_id": 2, "user_name": "Paul", "lunch": 0, "dinner": 2,  "defaultLunch": 2, "defaultDinner": 2}
+			{"user_id": 1, "user_name": "John", "options": {"1": 3, "2": 1}, "defaults": {"1": 1, "2": 2}},
+			{"user_id": 2, "user_name": "Paul", "options": {"1": 0, "2": 2}, "defaults": {"1": 2, "2": 2}}
 		]
 	}`
 	assert.JSONEq(t, expected, w.Body.String())
@@ -148,10 +151,10 @@ func TestBulkUpdateMealsIntegration(t *testing.T) {
 
 	_, err := db.Exec(`
 		INSERT INTO users (id, name) VALUES (1, 'John');
-		INSERT INTO meal_periods (id) VALUES (1), (2);
-		INSERT INTO meal_options (id) VALUES (1), (2), (3);
-		INSERT INTO user_defaults (user_id, day_of_week, lunch, dinner) VALUES
-			(1, 0, 2, 2); -- Sun default: Home/Home
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 1), (2, '夕食', 2);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
+		INSERT INTO user_defaults (user_id, day_of_week, meal_period, meal_option) VALUES
+			(1, 0, 1, 2), (1, 0, 2, 2); -- Sun default: Home/Home
 	`)
 	require.NoError(t, err)
 
@@ -177,25 +180,59 @@ func TestBulkUpdateMealsIntegration(t *testing.T) {
 	}
 
 	// Step 1: insert new meals
-	bulkUpdate([]MealUpdate{{UserID: 1, Date: "2025-02-16", Lunch: 3, Dinner: 1}})
+	bulkUpdate([]MealUpdate{{UserID: 1, Date: "2025-02-16", Options: map[int]int{1: 3, 2: 1}}})
 	assert.JSONEq(t, `{
 		"2025-02-16": [
-			{"user_id":1,"user_name":"John","lunch":3,"dinner":1,"defaultLunch":2,"defaultDinner":2}
+			{"user_id":1,"user_name":"John","options":{"1":3,"2":1},"defaults":{"1":2,"2":2}}
 		]
 	}`, getMeals("date=2025-02-16&days=1"))
 
 	// Step 2: upsert — overwrite lunch only; dinner should remain 1
-	bulkUpdate([]MealUpdate{{UserID: 1, Date: "2025-02-16", Lunch: 1}})
+	bulkUpdate([]MealUpdate{{UserID: 1, Date: "2025-02-16", Options: map[int]int{1: 1}}})
 	assert.JSONEq(t, `{
 		"2025-02-16": [
-			{"user_id":1,"user_name":"John","lunch":1,"dinner":1,"defaultLunch":2,"defaultDinner":2}
+			{"user_id":1,"user_name":"John","options":{"1":1,"2":1},"defaults":{"1":2,"2":2}}
 		]
 	}`, getMeals("date=2025-02-16&days=1"))
+
+	// Step 3: the statement reports whether the option changed, and only a change is
+	// logged and left pending for the cook passed as $8.
+	_, err = db.Exec(`INSERT INTO users (id, name, is_cook) VALUES (5, 'Mother', true)`)
+	require.NoError(t, err)
+	counts := func() (changes, acks int) {
+		t.Helper()
+		require.NoError(t, db.QueryRow(`SELECT (SELECT COUNT(*) FROM meal_changes), (SELECT COUNT(*) FROM acknowledgements)`).
+			Scan(&changes, &acks))
+		return changes, acks
+	}
+	changes, acks := counts()
+	var changed bool
+	require.NoError(t, db.QueryRow(bulkUpdateMealsStmt, 1, "2025-02-16", 2, 1, 1, "test", nil, 5).Scan(&changed))
+	assert.False(t, changed)
+	c, a := counts()
+	assert.Equal(t, [2]int{changes, acks}, [2]int{c, a})
+	require.NoError(t, db.QueryRow(bulkUpdateMealsStmt, 1, "2025-02-16", 2, 2, 1, "test", nil, 5).Scan(&changed))
+	assert.True(t, changed)
+	c, a = counts()
+	assert.Equal(t, [2]int{changes + 1, acks + 1}, [2]int{c, a})
+	var cook, newOption int
+	require.NoError(t, db.QueryRow(`SELECT a.cook_user_id, c.new_option FROM acknowledgements a
+		JOIN meal_changes c ON c.id = a.meal_change_id ORDER BY a.id DESC LIMIT 1`).Scan(&cook, &newOption))
+	assert.Equal(t, [2]int{5, 2}, [2]int{cook, newOption})
+
+	// Step 4: through the endpoint, a late change to a period without a cutoff is left
+	// pending for its cook too; resending the same value adds nothing.
+	_, err = db.Exec(`INSERT INTO cook_schedules (date, meal_period, cook_user_id) VALUES ('2025-02-16', 1, 5)`)
+	require.NoError(t, err)
+	bulkUpdate([]MealUpdate{{UserID: 1, Date: "2025-02-16", Options: map[int]int{1: 2}}})
+	bulkUpdate([]MealUpdate{{UserID: 1, Date: "2025-02-16", Options: map[int]int{1: 2}}})
+	c, a = counts()
+	assert.Equal(t, [2]int{changes + 2, acks + 2}, [2]int{c, a})
 }
 
 // TestGetMealsWeekdayDefaultsIntegration verifies that EXTRACT(DOW FROM d.date)
 // correctly maps each day of the week (0=Sun … 6=Sat) to its user_defaults entry.
-// Each day is given a distinct (lunch, dinner) pair so a wrong DOW mapping is
+// Each day is given a distinct (昼食, 夕食) pair so a wrong DOW mapping is
 // immediately visible in the assertion.
 //
 // Date range: 2025-02-16 (Sun) … 2025-02-22 (Sat) — one full week, no meal records.
@@ -205,16 +242,16 @@ func TestGetMealsWeekdayDefaultsIntegration(t *testing.T) {
 
 	_, err := db.Exec(`
 		INSERT INTO users (id, name) VALUES (1, 'Test');
-		INSERT INTO meal_periods (id) VALUES (1), (2);
-		INSERT INTO meal_options (id) VALUES (1), (2), (3);
-		INSERT INTO user_defaults (user_id, day_of_week, lunch, dinner) VALUES
-			(1, 0, 1, 1),
-			(1, 1, 2, 1),
-			(1, 2, 3, 1),
-			(1, 3, 1, 2),
-			(1, 4, 2, 2),
-			(1, 5, 3, 2),
-			(1, 6, 1, 3);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 1), (2, '夕食', 2);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
+		INSERT INTO user_defaults (user_id, day_of_week, meal_period, meal_option) VALUES
+			(1, 0, 1, 1), (1, 0, 2, 1),
+			(1, 1, 1, 2), (1, 1, 2, 1),
+			(1, 2, 1, 3), (1, 2, 2, 1),
+			(1, 3, 1, 1), (1, 3, 2, 2),
+			(1, 4, 1, 2), (1, 4, 2, 2),
+			(1, 5, 1, 3), (1, 5, 2, 2),
+			(1, 6, 1, 1), (1, 6, 2, 3);
 	`)
 	require.NoError(t, err)
 
@@ -225,13 +262,13 @@ func TestGetMealsWeekdayDefaultsIntegration(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.JSONEq(t, `{
-		"2025-02-16": [{"user_id":1,"user_name":"Test","lunch":0,"dinner":0,"defaultLunch":1,"defaultDinner":1}],
-		"2025-02-17": [{"user_id":1,"user_name":"Test","lunch":0,"dinner":0,"defaultLunch":2,"defaultDinner":1}],
-		"2025-02-18": [{"user_id":1,"user_name":"Test","lunch":0,"dinner":0,"defaultLunch":3,"defaultDinner":1}],
-		"2025-02-19": [{"user_id":1,"user_name":"Test","lunch":0,"dinner":0,"defaultLunch":1,"defaultDinner":2}],
-		"2025-02-20": [{"user_id":1,"user_name":"Test","lunch":0,"dinner":0,"defaultLunch":2,"defaultDinner":2}],
-		"2025-02-21": [{"user_id":1,"user_name":"Test","lunch":0,"dinner":0,"defaultLunch":3,"defaultDinner":2}],
-		"2025-02-22": [{"user_id":1,"user_name":"Test","lunch":0,"dinner":0,"defaultLunch":1,"defaultDinner":3}]
+		"2025-02-16": [{"user_id":1,"user_name":"Test","options":{"1":0,"2":0},"defaults":{"1":1,"2":1}}],
+		"2025-02-17": [{"user_id":1,"user_name":"Test","options":{"1":0,"2":0},"defaults":{"1":2,"2":1}}],
+		"2025-02-18": [{"user_id":1,"user_name":"Test","options":{"1":0,"2":0},"defaults":{"1":3,"2":1}}],
+		"2025-02-19": [{"user_id":1,"user_name":"Test","options":{"1":0,"2":0},"defaults":{"1":1,"2":2}}],
+		"2025-02-20": [{"user_id":1,"user_name":"Test","options":{"1":0,"2":0},"defaults":{"1":2,"2":2}}],
+		"2025-02-21": [{"user_id":1,"user_name":"Test","options":{"1":0,"2":0},"defaults":{"1":3,"2":2}}],
+		"2025-02-22": [{"user_id":1,"user_name":"Test","options":{"1":0,"2":0},"defaults":{"1":1,"2":3}}]
 	}`, w.Body.String())
 }
 
@@ -256,9 +293,9 @@ func TestGetUsersIntegration(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.JSONEq(t, `[
-		{"id":1,"name":"Mother","is_cook":true,"is_eater":false},
-		{"id":2,"name":"Father","is_cook":true,"is_eater":true},
-		{"id":3,"name":"Taro","is_cook":false,"is_eater":true}
+		{"id":1,"name":"Mother","is_cook":true,"is_eater":false,"display_order":0,"active":true,"is_admin":false},
+		{"id":2,"name":"Father","is_cook":true,"is_eater":true,"display_order":0,"active":true,"is_admin":false},
+		{"id":3,"name":"Taro","is_cook":false,"is_eater":true,"display_order":0,"active":true,"is_admin":false}
 	]`, w.Body.String())
 }
 
@@ -286,7 +323,7 @@ func TestUpdateUserRolesIntegration(t *testing.T) {
 	req2, _ := http.NewRequest("GET", "/api/users", nil)
 	r.ServeHTTP(w2, req2)
 	assert.Equal(t, http.StatusOK, w2.Code)
-	assert.JSONEq(t, `[{"id":1,"name":"Taro","is_cook":true,"is_eater":true}]`, w2.Body.String())
+	assert.JSONEq(t, `[{"id":1,"name":"Taro","is_cook":true,"is_eater":true,"display_order":0,"active":true,"is_admin":false}]`, w2.Body.String())
 }
 
 // TestGetMealsEaterFilterIntegration verifies that users with is_eater=false
@@ -299,8 +336,8 @@ func TestGetMealsEaterFilterIntegration(t *testing.T) {
 		INSERT INTO users (id, name, is_cook, is_eater) VALUES
 			(1, 'Mother', true,  false),
 			(2, 'Father', true,  true);
-		INSERT INTO meal_periods (id) VALUES (1), (2);
-		INSERT INTO meal_options (id) VALUES (1), (2), (3);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 1), (2, '夕食', 2);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
 	`)
 	require.NoError(t, err)
 
@@ -318,15 +355,15 @@ func TestGetMealsEaterFilterIntegration(t *testing.T) {
 }
 
 // TestGetMealsNoDefaultsIntegration verifies COALESCE fallback when a user has
-// no entry in user_defaults: defaultLunch and defaultDinner must be 1 (なし).
+// no entry in user_defaults: every period's default must be 1 (なし).
 func TestGetMealsNoDefaultsIntegration(t *testing.T) {
 	cleanup := startPostgres(t)
 	defer cleanup()
 
 	_, err := db.Exec(`
 		INSERT INTO users (id, name) VALUES (1, 'Solo');
-		INSERT INTO meal_periods (id) VALUES (1), (2);
-		INSERT INTO meal_options (id) VALUES (1), (2), (3);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 1), (2, '夕食', 2);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
 	`)
 	require.NoError(t, err)
 
@@ -339,7 +376,7 @@ func TestGetMealsNoDefaultsIntegration(t *testing.T) {
 
 	expected := `{
 		"2025-02-16": [
-			{"user_id": 1, "user_name": "Solo", "lunch": 0, "dinner": 0, "defaultLunch": 1, "defaultDinner": 1}
+			{"user_id": 1, "user_name": "Solo", "options": {"1": 0, "2": 0}, "defaults": {"1": 1, "2": 1}}
 		]
 	}`
 	assert.JSONEq(t, expected, w.Body.String())
@@ -353,8 +390,8 @@ func seedCookUsers(t *testing.T) {
 		INSERT INTO users (id, name, is_cook, is_eater) VALUES
 			(1, 'Cook', true, false),
 			(2, 'Eater', false, true);
-		INSERT INTO meal_periods (id) VALUES (1), (2);
-		INSERT INTO meal_options (id) VALUES (1), (2), (3);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 1), (2, '夕食', 2);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
 	`)
 	require.NoError(t, err)
 }
@@ -390,9 +427,9 @@ func TestGetCookSchedulesPriorityIntegration(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.JSONEq(t, `{
-		"2025-02-16": {"lunch": null, "dinner": null},
-		"2025-02-17": {"lunch": {"cook_user_id":1,"cook_user_name":"Cook"}, "dinner": null},
-		"2025-02-18": {"lunch": {"cook_user_id":1,"cook_user_name":"Cook"}, "dinner": null}
+		"2025-02-16": {"1": null, "2": null},
+		"2025-02-17": {"1": {"cook_user_id":1,"cook_user_name":"Cook"}, "2": null},
+		"2025-02-18": {"1": {"cook_user_id":1,"cook_user_name":"Cook"}, "2": null}
 	}`, w.Body.String())
 }
 
@@ -419,7 +456,7 @@ func TestGetCookSchedulesExplicitNullOverridesDefaultIntegration(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	// lunch must be null (explicit 各自), not the default Cook user
 	assert.JSONEq(t, `{
-		"2025-02-17": {"lunch": null, "dinner": null}
+		"2025-02-17": {"1": null, "2": null}
 	}`, w.Body.String())
 }
 
@@ -452,11 +489,11 @@ func TestBulkUpdateCookSchedulesIntegration(t *testing.T) {
 
 	// Step 1: assign Cook to Mon lunch
 	put(`[{"date":"2025-02-17","meal_period":1,"cook_user_id":1}]`)
-	assert.JSONEq(t, `{"2025-02-17":{"lunch":{"cook_user_id":1,"cook_user_name":"Cook"},"dinner":null}}`, get())
+	assert.JSONEq(t, `{"2025-02-17":{"1":{"cook_user_id":1,"cook_user_name":"Cook"},"2":null}}`, get())
 
 	// Step 2: overwrite Mon lunch with null (各自)
 	put(`[{"date":"2025-02-17","meal_period":1,"cook_user_id":null}]`)
-	assert.JSONEq(t, `{"2025-02-17":{"lunch":null,"dinner":null}}`, get())
+	assert.JSONEq(t, `{"2025-02-17":{"1":null,"2":null}}`, get())
 }
 
 // TestDeleteCookSchedulesIntegration verifies that DELETE removes the individual
@@ -484,7 +521,7 @@ func TestDeleteCookSchedulesIntegration(t *testing.T) {
 	}
 
 	// Before delete: explicit NULL override → lunch=null
-	assert.JSONEq(t, `{"2025-02-17":{"lunch":null,"dinner":null}}`, get())
+	assert.JSONEq(t, `{"2025-02-17":{"1":null,"2":null}}`, get())
 
 	// Delete the override
 	w := httptest.NewRecorder()
@@ -495,7 +532,7 @@ func TestDeleteCookSchedulesIntegration(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	// After delete: falls back to weekday default → lunch=Cook
-	assert.JSONEq(t, `{"2025-02-17":{"lunch":{"cook_user_id":1,"cook_user_name":"Cook"},"dinner":null}}`, get())
+	assert.JSONEq(t, `{"2025-02-17":{"1":{"cook_user_id":1,"cook_user_name":"Cook"},"2":null}}`, get())
 }
 
 // TestGetCookDefaultSchedulesIntegration verifies GET /api/cook-default-schedules.
@@ -523,7 +560,8 @@ func TestGetCookDefaultSchedulesIntegration(t *testing.T) {
 	]`, w.Body.String())
 }
 
-// TestUpdateCookDefaultSchedulesIntegration verifies upsert of weekday defaults.
+// TestUpdateCookDefaultSchedulesIntegration verifies upsert of weekday defaults and
+// that each change shows up in GET /api/history for that weekday.
 func TestUpdateCookDefaultSchedulesIntegration(t *testing.T) {
 	cleanup := startPostgres(t)
 	defer cleanup()
@@ -553,7 +591,1151 @@ func TestUpdateCookDefaultSchedulesIntegration(t *testing.T) {
 	put(`[{"day_of_week":1,"meal_period":1,"cook_user_id":1}]`)
 	assert.JSONEq(t, `[{"day_of_week":1,"meal_period":1,"cook_user_id":1,"cook_user_name":"Cook"}]`, get())
 
-	// Overwrite Mon lunch to null (各自)
+	// Overwrite Mon lunch to null (各自); repeating it changes nothing and logs nothing.
+	put(`[{"day_of_week":1,"meal_period":1,"cook_user_id":null}]`)
 	put(`[{"day_of_week":1,"meal_period":1,"cook_user_id":null}]`)
 	assert.JSONEq(t, `[{"day_of_week":1,"meal_period":1,"cook_user_id":null,"cook_user_name":null}]`, get())
+
+	monday := time.Now()
+	for monday.Weekday() != time.Monday {
+		monday = monday.AddDate(0, 0, 1)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/history?days=1&date="+monday.Format("2006-01-02"), nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var h History
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &h))
+	require.Len(t, h.CookDefaultSchedules, 2)
+	assert.Equal(t, 1, *h.CookDefaultSchedules[0].OldCookUserID)
+	assert.Nil(t, h.CookDefaultSchedules[0].NewCookUserID)
+	assert.Nil(t, h.CookDefaultSchedules[1].OldCookUserID)
+	assert.Equal(t, "Cook", *h.CookDefaultSchedules[1].NewCookUserName)
+	assert.Equal(t, "PUT /api/cook-default-schedules", h.CookDefaultSchedules[1].Source)
+}
+
+// TestUserLifecycleIntegration verifies create → deactivate → reactivate:
+//   - POST with defaults seeds all 7 weekdays, so getMeals returns them
+//   - deactivation hides the user from getMeals but keeps their meals rows
+//   - PATCH active=true brings the user (and the kept meals) back
+func TestUserLifecycleIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	// Hanako gets id 1, so act as another admin: nobody may deactivate themselves.
+	asCaller(t, User{ID: 99, Name: "Admin", IsAdmin: true, Active: true})
+
+	_, err := db.Exec(`
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 1), (2, '夕食', 2);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
+	`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/users", `{"name":"Hanako","defaults":{"1":3,"2":2}}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":1,"name":"Hanako","is_cook":false,"is_eater":true,"display_order":1,"active":true,"is_admin":false}`, w.Body.String())
+
+	w = do("PUT", "/api/meals/bulk-update", `[{"user_id":1,"date":"2025-02-19","options":{"2":1}}]`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", "/api/meals?date=2025-02-19&days=1", "")
+	assert.JSONEq(t, `{
+		"2025-02-19": [
+			{"user_id":1,"user_name":"Hanako","options":{"1":0,"2":1},"defaults":{"1":3,"2":2}}
+		]
+	}`, w.Body.String())
+
+	w = do("DELETE", "/api/users/1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", "/api/meals?date=2025-02-19&days=1", "")
+	assert.JSONEq(t, `{}`, w.Body.String())
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM meals WHERE user_id = 1").Scan(&n))
+	assert.Equal(t, 1, n)
+
+	w = do("GET", "/api/users?include_inactive=true", "")
+	assert.JSONEq(t, `[{"id":1,"name":"Hanako","is_cook":false,"is_eater":true,"display_order":1,"active":false,"is_admin":false}]`, w.Body.String())
+
+	w = do("PATCH", "/api/users/1", `{"active":true}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", "/api/meals?date=2025-02-19&days=1", "")
+	assert.Contains(t, w.Body.String(), `"options":{"1":0,"2":1}`)
+}
+
+// TestMealOptionsIntegration verifies that a new option added via the API can be
+// used immediately by bulk-update, and is rejected again once retired.
+func TestMealOptionsIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+
+	_, err := db.Exec(`
+		INSERT INTO users (id, name) VALUES (1, 'John');
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 1), (2, '夕食', 2);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
+		SELECT setval(pg_get_serial_sequence('meal_options', 'id'), 3);
+	`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/meal-options", `{"label":"外食","color":"#bbdefb"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":4,"label":"外食","sort_order":1,"color":"#bbdefb","eats_at_home":false,"is_skip":false,"is_bento":false,"active":true}`, w.Body.String())
+
+	w = do("PUT", "/api/meals/bulk-update", `[{"user_id":1,"date":"2025-02-16","options":{"2":4}}]`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do("PATCH", "/api/meal-options/4", `{"active":false}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do("PUT", "/api/meals/bulk-update", `[{"user_id":1,"date":"2025-02-16","options":{"2":4}}]`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = do("GET", "/api/meal-options", "")
+	assert.NotContains(t, w.Body.String(), "外食")
+}
+
+// TestMealPeriodsIntegration verifies that a period added via the API shows up in
+// getMeals and getCookSchedules, and disappears from both once retired.
+func TestMealPeriodsIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+
+	_, err := db.Exec(`
+		INSERT INTO users (id, name, is_cook) VALUES (1, 'John', true);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 2), (2, '夕食', 3);
+		SELECT setval(pg_get_serial_sequence('meal_periods', 'id'), 2);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
+	`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/meal-periods", `{"name":"朝食","sort_order":1,"cutoff_time":"06:30"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":3,"name":"朝食","sort_order":1,"cutoff_time":"06:30","cutoff_mode":"notify","packs_bento":false,"active":true}`, w.Body.String())
+
+	w = do("PUT", "/api/meals/bulk-update", `[{"user_id":1,"date":"2025-02-16","options":{"3":2}}]`)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do("PUT", "/api/cook-schedules", `[{"date":"2025-02-16","meal_period":3,"cook_user_id":1}]`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", "/api/meals?date=2025-02-16&days=1", "")
+	assert.JSONEq(t, `{
+		"2025-02-16": [
+			{"user_id":1,"user_name":"John","options":{"1":0,"2":0,"3":2},"defaults":{"1":1,"2":1,"3":1}}
+		]
+	}`, w.Body.String())
+	w = do("GET", "/api/cook-schedules?date=2025-02-16&days=1", "")
+	assert.JSONEq(t, `{"2025-02-16":{"1":null,"2":null,"3":{"cook_user_id":1,"cook_user_name":"John"}}}`, w.Body.String())
+
+	w = do("PATCH", "/api/meal-periods/3", `{"active":false}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", "/api/meals?date=2025-02-16&days=1", "")
+	assert.NotContains(t, w.Body.String(), `"3":`)
+	w = do("GET", "/api/cook-schedules?date=2025-02-16&days=1", "")
+	assert.JSONEq(t, `{"2025-02-16":{"1":null,"2":null}}`, w.Body.String())
+}
+
+// TestAuthIntegration verifies the session lifecycle against a real PostgreSQL instance:
+//   - in setup mode the first login stores its PIN and returns a session cookie
+//   - the cookie resolves to the member via the sessions table
+//   - a wrong PIN is rejected once credentials exist, and repeated failures lock the name out
+//   - logout deletes the session so the cookie stops working
+func TestAuthIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+
+	_, err := db.Exec(`INSERT INTO users (id, name) VALUES (1, 'Taro');`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+
+	do := func(method, path, body string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		for _, ck := range cookies {
+			req.AddCookie(ck)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/auth/login", `{"name":"Taro","pin":"1234"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	session := cookies[0]
+
+	w = do("GET", "/api/auth/me", "", session)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Taro"`)
+
+	t.Cleanup(func() { logins = newLoginThrottle() })
+	for i := 0; i < loginFreeAttempts+1; i++ {
+		w = do("POST", "/api/auth/login", `{"name":"Taro","pin":"9999"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+	w = do("POST", "/api/auth/login", `{"name":"Taro","pin":"1234"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "locked out even with the right PIN")
+
+	w = do("POST", "/api/auth/logout", "", session)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", "/api/auth/me", "", session)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// TestAuthorizationIntegration verifies that the cook resolved from cook_schedules
+// may edit other members' meals on that day only, and that a rejected request
+// writes nothing.
+func TestAuthorizationIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+
+	_, err := db.Exec(`
+		INSERT INTO users (id, name, is_cook) VALUES (1, 'John', true), (2, 'Paul', false);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 1), (2, '夕食', 2);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
+		INSERT INTO cook_schedules (date, meal_period, cook_user_id) VALUES ('2025-02-16', 2, 1);
+	`)
+	require.NoError(t, err)
+	asCaller(t, User{ID: 1, Name: "John", IsCook: true, IsEater: true, Active: true})
+
+	r := setupRouter()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("PUT", "/api/meals/bulk-update", `[{"user_id":2,"date":"2025-02-16","options":{"2":1}}]`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do("PUT", "/api/meals/bulk-update", `[
+		{"user_id":1,"date":"2025-02-17","options":{"1":3}},
+		{"user_id":2,"date":"2025-02-17","options":{"1":3}}
+	]`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"index":1`)
+
+	var n int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM meals WHERE date = '2025-02-17'`).Scan(&n))
+	assert.Equal(t, 0, n)
+}
+
+// TestHistoryIntegration verifies that meal and cook assignment writes are logged
+// with their previous values, and that rewriting the same value logs nothing.
+func TestHistoryIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+
+	_, err := db.Exec(`
+		INSERT INTO users (id, name, is_cook) VALUES (1, 'John', true), (2, 'Paul', false);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 1), (2, '夕食', 2);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
+	`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, option := range []int{2, 1, 1} {
+		w := do("PUT", "/api/meals/bulk-update", fmt.Sprintf(`[{"user_id":2,"date":"2025-02-16","options":{"2":%d}}]`, option))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	w := do("PUT", "/api/cook-schedules", `[{"date":"2025-02-16","meal_period":2,"cook_user_id":1}]`)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do("DELETE", "/api/cook-schedules", `[{"date":"2025-02-16","meal_period":2}]`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", "/api/history?date=2025-02-16&days=1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var h History
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &h))
+
+	require.Len(t, h.Meals, 2)
+	assert.Equal(t, 2, *h.Meals[0].OldOption)
+	assert.Equal(t, 1, h.Meals[0].NewOption)
+	assert.Nil(t, h.Meals[1].OldOption)
+	assert.Equal(t, "John", *h.Meals[0].ActorName)
+	assert.Equal(t, "PUT /api/meals/bulk-update", h.Meals[0].Source)
+
+	require.Len(t, h.CookSchedules, 2)
+	assert.Equal(t, "DELETE /api/cook-schedules", h.CookSchedules[0].Source)
+	assert.True(t, h.CookSchedules[0].OldOverride)
+	assert.False(t, h.CookSchedules[0].NewOverride)
+	assert.False(t, h.CookSchedules[1].OldOverride)
+	assert.Equal(t, 1, *h.CookSchedules[1].NewCookUserID)
+}
+
+// TestCutoffIntegration verifies that a reject-mode cutoff blocks a late change
+// without writing anything, and that an admin override goes through and is
+// recorded as such in meal_changes.
+func TestCutoffIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+
+	_, err := db.Exec(`
+		INSERT INTO users (id, name) VALUES (1, 'John');
+		INSERT INTO meal_periods (id, name, sort_order, cutoff_time, cutoff_mode) VALUES (1, '夕食', 1, '15:00', 'reject');
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true);
+	`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `[{"user_id":1,"date":"2025-02-16","options":{"1":2}}]`
+	w := do("PUT", "/api/meals/bulk-update", body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var n int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM meals`).Scan(&n))
+	assert.Equal(t, 0, n)
+
+	w = do("PUT", "/api/meals/bulk-update?override=true", body)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"mode":"override"`)
+
+	var mode string
+	require.NoError(t, db.QueryRow(`SELECT cutoff_mode FROM meal_changes`).Scan(&mode))
+	assert.Equal(t, "override", mode)
+}
+
+// TestAcknowledgementIntegration verifies that a late change is left pending for the
+// resolved cook, re-notified after the interval, and acknowledged only once.
+func TestAcknowledgementIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+
+	today := time.Now().Format("2006-01-02")
+	_, err := db.Exec(`
+		INSERT INTO users (id, name, is_cook) VALUES (1, 'John', false), (5, 'Mother', true);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '夕食', 1);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true);
+	`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO cook_schedules (date, meal_period, cook_user_id) VALUES ($1, 1, 5)`, today)
+	require.NoError(t, err)
+
+	r := setupRouter()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Dinner has no cutoff (notify); a change within the lead time still waits for the cook.
+	w := do("PUT", "/api/meals/bulk-update", fmt.Sprintf(`[{"user_id":1,"date":%q,"options":{"1":1}}]`, today))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	asCaller(t, User{ID: 5, Name: "Mother", IsCook: true, IsEater: true, Active: true})
+	w = do("GET", "/api/acknowledgements/pending", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var pending []Acknowledgement
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pending))
+	require.Len(t, pending, 1)
+	assert.Equal(t, 5, pending[0].CookUserID)
+	assert.Equal(t, 1, pending[0].Change.NewOption)
+
+	events, err := renotifyPendingAcknowledgements(30 * time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, events, "notified just now")
+	_, err = db.Exec(`UPDATE acknowledgements SET notified_at = now() - interval '1 hour'`)
+	require.NoError(t, err)
+	events, err = renotifyPendingAcknowledgements(30 * time.Minute)
+	require.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, []Recipient{{UserID: 5, Name: "Mother"}}, events[0].Recipients)
+	}
+
+	path := fmt.Sprintf("/api/acknowledgements/%d", pending[0].ID)
+	assert.Equal(t, http.StatusOK, do("POST", path, "").Code)
+	assert.Equal(t, http.StatusConflict, do("POST", path, "").Code)
+	w = do("GET", "/api/acknowledgements/pending", "")
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
+
+// TestGetSummaryIntegration verifies the summary query against real PostgreSQL: explicit
+// meals win over weekday defaults, every active option is listed, names follow display
+// order, the cook resolves exactly as GET /api/cook-schedules does, guests are
+// added to the home eaters of their period and the dietary needs of home eaters
+// (vegetarian only on their weekdays) are attached.
+func TestGetSummaryIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+	_, err := db.Exec(`
+		INSERT INTO cook_default_schedules (day_of_week, meal_period, cook_user_id) VALUES (1, 2, 1);
+		INSERT INTO meal_guests (date, meal_period, count, name, host_user_id, dietary_notes) VALUES
+			('2025-02-17', 2, 1, 'Grandma', 1, 'vegetarian'), ('2025-02-17', 2, 2, NULL, 2, NULL), ('2025-02-18', 2, 5, NULL, 2, NULL);
+		INSERT INTO user_dietary (user_id, allergies, vegetarian_days) VALUES (1, '{shrimp}', '{}'), (2, '{peanuts}', '{1,3}');
+	`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/summary?date=2025-02-17&days=1", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"2025-02-17": {
+			"1": {"cook": null, "eats_at_home": 1, "guests": 0, "options": [
+				{"meal_option":1,"label":"なし","count":0,"names":[]},
+				{"meal_option":2,"label":"家","count":1,"names":["Paul"]},
+				{"meal_option":3,"label":"弁当","count":1,"names":["John"]}
+			], "dietary": {"allergies": ["peanuts"], "vegetarian": true, "eaters": [
+				{"user_id":2,"name":"Paul","allergies":["peanuts"],"dislikes":[],"vegetarian":true,"notes":null}
+			]}},
+			"2": {"cook": {"cook_user_id":1,"cook_user_name":"John"}, "eats_at_home": 4, "guests": 3, "options": [
+				{"meal_option":1,"label":"なし","count":1,"names":["John"]},
+				{"meal_option":2,"label":"家","count":1,"names":["Paul"]},
+				{"meal_option":3,"label":"弁当","count":0,"names":[]}
+			], "dietary": {"allergies": ["peanuts"], "vegetarian": true, "eaters": [
+				{"user_id":2,"name":"Paul","allergies":["peanuts"],"dislikes":[],"vegetarian":true,"notes":null},
+				{"user_id":null,"name":"Grandma","allergies":[],"dislikes":[],"vegetarian":false,"notes":"vegetarian"}
+			]}}
+		}
+	}`, w.Body.String())
+}
+
+// TestCalendarIntegration verifies the feed queries against real PostgreSQL: a token
+// issued through the API opens both feeds, cook overrides win over weekday defaults,
+// and a reissued token revokes the old URL.
+func TestCalendarIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+
+	today := time.Now()
+	tomorrow := today.AddDate(0, 0, 1)
+	// Parameters need one statement per Exec.
+	_, err := db.Exec(`
+		INSERT INTO users (id, name, is_cook) VALUES (1, 'John', true), (2, 'Paul', true);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '夕食', 1);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true), (3, '弁当', false);
+	`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO cook_default_schedules (day_of_week, meal_period, cook_user_id) VALUES ($1, 1, 1), ($2, 1, 1)`,
+		int(today.Weekday()), int(tomorrow.Weekday()))
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO cook_schedules (date, meal_period, cook_user_id) VALUES ($1, 1, 2)`, tomorrow.Format("2006-01-02"))
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO meals (user_id, date, meal_period, meal_option) VALUES (1, $1, 1, 3)`, today.Format("2006-01-02"))
+	require.NoError(t, err)
+
+	r := setupRouter()
+	do := func(method, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/users/1/calendar-token")
+	require.Equal(t, http.StatusCreated, w.Code)
+	var urls struct {
+		CookURL  string `json:"cook_url"`
+		MealsURL string `json:"meals_url"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &urls))
+
+	w = do("GET", urls.CookURL)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf("UID:cook-1-%s-1@meal-schedule", today.Format("2006-01-02")))
+	assert.NotContains(t, w.Body.String(), fmt.Sprintf("cook-1-%s-1", tomorrow.Format("2006-01-02")), "Paul overrides tomorrow")
+
+	w = do("GET", urls.MealsURL)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "SUMMARY:夕食: 弁当")
+	assert.Contains(t, w.Body.String(), "SUMMARY:夕食: なし")
+
+	require.Equal(t, http.StatusCreated, do("POST", "/api/users/1/calendar-token").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", urls.CookURL).Code)
+}
+
+// TestExportMealsIntegration verifies the CSV export against real PostgreSQL, including
+// which values came from meals and which from weekday defaults.
+func TestExportMealsIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/export/meals?date=2025-02-17&days=1", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "\ufeff"+
+		"date,user_id,user_name,昼食,昼食_source,夕食,夕食_source,home_meals\n"+
+		"2025-02-17,1,John,弁当,meals,なし,meals,0\n"+
+		"2025-02-17,2,Paul,家,user_defaults,家,meals,2\n", w.Body.String())
+}
+
+// TestImportMealsIntegration verifies an exported CSV imports back, and that a commit
+// writes through bulk-update so the audit log records it.
+func TestImportMealsIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/export/meals?date=2025-02-17&days=1", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	exported := w.Body.String()
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/import/meals", strings.NewReader(exported))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"valid":2,"invalid":0`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/import/meals?mode=commit",
+		strings.NewReader(strings.Replace(exported, "2025-02-17,1,John,弁当,meals,なし", "2025-02-17,1,John,弁当,meals,家", 1)))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var option, changes int
+	require.NoError(t, db.QueryRow("SELECT meal_option FROM meals WHERE user_id = 1 AND date = '2025-02-17' AND meal_period = 2").Scan(&option))
+	assert.Equal(t, 2, option)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM meal_changes WHERE user_id = 1 AND date = '2025-02-17' AND meal_period = 2").Scan(&changes))
+	assert.Equal(t, 1, changes)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/import/user-defaults?mode=commit", strings.NewReader("user,weekday,夕食\nPaul,月,弁当\n"))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, db.QueryRow("SELECT meal_option FROM user_defaults WHERE user_id = 2 AND day_of_week = 1 AND meal_period = 2").Scan(&option))
+	assert.Equal(t, 3, option)
+}
+
+// TestRevokeCookRoleIntegration verifies revoking is_cook is refused while the member
+// still cooks from today on, and that reassign to 各自 moves everything atomically.
+func TestRevokeCookRoleIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedCookUsers(t)
+
+	future := time.Now().AddDate(0, 0, 3).Format("2006-01-02")
+	_, err := db.Exec(`INSERT INTO cook_schedules (date, meal_period, cook_user_id) VALUES ('2025-02-17', 1, 1), ($1, 2, 1)`, future)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO cook_default_schedules (day_of_week, meal_period, cook_user_id) VALUES (1, 1, 1)`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+	do := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/users/1/roles", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(`{"is_cook":false,"is_eater":true}`)
+	require.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"cook_schedules":[{"date":"`+future+`","meal_period":2}]`)
+	assert.Contains(t, w.Body.String(), `"cook_default_schedules":[{"day_of_week":1,"meal_period":1}]`)
+	var isCook bool
+	require.NoError(t, db.QueryRow("SELECT is_cook FROM users WHERE id = 1").Scan(&isCook))
+	assert.True(t, isCook)
+
+	w = do(`{"is_cook":false,"is_eater":true,"reassign":{"cook_user_id":null}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var remaining int
+	require.NoError(t, db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM cook_schedules WHERE cook_user_id = 1 AND date >= CURRENT_DATE) +
+		(SELECT COUNT(*) FROM cook_default_schedules WHERE cook_user_id = 1)`).Scan(&remaining))
+	assert.Equal(t, 0, remaining)
+	// The past override is history and stays.
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM cook_schedules WHERE cook_user_id = 1").Scan(&remaining))
+	assert.Equal(t, 1, remaining)
+	// Both moves are in the audit logs.
+	var logged int
+	require.NoError(t, db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM cook_schedule_changes WHERE old_cook_user_id = 1 AND new_cook_user_id IS NULL) +
+		(SELECT COUNT(*) FROM cook_default_schedule_changes WHERE old_cook_user_id = 1 AND new_cook_user_id IS NULL)`).Scan(&logged))
+	assert.Equal(t, 2, logged)
+}
+
+// TestGenerateCookSchedulesIntegration verifies a committed rotation resolves through
+// GET /api/cook-schedules and keeps existing overrides unless replace_overrides is set.
+func TestGenerateCookSchedulesIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	_, err := db.Exec(`
+		INSERT INTO users (id, name, is_cook) VALUES (1, 'John', true), (2, 'Mother', true);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '昼食', 1), (2, '夕食', 2);
+		INSERT INTO cook_schedules (date, meal_period, cook_user_id) VALUES ('2025-02-17', 2, NULL);
+	`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/cook-schedules/generate?mode=commit",
+		`{"from":"2025-02-17","to":"2025-02-18","meal_periods":[2],"cooks":[{"user_id":1},{"user_id":2}]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("GET", "/api/cook-schedules?date=2025-02-17&days=2", "")
+	assert.JSONEq(t, `{
+		"2025-02-17": {"1": null, "2": null},
+		"2025-02-18": {"1": null, "2": {"cook_user_id":1,"cook_user_name":"John"}}
+	}`, w.Body.String())
+
+	w = do("POST", "/api/cook-schedules/generate?mode=commit",
+		`{"from":"2025-02-17","to":"2025-02-18","meal_periods":[2],"cooks":[{"user_id":1},{"user_id":2}],"replace_overrides":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("GET", "/api/cook-schedules?date=2025-02-17&days=2", "")
+	assert.JSONEq(t, `{
+		"2025-02-17": {"1": null, "2": {"cook_user_id":1,"cook_user_name":"John"}},
+		"2025-02-18": {"1": null, "2": {"cook_user_id":2,"cook_user_name":"Mother"}}
+	}`, w.Body.String())
+
+	// Mother cooks Wednesday dinners by default but is away on the 19th, and John is
+	// not taking part: the slot is written as 各自 rather than left to her default.
+	_, err = db.Exec(`INSERT INTO cook_default_schedules (day_of_week, meal_period, cook_user_id) VALUES (3, 2, 2)`)
+	require.NoError(t, err)
+	w = do("POST", "/api/cook-schedules/generate?mode=commit",
+		`{"from":"2025-02-19","to":"2025-02-19","meal_periods":[2],"cooks":[{"user_id":2,"blackout_dates":["2025-02-19"]}],"replace_overrides":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("GET", "/api/cook-schedules?date=2025-02-19&days=1", "")
+	assert.JSONEq(t, `{"2025-02-19": {"1": null, "2": null}}`, w.Body.String())
+}
+
+// TestGetCookStatsIntegration verifies cooks are resolved as GET /api/cook-schedules
+// does, home eaters as GET /api/meals does, and only late changes are counted.
+func TestGetCookStatsIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+	_, err := db.Exec(`
+		INSERT INTO cook_default_schedules (day_of_week, meal_period, cook_user_id) VALUES (1, 1, 1), (1, 2, 1);
+		INSERT INTO cook_schedules (date, meal_period, cook_user_id) VALUES ('2025-02-16', 2, 2);
+		INSERT INTO meal_changes (user_id, date, meal_period, old_option, new_option, actor_id, source, changed_at) VALUES
+			(2, '2025-02-17', 2, 1, 2, 2, 'test', '2025-02-16 12:00+00'),
+			(2, '2025-02-17', 2, NULL, 1, 2, 'test', '2025-02-10 12:00+00');
+	`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/stats/cooks?from=2025-02-16&to=2025-02-17", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"cook_user_id":1,"cook_user_name":"John","periods":{"1":1,"2":1},"total":2,"people_cooked_for":2,"late_changes":1},
+		{"cook_user_id":2,"cook_user_name":"Paul","periods":{"2":1},"total":1,"people_cooked_for":0,"late_changes":0}
+	]`, w.Body.String())
+}
+
+// TestGetMemberStatsIntegration verifies choices are resolved as GET /api/meals does,
+// deviations compare explicit meals with the weekday default, and only late changes
+// are counted.
+func TestGetMemberStatsIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+	_, err := db.Exec(`
+		INSERT INTO meal_changes (user_id, date, meal_period, old_option, new_option, actor_id, source, changed_at) VALUES
+			(2, '2025-02-17', 2, 1, 2, 2, 'test', '2025-02-16 12:00+00'),
+			(2, '2025-02-17', 2, NULL, 1, 2, 'test', '2025-02-10 12:00+00');
+	`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/stats/members?from=2025-02-16&to=2025-02-17", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"user_id":1,"user_name":"John","options":{"1":3,"3":1},"home_meals":0,"skipped":3,"deviations":4,"late_changes":0},
+		{"user_id":2,"user_name":"Paul","options":{"1":2,"2":2},"home_meals":2,"skipped":2,"deviations":2,"late_changes":1}
+	]`, w.Body.String())
+}
+
+// TestMealGuestsIntegration verifies guests created through the API are listed with
+// their host, folded into the host's row of GET /api/meals, and can be changed and
+// removed.
+func TestMealGuestsIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+
+	r := setupRouter()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/meal-guests", `{"date":"2025-02-16","meal_period":2,"count":2,"name":"Grandma","host_user_id":2,"dietary_notes":"no shellfish"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created MealGuest
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "Paul", created.HostUserName)
+
+	w = do("GET", "/api/meals?date=2025-02-16&days=1", "")
+	assert.JSONEq(t, `{"2025-02-16": [
+		{"user_id":1,"user_name":"John","options":{"1":1,"2":1},"defaults":{"1":2,"2":2}},
+		{"user_id":2,"user_name":"Paul","options":{"1":1,"2":1},"defaults":{"1":2,"2":2},"guests":{"2":2}}
+	]}`, w.Body.String())
+
+	path := fmt.Sprintf("/api/meal-guests/%d", created.ID)
+	w = do("PATCH", path, `{"count":3,"name":""}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("GET", "/api/meal-guests?date=2025-02-16&days=1", "")
+	assert.JSONEq(t, fmt.Sprintf(`[{"id":%d,"date":"2025-02-16","meal_period":2,"count":3,"name":null,
+		"host_user_id":2,"host_user_name":"Paul","dietary_notes":"no shellfish"}]`, created.ID), w.Body.String())
+
+	assert.Equal(t, http.StatusOK, do("DELETE", path, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", path, "").Code)
+
+	// A host with guests still to come cannot stop eating until the guests move.
+	next := time.Now().AddDate(0, 0, 7).Format("2006-01-02")
+	w = do("POST", "/api/meal-guests", fmt.Sprintf(`{"date":%q,"meal_period":2,"count":1,"host_user_id":2}`, next))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	w = do("PUT", "/api/users/2/roles", `{"is_cook":false,"is_eater":false}`)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"meal_guests"`)
+	w = do("DELETE", "/api/users/2", "")
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	w = do("PATCH", fmt.Sprintf("/api/meal-guests/%d", created.ID), `{"host_user_id":1}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("PUT", "/api/users/2/roles", `{"is_cook":false,"is_eater":false}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+// TestUserDietaryIntegration verifies a profile written through the API reads back,
+// and that a member without one gets empty values.
+func TestUserDietaryIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+
+	r := setupRouter()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("PUT", "/api/users/2/dietary", `{"allergies":["peanuts"],"dislikes":["celery"],"vegetarian_days":[3,1],"notes":"EpiPen in the kitchen"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("GET", "/api/users/2/dietary", "")
+	assert.JSONEq(t, `{"user_id":2,"allergies":["peanuts"],"dislikes":["celery"],"vegetarian_days":[1,3],"notes":"EpiPen in the kitchen"}`, w.Body.String())
+	w = do("GET", "/api/users/1/dietary", "")
+	assert.JSONEq(t, `{"user_id":1,"allergies":[],"dislikes":[],"vegetarian_days":[],"notes":null}`, w.Body.String())
+}
+
+// TestMenusIntegration verifies menus written through the API come back next to the
+// cook resolved as GET /api/cook-schedules does, and that an emptied menu is removed.
+func TestMenusIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+	_, err := db.Exec(`INSERT INTO cook_default_schedules (day_of_week, meal_period, cook_user_id) VALUES (1, 2, 1)`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("PUT", "/api/menus", `[
+		{"date":"2025-02-17","meal_period":2,"dishes":["curry","salad"],"recipe_url":"https://example.com/curry",
+		 "ingredients":[{"dish":"curry","name":"pork","quantity":150,"unit":"g"}]},
+		{"date":"2025-02-16","meal_period":2,"dishes":["pizza"],
+		 "ingredients":[{"dish":"pizza","name":"cheese","quantity":50,"unit":"g"}]}
+	]`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("PUT", "/api/menus", `[{"date":"2025-02-16","meal_period":2,"dishes":[]}]`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = do("GET", "/api/menus?date=2025-02-16&days=2", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"2025-02-16": {
+			"1": {"cook": null, "dishes": [], "notes": null, "recipe_url": null, "ingredients": []},
+			"2": {"cook": null, "dishes": [], "notes": null, "recipe_url": null, "ingredients": []}
+		},
+		"2025-02-17": {
+			"1": {"cook": null, "dishes": [], "notes": null, "recipe_url": null, "ingredients": []},
+			"2": {"cook": {"cook_user_id":1,"cook_user_name":"John"}, "dishes": ["curry","salad"], "notes": null, "recipe_url": "https://example.com/curry",
+				"ingredients": [{"dish":"curry","name":"pork","quantity":150,"unit":"g"}]}
+		}
+	}`, w.Body.String())
+}
+
+// TestShoppingListIntegration verifies the servings come from the resolved choices plus
+// guests, and that menu_ingredients follow their menu when it is removed.
+func TestShoppingListIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+	// Mon 2025-02-17 dinner: Paul eats at home, with two guests. Tue: nobody does.
+	_, err := db.Exec(`
+		INSERT INTO meal_guests (date, meal_period, count, host_user_id) VALUES ('2025-02-17', 2, 2, 2);
+		INSERT INTO menus (date, meal_period, dishes) VALUES
+			('2025-02-16', 2, '{pizza}'), ('2025-02-17', 2, '{curry}'), ('2025-02-18', 2, '{curry}');
+		INSERT INTO menu_ingredients (date, meal_period, dish, name, quantity, unit) VALUES
+			('2025-02-16', 2, 'pizza', 'cheese', 50, 'g'),
+			('2025-02-17', 2, 'curry', 'pork', 150, 'g'),
+			('2025-02-17', 2, 'curry', 'milk', 1, '大さじ'),
+			('2025-02-18', 2, 'curry', 'pork', 150, 'g');
+		DELETE FROM menus WHERE date = '2025-02-16';
+	`)
+	require.NoError(t, err)
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/shopping-list?date=2025-02-16&days=3", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"from":"2025-02-16","to":"2025-02-18",
+		"meals":[
+			{"date":"2025-02-17","meal_period":2,"servings":3,"dishes":["curry"]},
+			{"date":"2025-02-18","meal_period":2,"servings":0,"dishes":["curry"]}
+		],
+		"items":[
+			{"name":"pork","quantity":450,"unit":"g","dishes":["curry"]},
+			{"name":"milk","quantity":45,"unit":"ml","dishes":["curry"]}
+		]}`, w.Body.String())
+}
+
+// TestRecipesIntegration verifies the tag and text search, the 弁当 suggestions from
+// resolved choices, and putting a recipe on a menu.
+func TestRecipesIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+
+	r := setupRouter()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/recipes", `{"title":"tamagoyaki","servings":2,"tags":["弁当向け"],
+		"ingredients":[{"name":"egg","quantity":3,"unit":"個"}],"steps":["beat 100% of the eggs"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = do("POST", "/api/recipes", `{"title":"curry","servings":4,"tags":["作り置き"],
+		"ingredients":[{"name":"pork","quantity":400,"unit":"g"}]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	for _, tc := range []struct{ query, titles string }{
+		{"", `["curry","tamagoyaki"]`},
+		{"tag=弁当向け", `["tamagoyaki"]`},
+		{"q=PORK", `["curry"]`},
+		{"q=100%25", `["tamagoyaki"]`},
+		{"q=_", `[]`},
+	} {
+		w = do("GET", "/api/recipes?"+tc.query, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var recipes []Recipe
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &recipes))
+		titles := []string{}
+		for _, rc := range recipes {
+			titles = append(titles, rc.Title)
+		}
+		b, _ := json.Marshal(titles)
+		assert.JSONEq(t, tc.titles, string(b), tc.query)
+	}
+
+	// Mon 2025-02-17: John takes a 弁当 for lunch; nobody does on Sunday.
+	w = do("GET", "/api/recipes/suggestions?date=2025-02-16&days=2", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var suggestions []RecipeSuggestion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &suggestions))
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "2025-02-17", suggestions[0].Date)
+	assert.Equal(t, []string{"John"}, suggestions[0].Names)
+	require.Len(t, suggestions[0].Recipes, 1)
+	tamagoyaki := suggestions[0].Recipes[0]
+	assert.Equal(t, []RecipeIngredient{{Name: "egg", Quantity: 3, Unit: "個"}}, tamagoyaki.Ingredients)
+
+	w = do("PUT", "/api/menus", fmt.Sprintf(`[{"date":"2025-02-17","meal_period":1,"recipe_ids":[%d]}]`, tamagoyaki.ID))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("DELETE", fmt.Sprintf("/api/recipes/%d", tamagoyaki.ID), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do("GET", "/api/menus?date=2025-02-17&days=1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"2025-02-17": {
+		"1": {"cook": null, "dishes": ["tamagoyaki"], "notes": null, "recipe_url": null,
+			"ingredients": [{"dish":"tamagoyaki","name":"egg","quantity":1.5,"unit":"個"}]},
+		"2": {"cook": null, "dishes": [], "notes": null, "recipe_url": null, "ingredients": []}
+	}}`, w.Body.String())
+}
+
+// TestBentoIntegration verifies the 弁当 list uses resolved choices and bento_note, and
+// that the lunch cook comes from the default schedule unless overridden.
+func TestBentoIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedGetMeals(t)
+	// Mon 2025-02-17: John takes a 弁当 for lunch; Paul cooks Monday lunches by default.
+	_, err := db.Exec(`
+		UPDATE users SET bento_note = 'no rice' WHERE id = 1;
+		INSERT INTO cook_default_schedules (day_of_week, meal_period, cook_user_id) VALUES (1, 1, 2);
+	`)
+	require.NoError(t, err)
+
+	list, err := loadBentoList("2025-02-17")
+	require.NoError(t, err)
+	note := "no rice"
+	assert.Equal(t, BentoList{Date: "2025-02-17", Cook: &CookAssignment{CookUserID: 2, CookUserName: "Paul"}, Count: 1,
+		Bentos: []Bento{{UserID: 1, Name: "John", MealPeriods: []int{1}, Note: &note}}}, list)
+
+	_, err = db.Exec(`INSERT INTO cook_schedules (date, meal_period, cook_user_id) VALUES ('2025-02-17', 1, NULL)`)
+	require.NoError(t, err)
+	list, err = loadBentoList("2025-02-17")
+	require.NoError(t, err)
+	assert.Nil(t, list.Cook)
+
+	// 朝食 ahead of 昼食 does not take over packing the 弁当.
+	_, err = db.Exec(`
+		DELETE FROM cook_schedules;
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (3, '朝食', 0);
+		INSERT INTO cook_default_schedules (day_of_week, meal_period, cook_user_id) VALUES (1, 3, 1);
+	`)
+	require.NoError(t, err)
+	list, err = loadBentoList("2025-02-17")
+	require.NoError(t, err)
+	assert.Equal(t, &CookAssignment{CookUserID: 2, CookUserName: "Paul"}, list.Cook)
+
+	list, err = loadBentoList("2025-02-16")
+	require.NoError(t, err)
+	assert.Equal(t, 0, list.Count)
+	assert.Equal(t, "", bentoReminderMessage(list))
+}
+
+// TestNotificationChannelsIntegration verifies a webhook channel added through the API
+// receives cook schedule changes, only when the override actually changes, and that
+// its token is kept but never returned.
+func TestNotificationChannelsIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	seedCookUsers(t)
+	t.Setenv("SLACK_WEBHOOK_URL", "")
+	defer dispatch.set(nil, nil)
+
+	type delivery struct {
+		auth string
+		body map[string]interface{}
+	}
+	deliveries := make(chan delivery, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		deliveries <- delivery{r.Header.Get("Authorization"), body}
+	}))
+	defer srv.Close()
+
+	r := setupRouter()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/notification-channels", fmt.Sprintf(
+		`{"kind":"webhook","name":"stand-in","url":%q,"token":"secret","events":["cook_schedule_change"]}`, srv.URL))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var ch NotificationChannel
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ch))
+	assert.True(t, ch.TokenSet)
+	assert.NotContains(t, w.Body.String(), "secret")
+
+	for i := 0; i < 2; i++ {
+		w = do("PUT", "/api/cook-schedules", `[{"date":"2025-02-17","meal_period":1,"cook_user_id":1}]`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	select {
+	case d := <-deliveries:
+		assert.Equal(t, "Bearer secret", d.auth)
+		assert.Equal(t, map[string]interface{}{"event": "cook_schedule_change",
+			"messages": []interface{}{"John さんが 2025-02-17 の昼食の料理担当を「Cook さん」に変更しました"}}, d.body)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification was sent")
+	}
+
+	// Omitting the token on PUT keeps it.
+	w = do("PUT", fmt.Sprintf("/api/notification-channels/%d", ch.ID), fmt.Sprintf(
+		`{"kind":"webhook","name":"renamed","url":%q,"events":["cook_schedule_change"]}`, srv.URL))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"token_set":true`)
+	w = do("POST", fmt.Sprintf("/api/notification-channels/%d/test", ch.ID), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	d := <-deliveries
+	assert.Equal(t, "Bearer secret", d.auth)
+	assert.Equal(t, "test", d.body["event"])
+
+	w = do("DELETE", fmt.Sprintf("/api/notification-channels/%d", ch.ID), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do("GET", "/api/notification-channels", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+	w = do("PUT", "/api/cook-schedules", `[{"date":"2025-02-17","meal_period":1,"cook_user_id":null}]`)
+	require.Equal(t, http.StatusOK, w.Code)
+	select {
+	case d := <-deliveries:
+		t.Fatalf("unexpected notification after delete: %v", d.body)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// TestNotificationPreferencesIntegration verifies a late change goes to the cook and
+// the member, minus whoever opted out, with the recipients in the webhook payload.
+func TestNotificationPreferencesIntegration(t *testing.T) {
+	cleanup := startPostgres(t)
+	defer cleanup()
+	t.Setenv("SLACK_WEBHOOK_URL", "")
+	defer dispatch.set(nil, nil)
+
+	today := time.Now().Format("2006-01-02")
+	_, err := db.Exec(`
+		INSERT INTO users (id, name, is_cook) VALUES (1, 'John', false), (3, 'Taro', false), (5, 'Mother', true);
+		INSERT INTO meal_periods (id, name, sort_order) VALUES (1, '夕食', 1);
+		INSERT INTO meal_options (id, label, eats_at_home) VALUES (1, 'なし', false), (2, '家', true);
+	`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO cook_schedules (date, meal_period, cook_user_id) VALUES ($1, 1, 5)`, today)
+	require.NoError(t, err)
+
+	deliveries := make(chan map[string]interface{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		deliveries <- body
+	}))
+	defer srv.Close()
+
+	r := setupRouter()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/notification-channels", fmt.Sprintf(`{"kind":"webhook","name":"stand-in","url":%q}`, srv.URL))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var ch NotificationChannel
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ch))
+
+	w = do("PUT", "/api/users/5/notification-preferences", fmt.Sprintf(
+		`{"events":["late_meal_change"],"channel_id":%d,"slack_member_id":"U0MOTHER"}`, ch.ID))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("PUT", "/api/users/3/notification-preferences", `{"events":["bento_reminder"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("GET", "/api/users/5/notification-preferences", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, fmt.Sprintf(`{"user_id":5,"events":["late_meal_change"],"channel_id":%d,"quiet_start":null,
+		"quiet_end":null,"slack_member_id":"U0MOTHER","channels":[{"id":%d,"kind":"webhook","name":"stand-in"}]}`,
+		ch.ID, ch.ID), w.Body.String())
+
+	w = do("PUT", "/api/meals/bulk-update", fmt.Sprintf(`[{"user_id":3,"date":%q,"options":{"1":1}}]`, today))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	select {
+	case body := <-deliveries:
+		assert.Equal(t, "late_meal_change", body["event"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"user_id": float64(5), "name": "Mother",
+			"slack_member_id": "U0MOTHER"}}, body["recipients"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification was sent")
+	}
+
+	// Once the cook opts out too, nobody is left to tell.
+	w = do("PUT", "/api/users/5/notification-preferences", `{"events":["ack_reminder"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("PUT", "/api/meals/bulk-update", fmt.Sprintf(`[{"user_id":3,"date":%q,"options":{"1":2}}]`, today))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	select {
+	case body := <-deliveries:
+		t.Fatalf("unexpected notification: %v", body)
+	case <-time.After(200 * time.Millisecond):
+	}
 }
@@ -1,15 +1,12 @@
 package main
 
 import (
-   "bytes"
    "database/sql"
-   "encoding/json"
    "fmt"
    "log"
    "net/http"
    "os"
    "strconv"
-   "strings"
    "time"
 
    "github.com/gin-gonic/gin"
@@ -20,10 +17,13 @@ var db *sql.DB
 
 // User represents a user with their role attributes.
 type User struct {
-	ID      int    `json:"id"`
-	Name    string `json:"name"`
-	IsCook  bool   `json:"is_cook"`
-	IsEater bool   `json:"is_eater"`
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	IsCook       bool   `json:"is_cook"`
+	IsEater      bool   `json:"is_eater"`
+	DisplayOrder int    `json:"display_order"`
+	Active       bool   `json:"active"`
+	IsAdmin      bool   `json:"is_admin"`
 }
 
 // CookAssignment represents the cook assigned to a meal period.
@@ -33,11 +33,9 @@ type CookAssignment struct {
 	CookUserName string `json:"cook_user_name"`
 }
 
-// DailyCookSchedule holds the resolved cook assignments for a single day.
-type DailyCookSchedule struct {
-	Lunch  *CookAssignment `json:"lunch"`
-	Dinner *CookAssignment `json:"dinner"`
-}
+// DailyCookSchedule holds the resolved cook assignments for a single day,
+// keyed by meal period id.
+type DailyCookSchedule map[int]*CookAssignment
 
 // CookScheduleUpdate is one element of the PUT /api/cook-schedules request body.
 type CookScheduleUpdate struct {
@@ -68,38 +66,35 @@ type CookDefaultScheduleUpdate struct {
 }
 
 // Meal represents meal information for a user on a specific date.
+// Options and Defaults are keyed by meal period id; an option of 0 means not set.
+// Guests counts the meal_guests the user hosts, only for periods that have any.
 type Meal struct {
-	UserID        int    `json:"user_id"`
-	UserName      string `json:"user_name"`
-	Lunch         int    `json:"lunch"`
-	Dinner        int    `json:"dinner"`
-	DefaultLunch  int    `json:"defaultLunch"`
-	DefaultDinner int    `json:"defaultDinner"`
+	UserID   int         `json:"user_id"`
+	UserName string      `json:"user_name"`
+	Options  map[int]int `json:"options"`
+	Defaults map[int]int `json:"defaults"`
+	Guests   map[int]int `json:"guests,omitempty"`
 }
 
 // MealUpdate represents an update for a meal record.
+// Options is keyed by meal period id; periods that are absent or 0 are left unchanged.
 type MealUpdate struct {
-	UserID   int    `json:"user_id"`
-	UserName string `json:"user_name"`
-	Date     string `json:"date"`
-	Lunch    int    `json:"lunch"`
-	Dinner   int    `json:"dinner"`
+	UserID   int         `json:"user_id"`
+	UserName string      `json:"user_name"`
+	Date     string      `json:"date"`
+	Options  map[int]int `json:"options"`
 }
 
 // UserDefault represents the default meal settings for a user for a given day of week.
+// Options is keyed by meal period id.
 type UserDefault struct {
-	UserID    int `json:"user_id"`
-	DayOfWeek int `json:"day_of_week"` // 0: Sunday, ... 6: Saturday
-	Lunch     int `json:"lunch"`
-	Dinner    int `json:"dinner"`
+	UserID    int         `json:"user_id"`
+	DayOfWeek int         `json:"day_of_week"` // 0: Sunday, ... 6: Saturday
+	Options   map[int]int `json:"options"`
 }
 
-// Mapping from meal option id to Japanese text.
-var mealOptionText = map[int]string{
-   1: "なし",
-   2: "家",
-   3: "弁当",
-}
+// weekdayNames are the Japanese day names indexed by day_of_week (0 = Sunday).
+var weekdayNames = []string{"日", "月", "火", "水", "木", "金", "土"}
 
 // notifyLeadTime specifies threshold for last-minute changes.
 const notifyLeadTime = 24 * time.Hour
@@ -113,29 +108,29 @@ func healthCheck(c *gin.Context) {
 }
 
 // getMealsQuery retrieves meal schedule for a date range in a single query.
-// It pivots meal_period rows into lunch/dinner columns and joins user defaults,
-// so no Go-side merging or fallback logic is needed.
+// It returns one row per user, date and active meal period with the user's default
+// joined in, so the only Go-side work is grouping the periods of a user's day.
 const getMealsQuery = `
         SELECT
             u.id,
             u.name,
             TO_CHAR(d.date, 'YYYY-MM-DD'),
-            COALESCE(MAX(CASE WHEN m.meal_period = 1 THEN m.meal_option END), 0),
-            COALESCE(MAX(CASE WHEN m.meal_period = 2 THEN m.meal_option END), 0),
-            COALESCE(ud.lunch, 1),
-            COALESCE(ud.dinner, 1)
+            p.id,
+            COALESCE(m.meal_option, 0),
+            COALESCE(ud.meal_option, 1)
         FROM users u
         CROSS JOIN generate_series($1::date, $2::date, '1 day') AS d(date)
-        LEFT JOIN meals m ON m.user_id = u.id AND m.date = d.date
+        CROSS JOIN meal_periods p
+        LEFT JOIN meals m ON m.user_id = u.id AND m.date = d.date AND m.meal_period = p.id
         LEFT JOIN user_defaults ud ON ud.user_id = u.id
             AND ud.day_of_week = EXTRACT(DOW FROM d.date)
-        WHERE u.is_eater = true
-        GROUP BY u.id, u.name, d.date, ud.lunch, ud.dinner
-        ORDER BY d.date, u.id`
+            AND ud.meal_period = p.id
+        WHERE u.is_eater = true AND u.active = true AND p.active = true
+        ORDER BY d.date, u.display_order, u.id, p.sort_order, p.id`
 
 // getMeals retrieves meal information for a range of dates.
 // For each user and each date, if there is no meal record, the user's default for that day-of-week is used.
-// The returned JSON includes defaultLunch and defaultDinner fields.
+// The returned JSON includes per-period options and defaults, and the guests each user hosts.
 func getMeals(c *gin.Context) {
 	dateParam := c.Query("date")
 	daysParam := c.Query("days")
@@ -154,6 +149,11 @@ func getMeals(c *gin.Context) {
 
 	endDate := startDate.AddDate(0, 0, days-1).Format("2006-01-02")
 
+	guests, err := loadGuestCounts(startDate.Format("2006-01-02"), endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	rows, err := db.Query(getMealsQuery, startDate.Format("2006-01-02"), endDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -163,132 +163,206 @@ func getMeals(c *gin.Context) {
 
 	result := make(map[string][]Meal)
 	for rows.Next() {
-		var m Meal
-		var dateStr string
-		if err := rows.Scan(&m.UserID, &m.UserName, &dateStr, &m.Lunch, &m.Dinner, &m.DefaultLunch, &m.DefaultDinner); err != nil {
+		var userID, periodID, option, defaultOption int
+		var userName, dateStr string
+		if err := rows.Scan(&userID, &userName, &dateStr, &periodID, &option, &defaultOption); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		result[dateStr] = append(result[dateStr], m)
+		// Rows are ordered by date and user, so a user's periods are contiguous.
+		day := result[dateStr]
+		if len(day) == 0 || day[len(day)-1].UserID != userID {
+			day = append(day, Meal{UserID: userID, UserName: userName, Options: map[int]int{}, Defaults: map[int]int{},
+				Guests: guests[userID][dateStr]})
+		}
+		m := &day[len(day)-1]
+		m.Options[periodID] = option
+		m.Defaults[periodID] = defaultOption
+		result[dateStr] = day
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
+// bulkUpdateMealsStmt upserts one meal and, if the option actually changed, appends
+// the old and new values to meal_changes in the same statement.
+// $5 is the acting user, $6 the source endpoint and $7 the cutoff mode applied
+// (NULL when the change was made before the cutoff). A non-NULL $8 is the cook who
+// has to acknowledge the change; the pending acknowledgement is written alongside.
+// It returns whether the option changed, so unchanged rows are not announced.
+const bulkUpdateMealsStmt = `WITH prev AS (
+    SELECT meal_option FROM meals WHERE user_id = $1 AND date = $2 AND meal_period = $3
+), upsert AS (
+    INSERT INTO meals (user_id, date, meal_period, meal_option) VALUES ($1, $2, $3, $4)
+    ON CONFLICT (user_id, date, meal_period) DO UPDATE SET meal_option = EXCLUDED.meal_option
+), logged AS (
+    INSERT INTO meal_changes (user_id, date, meal_period, old_option, new_option, actor_id, source, cutoff_mode)
+    SELECT $1::int, $2::date, $3::int, (SELECT meal_option FROM prev), $4::int, $5::int, $6::text, $7::text
+    WHERE (SELECT meal_option FROM prev) IS DISTINCT FROM $4::int
+    RETURNING id
+), acked AS (
+    INSERT INTO acknowledgements (meal_change_id, cook_user_id)
+    SELECT id, $8::int FROM logged WHERE $8::int IS NOT NULL
+)
+SELECT EXISTS (SELECT 1 FROM logged)`
+
 // bulkUpdateMeals performs a bulk update/insertion of meal records.
+// Changes past a period's cutoff are handled by its cutoff_mode; override=true lets
+// the cook or an admin push them through. Locked rows are listed in the response.
+// Late changes are notified and left pending until the cook acknowledges them.
 func bulkUpdateMeals(c *gin.Context) {
 	var updates []MealUpdate
 	if err := c.ShouldBindJSON(&updates); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
-   }
-
-   // Fetch user names from DB to use in notifications
-   users := make(map[int]string)
-   userRows, err := db.Query("SELECT id, name FROM users")
-   if err != nil {
-       c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-       return
-   }
-   defer userRows.Close()
-   for userRows.Next() {
-       var id int
-       var name string
-       if err := userRows.Scan(&id, &name); err != nil {
-           c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-           return
-       }
-       users[id] = name
-   }
-
-   // prepare for last-minute change notification
-   now := time.Now()
-	var lateMsgs []string
+	}
+	saveMealUpdates(c, updates)
+}
+
+// saveMealUpdates validates, authorizes and writes a bulk meal update in one transaction
+// and writes the response. It is shared by bulk-update and the CSV import, so both get
+// the same checks, audit log, acknowledgements and notifications.
+func saveMealUpdates(c *gin.Context, updates []MealUpdate) {
+	// Check every row against the masters before authorizing or writing any.
+	v, err := newValidator(validatePeriods | validateOptions | validateUsers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i, m := range updates {
+		v.member(i, "user_id", m.UserID)
+		v.date(i, "date", m.Date)
+		v.mealOptions(i, m.Options, true)
+	}
+	if v.respond(c) {
+		return
+	}
+	periods, options := v.periods, v.options
+
+	// Authorize every row before writing any, so a mixed request is not half applied.
+	caller := currentUser(c)
+	denied, err := forbiddenMealUpdates(caller, updates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(denied) > 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "some rows belong to other members", "rows": denied})
+		return
+	}
+
+	now := time.Now()
+	locked, denied, err := lockedMealUpdates(caller, updates, periods, c.Query("override") == "true", now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(denied) > 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "override requires the cook or an admin", "rows": denied})
+		return
+	}
+	lockModes := make(map[[2]int]string, len(locked)) // (index, period) -> mode
+	for _, l := range locked {
+		if l.Mode == cutoffReject {
+			c.JSON(http.StatusConflict, gin.H{"error": "some rows are past the cutoff", "locked": locked})
+			return
+		}
+		lockModes[[2]int{l.Index, l.MealPeriod}] = l.Mode
+	}
+
+	// A row is late when it is for a meal within notifyLeadTime or past a cutoff.
+	late := make([]bool, len(updates))
+	for i, m := range updates {
+		date, err := time.Parse("2006-01-02", m.Date)
+		late[i] = err == nil && date.Sub(now) <= notifyLeadTime
+	}
+	for _, l := range locked {
+		late[l.Index] = true
+	}
+	cooks, err := lateCookAssignments(updates, late)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// prepare for last-minute change notification, attributed to the caller
+	source := changeSource(c)
+	var lateEvents []Event
 	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-//	stmt, err := tx.Prepare("INSERT INTO meals (user_id, date, lunch, dinner) VALUES ($1, $2, $3, $4) ON CONFLICT (user_id, date) DO UPDATE SET lunch = EXCLUDED.lunch, dinner = EXCLUDED.dinner")
-	stmt, err := tx.Prepare("INSERT INTO meals (user_id, date, meal_period, meal_option) VALUES ($1, $2, $3, $4) ON CONFLICT (user_id, date, meal_period) DO UPDATE SET meal_option = EXCLUDED.meal_option")
+	stmt, err := tx.Prepare(bulkUpdateMealsStmt)
 	if err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer stmt.Close()
-	for _, m := range updates {
-       // Determine user name and detect last-minute change
-       userName := users[m.UserID]
-       // Detect last-minute change
-		if date, err := time.Parse("2006-01-02", m.Date); err == nil {
-			if diff := date.Sub(now); diff <= notifyLeadTime {
-				if m.Lunch != 0 {
-					optionName := mealOptionText[m.Lunch]
-                   lateMsgs = append(lateMsgs, fmt.Sprintf("%s の %s さんの昼食が「%s」に変更されました", m.Date, userName, optionName))
-				}
-				if m.Dinner != 0 {
-					optionName := mealOptionText[m.Dinner]
-                   lateMsgs = append(lateMsgs, fmt.Sprintf("%s の %s さんの夕食が「%s」に変更されました", m.Date, userName, optionName))
-				}
+	for i, m := range updates {
+		userName := v.users[m.UserID].Name
+		for _, periodID := range sortedPeriodIDs(m.Options) {
+			optionID := m.Options[periodID]
+			if optionID == 0 {
+				continue
 			}
-		}
-		if m.Lunch != 0 {
-			if _, err := stmt.Exec(m.UserID, m.Date, 1, m.Lunch ); err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
+			var lockMode, cook interface{}
+			mode, isLocked := lockModes[[2]int{i, periodID}]
+			if isLocked {
+				lockMode = mode
 			}
-		}
-
-		if m.Dinner != 0 {
-			if _, err := stmt.Exec(m.UserID, m.Date, 2, m.Dinner); err != nil {
+			if late[i] {
+				cook = ackCook(cooks, m.Date, periodID, caller)
+			}
+			var changed bool
+			if err := stmt.QueryRow(m.UserID, m.Date, periodID, optionID, caller.ID, source, lockMode, cook).
+				Scan(&changed); err != nil {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
+			if late[i] && changed {
+				msg := fmt.Sprintf("%s さんが %s の %s さんの%sを「%s」に変更しました",
+					caller.Name, m.Date, userName, periods[periodID].Name, options[optionID].Label)
+				switch mode {
+				case cutoffAcknowledge:
+					msg += "（締め切り後・要確認）"
+				case cutoffOverride:
+					msg += "（締め切り後・強制変更）"
+				}
+				if to := lateRecipients(cooks[m.Date][periodID], m.UserID, userName, caller); len(to) > 0 {
+					lateEvents = append(lateEvents, Event{Kind: eventLateMealChange, Messages: []string{msg}, Recipients: to})
+				}
+			}
 		}
 	}
-   if err := tx.Commit(); err != nil {
-       c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-       return
-   }
-   // Send Slack notification for last-minute changes
-   if len(lateMsgs) > 0 {
-       go sendSlackNotification(lateMsgs)
-   }
-   c.JSON(http.StatusOK, gin.H{"message": "Meals updated"})
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Notify the cooks and members concerned of last-minute changes
+	if len(lateEvents) > 0 {
+		go dispatch.publish(mergeEvents(lateEvents)...)
+	}
+	if len(locked) > 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Meals updated", "locked": locked})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Meals updated"})
 }
 
-// sendSlackNotification sends a list of messages to Slack via incoming webhook.
-func sendSlackNotification(messages []string) {
-   webhookURL := os.Getenv("SLACK_WEBHOOK_URL")
-   if webhookURL == "" {
-       return
-   }
-   combined := strings.Join(messages, "\n")
-   text := fmt.Sprintf("<!channel>\n%s", combined)
-   payload := map[string]string{"text": text}
-
-   body, err := json.Marshal(payload)
-   if err != nil {
-       log.Printf("failed to marshal Slack payload: %v", err)
-       return
-   }
-   resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(body))
-   if err != nil {
-       log.Printf("failed to send Slack notification: %v", err)
-       return
-   }
-   defer resp.Body.Close()
-   if resp.StatusCode >= 300 {
-       log.Printf("Slack notification returned non-OK status: %s", resp.Status)
-   }
-}
+// getUsersQuery lists users in display order; $1=true includes deactivated users.
+const getUsersQuery = `SELECT id, name, is_cook, is_eater, display_order, active, is_admin
+FROM users
+WHERE active OR $1
+ORDER BY display_order, id`
 
-// getUsers returns all users with their role attributes.
+// getUsers returns active users with their role attributes.
+// Pass include_inactive=true to also list deactivated users.
 func getUsers(c *gin.Context) {
-	rows, err := db.Query("SELECT id, name, is_cook, is_eater FROM users ORDER BY id")
+	includeInactive := c.Query("include_inactive") == "true"
+	rows, err := db.Query(getUsersQuery, includeInactive)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -297,7 +371,7 @@ func getUsers(c *gin.Context) {
 	users := []User{}
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.ID, &u.Name, &u.IsCook, &u.IsEater); err != nil {
+		if err := rows.Scan(&u.ID, &u.Name, &u.IsCook, &u.IsEater, &u.DisplayOrder, &u.Active, &u.IsAdmin); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -306,70 +380,158 @@ func getUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
-// updateUserRoles updates the is_cook / is_eater flags for a specific user.
+// updateUserRolesStmt sets the role flags; a NULL $3 leaves is_admin unchanged.
+const updateUserRolesStmt = "UPDATE users SET is_cook = $1, is_eater = $2, is_admin = COALESCE($3::bool, is_admin) WHERE id = $4"
+
+// updateUserRoles updates the is_cook / is_eater / is_admin flags for a specific user.
+// Admins cannot revoke their own admin role, so the household is never left without one.
+// Revoking is_cook from someone who still cooks from today on is a 409 listing those
+// assignments, unless reassign moves them to another cook or 各自 in the same transaction.
+// Revoking is_eater from someone who hosts guests from today on is a 409 listing them.
 func updateUserRoles(c *gin.Context) {
 	userID := c.Param("user_id")
 	var req struct {
-		IsCook  bool `json:"is_cook"`
-		IsEater bool `json:"is_eater"`
+		IsCook   bool          `json:"is_cook"`
+		IsEater  bool          `json:"is_eater"`
+		IsAdmin  *bool         `json:"is_admin"` // nil = unchanged
+		Reassign *CookReassign `json:"reassign"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	result, err := db.Exec("UPDATE users SET is_cook = $1, is_eater = $2 WHERE id = $3", req.IsCook, req.IsEater, userID)
+	caller := currentUser(c)
+	if req.IsAdmin != nil && !*req.IsAdmin && userID == strconv.Itoa(caller.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot revoke your own admin role"})
+		return
+	}
+	if !validReassignTarget(c, userID, req.Reassign) {
+		return
+	}
+	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	result, err := tx.Exec(updateUserRolesStmt, req.IsCook, req.IsEater, nullableBool(req.IsAdmin), userID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	n, err := result.RowsAffected()
 	if err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	if n == 0 {
+		tx.Rollback()
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
+	if !req.IsCook {
+		conflict, err := releaseCookAssignments(tx, userID, req.Reassign, caller.ID, changeSource(c))
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if conflict != nil {
+			tx.Rollback()
+			cookRoleConflictResponse(c, conflict)
+			return
+		}
+	}
+	if !req.IsEater {
+		guests, err := hostedGuests(tx, userID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(guests) > 0 {
+			tx.Rollback()
+			hostedGuestsConflictResponse(c, guests)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "User roles updated"})
 }
 
+const getUserDefaultsQuery = "SELECT day_of_week, meal_period, meal_option FROM user_defaults WHERE user_id = $1 ORDER BY day_of_week, meal_period"
+
 // getUserDefaults returns the default meal settings for a specific user.
 func getUserDefaults(c *gin.Context) {
 	userID := c.Param("user_id")
-	rows, err := db.Query("SELECT day_of_week, lunch, dinner FROM user_defaults WHERE user_id = $1 ORDER BY day_of_week", userID)
+	rows, err := db.Query(getUserDefaultsQuery, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
+	uid, _ := strconv.Atoi(userID)
 	var defaults []UserDefault
 	for rows.Next() {
-		var ud UserDefault
-		ud.UserID, _ = strconv.Atoi(userID)
-		if err := rows.Scan(&ud.DayOfWeek, &ud.Lunch, &ud.Dinner); err != nil {
+		var dayOfWeek, periodID, optionID int
+		if err := rows.Scan(&dayOfWeek, &periodID, &optionID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		defaults = append(defaults, ud)
+		// Rows are ordered by day_of_week, so a day's periods are contiguous.
+		if len(defaults) == 0 || defaults[len(defaults)-1].DayOfWeek != dayOfWeek {
+			defaults = append(defaults, UserDefault{UserID: uid, DayOfWeek: dayOfWeek, Options: map[int]int{}})
+		}
+		defaults[len(defaults)-1].Options[periodID] = optionID
 	}
 	c.JSON(http.StatusOK, defaults)
 }
 
+const updateUserDefaultsStmt = "INSERT INTO user_defaults (user_id, day_of_week, meal_period, meal_option) VALUES ($1, $2, $3, $4) ON CONFLICT (user_id, day_of_week, meal_period) DO UPDATE SET meal_option = EXCLUDED.meal_option"
+
 // updateUserDefaults updates the default meal settings for a specific user.
+// Members may only change their own defaults; admins may change anyone's.
 func updateUserDefaults(c *gin.Context) {
-	userID := c.Param("user_id")
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if caller := currentUser(c); !caller.IsAdmin && userID != caller.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own defaults"})
+		return
+	}
 	var defaults []UserDefault
 	if err := c.ShouldBindJSON(&defaults); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	v, err := newValidator(validatePeriods | validateOptions | validateUsers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := v.users[userID]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	for i, ud := range defaults {
+		v.dayOfWeek(i, "day_of_week", ud.DayOfWeek)
+		v.mealOptions(i, ud.Options, false)
+	}
+	if v.respond(c) {
+		return
+	}
 	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	stmt, err := tx.Prepare("INSERT INTO user_defaults (user_id, day_of_week, lunch, dinner) VALUES ($1, $2, $3, $4) ON CONFLICT (user_id, day_of_week) DO UPDATE SET lunch = EXCLUDED.lunch, dinner = EXCLUDED.dinner")
+	stmt, err := tx.Prepare(updateUserDefaultsStmt)
 	if err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -377,10 +539,12 @@ func updateUserDefaults(c *gin.Context) {
 	}
 	defer stmt.Close()
 	for _, ud := range defaults {
-		if _, err := stmt.Exec(userID, ud.DayOfWeek, ud.Lunch, ud.Dinner); err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+		for _, periodID := range sortedPeriodIDs(ud.Options) {
+			if _, err := stmt.Exec(userID, ud.DayOfWeek, periodID, ud.Options[periodID]); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
 		}
 	}
 	if err := tx.Commit(); err != nil {
@@ -404,7 +568,7 @@ const getCookSchedulesQuery = `
         END,
         u.name
     FROM generate_series($1::date, $2::date, '1 day') AS d(date)
-    CROSS JOIN (VALUES (1), (2)) AS p(id)
+    CROSS JOIN meal_periods p
     LEFT JOIN cook_schedules cs ON cs.date = d.date AND cs.meal_period = p.id
     LEFT JOIN cook_default_schedules cds
         ON cds.day_of_week = EXTRACT(DOW FROM d.date) AND cds.meal_period = p.id
@@ -412,7 +576,8 @@ const getCookSchedulesQuery = `
         WHEN cs.date IS NOT NULL THEN cs.cook_user_id
         ELSE cds.cook_user_id
     END
-    ORDER BY d.date, p.id`
+    WHERE p.active = true
+    ORDER BY d.date, p.sort_order, p.id`
 
 // getCookSchedules returns resolved cook assignments for a date range.
 func getCookSchedules(c *gin.Context) {
@@ -431,45 +596,27 @@ func getCookSchedules(c *gin.Context) {
 	}
 	endDate := startDate.AddDate(0, 0, days-1).Format("2006-01-02")
 
-	rows, err := db.Query(getCookSchedulesQuery, startDate.Format("2006-01-02"), endDate)
+	result, err := loadCookAssignments(startDate.Format("2006-01-02"), endDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	result := make(map[string]*DailyCookSchedule)
-	for rows.Next() {
-		var dateStr string
-		var mealPeriod int
-		var cookUserID sql.NullInt64
-		var cookUserName sql.NullString
-		if err := rows.Scan(&dateStr, &mealPeriod, &cookUserID, &cookUserName); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if _, ok := result[dateStr]; !ok {
-			result[dateStr] = &DailyCookSchedule{}
-		}
-		var assignment *CookAssignment
-		if cookUserID.Valid {
-			assignment = &CookAssignment{
-				CookUserID:   int(cookUserID.Int64),
-				CookUserName: cookUserName.String,
-			}
-		}
-		if mealPeriod == 1 {
-			result[dateStr].Lunch = assignment
-		} else {
-			result[dateStr].Dinner = assignment
-		}
-	}
 	c.JSON(http.StatusOK, result)
 }
 
-const bulkUpdateCookSchedulesStmt = `INSERT INTO cook_schedules (date, meal_period, cook_user_id)
-VALUES ($1, $2, $3)
-ON CONFLICT (date, meal_period) DO UPDATE SET cook_user_id = EXCLUDED.cook_user_id`
+// bulkUpdateCookSchedulesStmt upserts one override and logs it to cook_schedule_changes
+// unless the same override was already in place. $4 is the actor and $5 the source.
+const bulkUpdateCookSchedulesStmt = `WITH prev AS (
+    SELECT cook_user_id FROM cook_schedules WHERE date = $1 AND meal_period = $2
+), upsert AS (
+    INSERT INTO cook_schedules (date, meal_period, cook_user_id)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (date, meal_period) DO UPDATE SET cook_user_id = EXCLUDED.cook_user_id
+)
+INSERT INTO cook_schedule_changes
+    (date, meal_period, old_override, old_cook_user_id, new_override, new_cook_user_id, actor_id, source)
+SELECT $1::date, $2::int, EXISTS (SELECT 1 FROM prev), (SELECT cook_user_id FROM prev), true, $3::int, $4::int, $5::text
+WHERE NOT EXISTS (SELECT 1 FROM prev WHERE cook_user_id IS NOT DISTINCT FROM $3::int)`
 
 // bulkUpdateCookSchedules upserts individual date cook assignments.
 func bulkUpdateCookSchedules(c *gin.Context) {
@@ -478,6 +625,20 @@ func bulkUpdateCookSchedules(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	v, err := newValidator(validatePeriods | validateUsers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i, u := range updates {
+		v.date(i, "date", u.Date)
+		v.period(i, "meal_period", u.MealPeriod, false)
+		v.cook(i, "cook_user_id", u.CookUserID)
+	}
+	if v.respond(c) {
+		return
+	}
+	caller, source := currentUser(c), changeSource(c)
 	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -490,25 +651,50 @@ func bulkUpdateCookSchedules(c *gin.Context) {
 		return
 	}
 	defer stmt.Close()
+	var msgs []string
 	for _, u := range updates {
 		var cookID interface{}
 		if u.CookUserID != nil {
 			cookID = *u.CookUserID
 		}
-		if _, err := stmt.Exec(u.Date, u.MealPeriod, cookID); err != nil {
+		res, err := stmt.Exec(u.Date, u.MealPeriod, cookID, caller.ID, source)
+		if err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		// Only overrides that changed are logged, and only those are announced.
+		if n, _ := res.RowsAffected(); n > 0 {
+			msgs = append(msgs, fmt.Sprintf("%s さんが %s の%sの料理担当を「%s」に変更しました",
+				caller.Name, u.Date, v.periods[u.MealPeriod].Name, cookLabel(v.users, u.CookUserID)))
+		}
 	}
 	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if len(msgs) > 0 {
+		go dispatch.publish(Event{Kind: eventCookScheduleChange, Messages: msgs})
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Cook schedules updated"})
 }
 
-const deleteCookSchedulesStmt = "DELETE FROM cook_schedules WHERE date = $1 AND meal_period = $2"
+// cookLabel names a cook in notifications; nil is 各自.
+func cookLabel(users map[int]User, cookUserID *int) string {
+	if cookUserID == nil {
+		return "各自"
+	}
+	return users[*cookUserID].Name + " さん"
+}
+
+// deleteCookSchedulesStmt removes one override and logs what it was; deleting a
+// missing override logs nothing. $3 is the actor and $4 the source.
+const deleteCookSchedulesStmt = `WITH deleted AS (
+    DELETE FROM cook_schedules WHERE date = $1 AND meal_period = $2 RETURNING cook_user_id
+)
+INSERT INTO cook_schedule_changes
+    (date, meal_period, old_override, old_cook_user_id, new_override, new_cook_user_id, actor_id, source)
+SELECT $1::date, $2::int, true, cook_user_id, false, NULL, $3::int, $4::text FROM deleted`
 
 // deleteCookSchedules removes individual date overrides, reverting to weekday defaults.
 func deleteCookSchedules(c *gin.Context) {
@@ -517,6 +703,19 @@ func deleteCookSchedules(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	v, err := newValidator(validatePeriods)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i, e := range entries {
+		v.date(i, "date", e.Date)
+		v.period(i, "meal_period", e.MealPeriod, true)
+	}
+	if v.respond(c) {
+		return
+	}
+	caller, source := currentUser(c), changeSource(c)
 	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -529,17 +728,26 @@ func deleteCookSchedules(c *gin.Context) {
 		return
 	}
 	defer stmt.Close()
+	var msgs []string
 	for _, e := range entries {
-		if _, err := stmt.Exec(e.Date, e.MealPeriod); err != nil {
+		res, err := stmt.Exec(e.Date, e.MealPeriod, caller.ID, source)
+		if err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			msgs = append(msgs, fmt.Sprintf("%s さんが %s の%sの料理担当の個別設定を取り消しました（曜日ごとの既定に戻ります）",
+				caller.Name, e.Date, v.periods[e.MealPeriod].Name))
+		}
 	}
 	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if len(msgs) > 0 {
+		go dispatch.publish(Event{Kind: eventCookScheduleChange, Messages: msgs})
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Cook schedules deleted"})
 }
 
@@ -577,9 +785,20 @@ func getCookDefaultSchedules(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-const updateCookDefaultSchedulesStmt = `INSERT INTO cook_default_schedules (day_of_week, meal_period, cook_user_id)
-VALUES ($1, $2, $3)
-ON CONFLICT (day_of_week, meal_period) DO UPDATE SET cook_user_id = EXCLUDED.cook_user_id`
+// updateCookDefaultSchedulesStmt upserts one weekday default and logs it to
+// cook_default_schedule_changes unless the same cook was already set (a missing row
+// counts as 各自). $4 is the actor and $5 the source.
+const updateCookDefaultSchedulesStmt = `WITH prev AS (
+    SELECT cook_user_id FROM cook_default_schedules WHERE day_of_week = $1 AND meal_period = $2
+), upsert AS (
+    INSERT INTO cook_default_schedules (day_of_week, meal_period, cook_user_id)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (day_of_week, meal_period) DO UPDATE SET cook_user_id = EXCLUDED.cook_user_id
+)
+INSERT INTO cook_default_schedule_changes
+    (day_of_week, meal_period, old_cook_user_id, new_cook_user_id, actor_id, source)
+SELECT $1::int, $2::int, (SELECT cook_user_id FROM prev), $3::int, $4::int, $5::text
+WHERE (SELECT cook_user_id FROM prev) IS DISTINCT FROM $3::int`
 
 // updateCookDefaultSchedules upserts weekday-based default cook assignments.
 func updateCookDefaultSchedules(c *gin.Context) {
@@ -588,6 +807,20 @@ func updateCookDefaultSchedules(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	v, err := newValidator(validatePeriods | validateUsers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i, e := range entries {
+		v.dayOfWeek(i, "day_of_week", e.DayOfWeek)
+		v.period(i, "meal_period", e.MealPeriod, false)
+		v.cook(i, "cook_user_id", e.CookUserID)
+	}
+	if v.respond(c) {
+		return
+	}
+	caller, source := currentUser(c), changeSource(c)
 	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -600,21 +833,31 @@ func updateCookDefaultSchedules(c *gin.Context) {
 		return
 	}
 	defer stmt.Close()
+	var msgs []string
 	for _, e := range entries {
 		var cookID interface{}
 		if e.CookUserID != nil {
 			cookID = *e.CookUserID
 		}
-		if _, err := stmt.Exec(e.DayOfWeek, e.MealPeriod, cookID); err != nil {
+		res, err := stmt.Exec(e.DayOfWeek, e.MealPeriod, cookID, caller.ID, source)
+		if err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		// As for overrides, only defaults that changed are logged and announced.
+		if n, _ := res.RowsAffected(); n > 0 {
+			msgs = append(msgs, fmt.Sprintf("%s さんが毎週%s曜の%sの料理担当を「%s」に変更しました",
+				caller.Name, weekdayNames[e.DayOfWeek], v.periods[e.MealPeriod].Name, cookLabel(v.users, e.CookUserID)))
+		}
 	}
 	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if len(msgs) > 0 {
+		go dispatch.publish(Event{Kind: eventCookScheduleChange, Messages: msgs})
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Cook default schedules updated"})
 }
 
@@ -626,18 +869,81 @@ func main() {
 	}
 	defer db.Close()
 
+	if err := dispatch.reload(); err != nil {
+		log.Printf("failed to load notification channels: %v", err)
+	}
+	go runAckRenotifier(ackRenotifyInterval())
+	go runBentoReminder(bentoReminderTime())
+	go runReleaseHeld()
+
 	r := gin.Default()
 	r.GET("/api/health", healthCheck)
-	r.GET("/api/users", getUsers)
-	r.PUT("/api/users/:user_id/roles", updateUserRoles)
-	r.GET("/api/meals", getMeals)
-	r.PUT("/api/meals/bulk-update", bulkUpdateMeals)
-	r.GET("/api/user-defaults/:user_id", getUserDefaults)
-	r.PUT("/api/user-defaults/:user_id", updateUserDefaults)
-	r.GET("/api/cook-schedules", getCookSchedules)
-	r.PUT("/api/cook-schedules", bulkUpdateCookSchedules)
-	r.DELETE("/api/cook-schedules", deleteCookSchedules)
-	r.GET("/api/cook-default-schedules", getCookDefaultSchedules)
-	r.PUT("/api/cook-default-schedules", updateCookDefaultSchedules)
+	r.POST("/api/auth/login", login)
+	r.POST("/api/auth/logout", logout)
+	// Checks the session itself so credentials can be set before anyone can log in.
+	r.PUT("/api/users/:user_id/credentials", setUserCredentials)
+	// Calendar apps cannot log in; these feeds check their own ?token= instead.
+	r.GET("/api/calendar/cook/:file", getCookCalendar)
+	r.GET("/api/calendar/meals/:file", getMealCalendar)
+
+	// Everything else requires a session; handlers read the caller via currentUser.
+	api := r.Group("/api", requireAuth)
+	api.GET("/auth/me", getMe)
+	api.GET("/users", getUsers)
+	api.POST("/users", requireAdmin, createUser)
+	api.PATCH("/users/:user_id", requireAdmin, updateUser)
+	api.DELETE("/users/:user_id", requireAdmin, deleteUser)
+	api.PUT("/users/:user_id/roles", requireAdmin, updateUserRoles)
+	api.GET("/users/:user_id/dietary", getUserDietary)
+	api.PUT("/users/:user_id/dietary", updateUserDietary)
+	api.PUT("/users/:user_id/bento-note", updateBentoNote)
+	api.GET("/users/:user_id/notification-preferences", getNotificationPreferences)
+	api.PUT("/users/:user_id/notification-preferences", updateNotificationPreferences)
+	api.POST("/users/:user_id/calendar-token", createCalendarToken)
+	api.DELETE("/users/:user_id/calendar-token", deleteCalendarToken)
+	api.GET("/meal-periods", getMealPeriods)
+	api.POST("/meal-periods", requireAdmin, createMealPeriod)
+	api.PATCH("/meal-periods/:period_id", requireAdmin, updateMealPeriod)
+	api.GET("/meal-options", getMealOptions)
+	api.POST("/meal-options", requireAdmin, createMealOption)
+	api.PATCH("/meal-options/:option_id", requireAdmin, updateMealOption)
+	api.GET("/meals", getMeals)
+	api.GET("/meal-guests", getMealGuests)
+	api.POST("/meal-guests", createMealGuest)
+	api.PATCH("/meal-guests/:id", updateMealGuest)
+	api.DELETE("/meal-guests/:id", deleteMealGuest)
+	api.PUT("/meals/bulk-update", bulkUpdateMeals)
+	api.GET("/user-defaults/:user_id", getUserDefaults)
+	api.PUT("/user-defaults/:user_id", updateUserDefaults)
+	api.GET("/cook-schedules", getCookSchedules)
+	api.PUT("/cook-schedules", requireAdmin, bulkUpdateCookSchedules)
+	api.DELETE("/cook-schedules", requireAdmin, deleteCookSchedules)
+	api.POST("/cook-schedules/generate", requireAdmin, generateCookSchedules)
+	api.GET("/summary", getSummary)
+	api.GET("/menus", getMenus)
+	api.PUT("/menus", bulkUpdateMenus)
+	api.GET("/shopping-list", getShoppingList)
+	api.GET("/recipes", getRecipes)
+	api.GET("/bento", getBento)
+	api.GET("/recipes/suggestions", getRecipeSuggestions)
+	api.GET("/recipes/:id", getRecipe)
+	api.POST("/recipes", requireCook, createRecipe)
+	api.PUT("/recipes/:id", requireCook, updateRecipe)
+	api.DELETE("/recipes/:id", requireCook, deleteRecipe)
+	api.GET("/stats/cooks", getCookStats)
+	api.GET("/stats/members", getMemberStats)
+	api.GET("/export/meals", exportMeals)
+	api.POST("/import/meals", importMeals)
+	api.POST("/import/user-defaults", importUserDefaults)
+	api.GET("/history", getHistory)
+	api.GET("/acknowledgements/pending", getPendingAcknowledgements)
+	api.POST("/acknowledgements/:id", acknowledge)
+	api.GET("/cook-default-schedules", getCookDefaultSchedules)
+	api.PUT("/cook-default-schedules", requireAdmin, updateCookDefaultSchedules)
+	api.GET("/notification-channels", requireAdmin, getNotificationChannels)
+	api.POST("/notification-channels", requireAdmin, createNotificationChannel)
+	api.PUT("/notification-channels/:id", requireAdmin, updateNotificationChannel)
+	api.DELETE("/notification-channels/:id", requireAdmin, deleteNotificationChannel)
+	api.POST("/notification-channels/:id/test", requireAdmin, testNotificationChannel)
 	r.Run(":8080")
 }
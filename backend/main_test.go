@@ -20,20 +20,84 @@ func setupRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
 	r.GET("/health", healthCheck)
-	r.GET("/api/users", getUsers)
-	r.PUT("/api/users/:user_id/roles", updateUserRoles)
-	r.GET("/api/meals", getMeals)
-	r.PUT("/api/meals/bulk-update", bulkUpdateMeals)
-	r.GET("/api/user-defaults/:user_id", getUserDefaults)
-	r.PUT("/api/user-defaults/:user_id", updateUserDefaults)
-	r.GET("/api/cook-schedules", getCookSchedules)
-	r.PUT("/api/cook-schedules", bulkUpdateCookSchedules)
-	r.DELETE("/api/cook-schedules", deleteCookSchedules)
-	r.GET("/api/cook-default-schedules", getCookDefaultSchedules)
-	r.PUT("/api/cook-default-schedules", updateCookDefaultSchedules)
+	r.POST("/api/auth/login", login)
+	r.POST("/api/auth/logout", logout)
+	r.PUT("/api/users/:user_id/credentials", setUserCredentials)
+	r.GET("/api/auth/me", requireAuth, getMe)
+	r.GET("/api/calendar/cook/:file", getCookCalendar)
+	r.GET("/api/calendar/meals/:file", getMealCalendar)
+
+	// Other routes get a fixed caller instead of a session lookup, so tests don't
+	// need to mock the sessions query; see auth_test.go for requireAuth itself.
+	api := r.Group("/api", testCaller)
+	api.GET("/users", getUsers)
+	api.POST("/users", requireAdmin, createUser)
+	api.PATCH("/users/:user_id", requireAdmin, updateUser)
+	api.DELETE("/users/:user_id", requireAdmin, deleteUser)
+	api.PUT("/users/:user_id/roles", requireAdmin, updateUserRoles)
+	api.GET("/users/:user_id/dietary", getUserDietary)
+	api.PUT("/users/:user_id/dietary", updateUserDietary)
+	api.PUT("/users/:user_id/bento-note", updateBentoNote)
+	api.GET("/users/:user_id/notification-preferences", getNotificationPreferences)
+	api.PUT("/users/:user_id/notification-preferences", updateNotificationPreferences)
+	api.POST("/users/:user_id/calendar-token", createCalendarToken)
+	api.DELETE("/users/:user_id/calendar-token", deleteCalendarToken)
+	api.GET("/meal-periods", getMealPeriods)
+	api.POST("/meal-periods", requireAdmin, createMealPeriod)
+	api.PATCH("/meal-periods/:period_id", requireAdmin, updateMealPeriod)
+	api.GET("/meal-options", getMealOptions)
+	api.POST("/meal-options", requireAdmin, createMealOption)
+	api.PATCH("/meal-options/:option_id", requireAdmin, updateMealOption)
+	api.GET("/meals", getMeals)
+	api.GET("/meal-guests", getMealGuests)
+	api.POST("/meal-guests", createMealGuest)
+	api.PATCH("/meal-guests/:id", updateMealGuest)
+	api.DELETE("/meal-guests/:id", deleteMealGuest)
+	api.PUT("/meals/bulk-update", bulkUpdateMeals)
+	api.GET("/user-defaults/:user_id", getUserDefaults)
+	api.PUT("/user-defaults/:user_id", updateUserDefaults)
+	api.GET("/cook-schedules", getCookSchedules)
+	api.PUT("/cook-schedules", requireAdmin, bulkUpdateCookSchedules)
+	api.DELETE("/cook-schedules", requireAdmin, deleteCookSchedules)
+	api.POST("/cook-schedules/generate", requireAdmin, generateCookSchedules)
+	api.GET("/summary", getSummary)
+	api.GET("/menus", getMenus)
+	api.PUT("/menus", bulkUpdateMenus)
+	api.GET("/shopping-list", getShoppingList)
+	api.GET("/recipes", getRecipes)
+	api.GET("/bento", getBento)
+	api.GET("/recipes/suggestions", getRecipeSuggestions)
+	api.GET("/recipes/:id", getRecipe)
+	api.POST("/recipes", requireCook, createRecipe)
+	api.PUT("/recipes/:id", requireCook, updateRecipe)
+	api.DELETE("/recipes/:id", requireCook, deleteRecipe)
+	api.GET("/stats/cooks", getCookStats)
+	api.GET("/stats/members", getMemberStats)
+	api.GET("/export/meals", exportMeals)
+	api.POST("/import/meals", importMeals)
+	api.POST("/import/user-defaults", importUserDefaults)
+	api.GET("/history", getHistory)
+	api.GET("/acknowledgements/pending", getPendingAcknowledgements)
+	api.POST("/acknowledgements/:id", acknowledge)
+	api.GET("/cook-default-schedules", getCookDefaultSchedules)
+	api.PUT("/cook-default-schedules", requireAdmin, updateCookDefaultSchedules)
+	api.GET("/notification-channels", requireAdmin, getNotificationChannels)
+	api.POST("/notification-channels", requireAdmin, createNotificationChannel)
+	api.PUT("/notification-channels/:id", requireAdmin, updateNotificationChannel)
+	api.DELETE("/notification-channels/:id", requireAdmin, deleteNotificationChannel)
+	api.POST("/notification-channels/:id/test", requireAdmin, testNotificationChannel)
 	return r
 }
 
+// testCallerUser is the caller setupRouter injects for routes behind authentication.
+// It is an admin so handler tests are not subject to authorization; see authz_test.go.
+var testCallerUser = User{ID: 1, Name: "John", IsEater: true, Active: true, IsAdmin: true}
+
+func testCaller(c *gin.Context) {
+	c.Set(callerKey, testCallerUser)
+	c.Next()
+}
+
 // TestHealthCheck verifies that the /health endpoint returns a healthy status.
 func TestHealthCheck(t *testing.T) {
 	mockDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
@@ -54,24 +118,31 @@ func TestHealthCheck(t *testing.T) {
 }
 
 // TestGetMeals verifies the /api/meals endpoint.
-// The refactored getMeals uses a single query that pivots meal_period into
-// lunch/dinner columns and joins user defaults via generate_series.
+// getMeals uses a single query that returns one row per user, date and meal period
+// with user defaults joined via generate_series; Go only groups the periods.
 func TestGetMeals(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer mockDB.Close()
 	db = mockDB
 
-	// Single combined query: users × date range, with meals pivoted and defaults joined.
-	// Rows represent the final per-user-per-date result already merged by SQL.
-	rows := sqlmock.NewRows([]string{"user_id", "user_name", "date", "lunch", "dinner", "default_lunch", "default_dinner"}).
+	// Single combined query: users × date range × meal periods, with defaults joined.
+	rows := sqlmock.NewRows([]string{"user_id", "user_name", "date", "meal_period", "meal_option", "default_option"}).
 		// 2025-02-16 (Sunday): both users have explicit meal records overriding defaults
-		AddRow(1, "John", "2025-02-16", 1, 1, 2, 2). // John: lunch=None, dinner=None; default Sun=Home/Home
-		AddRow(2, "Paul", "2025-02-16", 1, 1, 2, 2). // Paul: lunch=None, dinner=None; default Sun=Home/Home
+		AddRow(1, "John", "2025-02-16", 1, 1, 2). // John: lunch=None; default Sun=Home
+		AddRow(1, "John", "2025-02-16", 2, 1, 2). // John: dinner=None; default Sun=Home
+		AddRow(2, "Paul", "2025-02-16", 1, 1, 2).
+		AddRow(2, "Paul", "2025-02-16", 2, 1, 2).
 		// 2025-02-17 (Monday): partial records; Paul has no lunch record (returns 0)
-		AddRow(1, "John", "2025-02-17", 3, 1, 1, 2). // John: lunch=Bento, dinner=None; default Mon=None/Home
-		AddRow(2, "Paul", "2025-02-17", 0, 2, 2, 2)  // Paul: lunch=0(not set), dinner=Home; default Mon=Home/Home
-
+		AddRow(1, "John", "2025-02-17", 1, 3, 1). // John: lunch=Bento; default Mon=None
+		AddRow(1, "John", "2025-02-17", 2, 1, 2).
+		AddRow(2, "Paul", "2025-02-17", 1, 0, 2). // Paul: lunch=0(not set); default Mon=Home
+		AddRow(2, "Paul", "2025-02-17", 2, 2, 2)
+
+	// Grandma (2 guests) joins John's dinner on the 17th.
+	mock.ExpectQuery(regexp.QuoteMeta(getGuestCountsQuery)).WithArgs("2025-02-16", "2025-02-17").
+		WillReturnRows(sqlmock.NewRows([]string{"host_user_id", "date", "meal_period", "count"}).
+			AddRow(1, "2025-02-17", 2, 2))
 	mock.ExpectQuery(regexp.QuoteMeta(getMealsQuery)).
 		WithArgs("2025-02-16", "2025-02-17").
 		WillReturnRows(rows)
@@ -85,12 +156,12 @@ func TestGetMeals(t *testing.T) {
 
 	expectedBody := `{
       "2025-02-16": [
-        {"user_id": 1, "user_name": "John", "lunch": 1, "dinner": 1, "defaultLunch": 2, "defaultDinner": 2},
-        {"user_id": 2, "user_name": "Paul", "lunch": 1, "dinner": 1, "defaultLunch": 2, "defaultDinner": 2}
+        {"user_id": 1, "user_name": "John", "options": {"1": 1, "2": 1}, "defaults": {"1": 2, "2": 2}},
+        {"user_id": 2, "user_name": "Paul", "options": {"1": 1, "2": 1}, "defaults": {"1": 2, "2": 2}}
       ],
       "2025-02-17": [
-        {"user_id": 1, "user_name": "John", "lunch": 3, "dinner": 1, "defaultLunch": 1, "defaultDinner": 2},
-        {"user_id": 2, "user_name": "Paul", "lunch": 0, "dinner": 2, "defaultLunch": 2, "defaultDinner": 2}
+        {"user_id": 1, "user_name": "John", "options": {"1": 3, "2": 1}, "defaults": {"1": 1, "2": 2}, "guests": {"2": 2}},
+        {"user_id": 2, "user_name": "Paul", "options": {"1": 0, "2": 2}, "defaults": {"1": 2, "2": 2}}
       ]
     }`
 	assert.JSONEq(t, expectedBody, w.Body.String())
@@ -104,32 +175,38 @@ func TestBulkUpdateMeals(t *testing.T) {
 	defer mockDB.Close()
 	db = mockDB
 
-	// --- Query: users ---
-	rowsUsers := sqlmock.NewRows([]string{"id", "name"}).
-		AddRow(1, "John").
-		AddRow(2, "Paul")
-	queryUsers := "SELECT id, name FROM users"
-	t.Log(regexp.QuoteMeta(queryUsers))
-	t.Log(rowsUsers)
-	mock.ExpectQuery(regexp.QuoteMeta(queryUsers)).WillReturnRows(rowsUsers)
+	// --- Queries: the masters the rows are validated against ---
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(true).WillReturnRows(mealOptionRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	// Every row is late, so the cooks are resolved: Mother cooks dinner on the 4th.
+	mock.ExpectQuery(regexp.QuoteMeta(getCookSchedulesQuery)).WithArgs("2024-02-04", "2024-02-05").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name"}).
+			AddRow("2024-02-04", 1, nil, nil).
+			AddRow("2024-02-04", 2, 5, "Mother").
+			AddRow("2024-02-05", 1, nil, nil).
+			AddRow("2024-02-05", 2, 1, "John"))
 
 	mock.ExpectBegin()
-//	prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO meals (user_id, date, lunch, dinner) VALUES ($1, $2, $3, $4) ON CONFLICT (user_id, date) DO UPDATE SET lunch = EXCLUDED.lunch, dinner = EXCLUDED.dinner"))
-	prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO meals (user_id, date, meal_period, meal_option) VALUES ($1, $2, $3, $4) ON CONFLICT (user_id, date, meal_period) DO UPDATE SET meal_option = EXCLUDED.meal_option"))
-	// Simulate two update records.
-	prep.ExpectExec().WithArgs(1, "2024-02-04", 1, 3).WillReturnResult(sqlmock.NewResult(1, 1))
-	prep.ExpectExec().WithArgs(1, "2024-02-04", 2, 2).WillReturnResult(sqlmock.NewResult(2, 1))
-	prep.ExpectExec().WithArgs(2, "2024-02-04", 1, 1).WillReturnResult(sqlmock.NewResult(3, 1))
-	prep.ExpectExec().WithArgs(2, "2024-02-04", 2, 3).WillReturnResult(sqlmock.NewResult(4, 1))
-	prep.ExpectExec().WithArgs(1, "2024-02-05", 1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
-	prep.ExpectExec().WithArgs(2, "2024-02-05", 2, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(bulkUpdateMealsStmt))
+	// Simulate two update records; each is logged as made by the caller through this endpoint.
+	// The dates are in the past, so dinner (cutoff 15:00, mode notify) is locked but accepted.
+	// Mother has to acknowledge dinner on the 4th; John cooks on the 5th and made the change himself.
+	src := "PUT /api/meals/bulk-update"
+	changed := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"changed"}).AddRow(true) }
+	prep.ExpectQuery().WithArgs(1, "2024-02-04", 1, 3, 1, src, nil, nil).WillReturnRows(changed())
+	prep.ExpectQuery().WithArgs(1, "2024-02-04", 2, 2, 1, src, "notify", 5).WillReturnRows(changed())
+	prep.ExpectQuery().WithArgs(2, "2024-02-04", 1, 1, 1, src, nil, nil).WillReturnRows(changed())
+	prep.ExpectQuery().WithArgs(2, "2024-02-04", 2, 3, 1, src, "notify", 5).WillReturnRows(changed())
+	prep.ExpectQuery().WithArgs(1, "2024-02-05", 1, 2, 1, src, nil, nil).WillReturnRows(changed())
+	prep.ExpectQuery().WithArgs(2, "2024-02-05", 2, 1, 1, src, "notify", nil).WillReturnRows(changed())
 	mock.ExpectCommit()
 
 	updates := []MealUpdate{
-		{UserID: 1, Date: "2024-02-04", Lunch: 3, Dinner: 2},
-		{UserID: 2, Date: "2024-02-04", Lunch: 1, Dinner: 3},
-		{UserID: 1, Date: "2024-02-05", Lunch: 2},
-		{UserID: 2, Date: "2024-02-05", Dinner: 1},
+		{UserID: 1, Date: "2024-02-04", Options: map[int]int{1: 3, 2: 2}},
+		{UserID: 2, Date: "2024-02-04", Options: map[int]int{1: 1, 2: 3}},
+		{UserID: 1, Date: "2024-02-05", Options: map[int]int{1: 2}},
+		{UserID: 2, Date: "2024-02-05", Options: map[int]int{2: 1}},
 	}
 	payload, _ := json.Marshal(updates)
 	r := setupRouter()
@@ -139,8 +216,17 @@ func TestBulkUpdateMeals(t *testing.T) {
 	r.ServeHTTP(w, req)
 	t.Log("\n", func() string { var b bytes.Buffer; json.Indent(&b, w.Body.Bytes(), "", "  "); return b.String() }())
 	assert.Equal(t, http.StatusOK, w.Code)
-	expectedResp := `{"message":"Meals updated"}`
-	assert.JSONEq(t, expectedResp, w.Body.String())
+	var resp struct {
+		Message string      `json:"message"`
+		Locked  []LockedRow `json:"locked"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Meals updated", resp.Message)
+	if assert.Len(t, resp.Locked, 3) {
+		assert.Equal(t, []int{0, 1, 3}, []int{resp.Locked[0].Index, resp.Locked[1].Index, resp.Locked[2].Index})
+		assert.Equal(t, cutoffNotify, resp.Locked[0].Mode)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // TestGetUserDefaults verifies the GET /api/user-defaults/:user_id endpoint.
@@ -150,16 +236,12 @@ func TestGetUserDefaults(t *testing.T) {
 	defer mockDB.Close()
 	db = mockDB
 
-	// Example scenario: for user_id = "4", return 7 default records.
-	rows := sqlmock.NewRows([]string{"day_of_week", "lunch", "dinner"}).
-		AddRow(0, 3, 1).
-		AddRow(1, 1, 2).
-		AddRow(2, 3, 1).
-		AddRow(3, 3, 1).
-		AddRow(4, 1, 3).
-		AddRow(5, 3, 1).
-		AddRow(6, 3, 1)
-	query := "SELECT day_of_week, lunch, dinner FROM user_defaults WHERE user_id = $1 ORDER BY day_of_week"
+	// Example scenario: for user_id = "4", return 7 days × 2 periods of default records.
+	rows := sqlmock.NewRows([]string{"day_of_week", "meal_period", "meal_option"})
+	for dow, pair := range [][2]int{{3, 1}, {1, 2}, {3, 1}, {3, 1}, {1, 3}, {3, 1}, {3, 1}} {
+		rows.AddRow(dow, 1, pair[0]).AddRow(dow, 2, pair[1])
+	}
+	query := getUserDefaultsQuery
 	t.Log(regexp.QuoteMeta(query))
 	t.Log(rows)
 	mock.ExpectQuery(regexp.QuoteMeta(query)).
@@ -175,13 +257,13 @@ func TestGetUserDefaults(t *testing.T) {
 
 	// Update expected JSON to include the user_id field.
 	expectedJSON := `[
-        {"day_of_week":0, "lunch":3, "dinner":1, "user_id":4},
-        {"day_of_week":1, "lunch":1, "dinner":2, "user_id":4},
-        {"day_of_week":2, "lunch":3, "dinner":1, "user_id":4},
-        {"day_of_week":3, "lunch":3, "dinner":1, "user_id":4},
-        {"day_of_week":4, "lunch":1, "dinner":3, "user_id":4},
-        {"day_of_week":5, "lunch":3, "dinner":1, "user_id":4},
-        {"day_of_week":6, "lunch":3, "dinner":1, "user_id":4}
+        {"day_of_week":0, "options":{"1":3, "2":1}, "user_id":4},
+        {"day_of_week":1, "options":{"1":1, "2":2}, "user_id":4},
+        {"day_of_week":2, "options":{"1":3, "2":1}, "user_id":4},
+        {"day_of_week":3, "options":{"1":3, "2":1}, "user_id":4},
+        {"day_of_week":4, "options":{"1":1, "2":3}, "user_id":4},
+        {"day_of_week":5, "options":{"1":3, "2":1}, "user_id":4},
+        {"day_of_week":6, "options":{"1":3, "2":1}, "user_id":4}
     ]`
 	assert.JSONEq(t, expectedJSON, w.Body.String())
 }
@@ -193,11 +275,12 @@ func TestGetUsers(t *testing.T) {
 	defer mockDB.Close()
 	db = mockDB
 
-	rows := sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater"}).
-		AddRow(1, "Mother", true, false).
-		AddRow(2, "Father", true, true).
-		AddRow(3, "Taro", false, true)
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name, is_cook, is_eater FROM users ORDER BY id")).
+	rows := sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}).
+		AddRow(1, "Mother", true, false, 1, true, true).
+		AddRow(2, "Father", true, true, 2, true, true).
+		AddRow(3, "Taro", false, true, 3, true, false)
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).
+		WithArgs(false).
 		WillReturnRows(rows)
 
 	r := setupRouter()
@@ -208,9 +291,9 @@ func TestGetUsers(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	expected := `[
-		{"id":1,"name":"Mother","is_cook":true,"is_eater":false},
-		{"id":2,"name":"Father","is_cook":true,"is_eater":true},
-		{"id":3,"name":"Taro","is_cook":false,"is_eater":true}
+		{"id":1,"name":"Mother","is_cook":true,"is_eater":false,"display_order":1,"active":true,"is_admin":true},
+		{"id":2,"name":"Father","is_cook":true,"is_eater":true,"display_order":2,"active":true,"is_admin":true},
+		{"id":3,"name":"Taro","is_cook":false,"is_eater":true,"display_order":3,"active":true,"is_admin":false}
 	]`
 	assert.JSONEq(t, expected, w.Body.String())
 }
@@ -222,9 +305,12 @@ func TestUpdateUserRoles(t *testing.T) {
 	defer mockDB.Close()
 	db = mockDB
 
-	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET is_cook = $1, is_eater = $2 WHERE id = $3")).
-		WithArgs(true, false, "1").
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateUserRolesStmt)).
+		WithArgs(true, false, nil, "1").
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(futureHostedGuestsQuery)).WithArgs("1").WillReturnRows(mealGuestRows())
+	mock.ExpectCommit()
 
 	payload := `{"is_cook":true,"is_eater":false}`
 	r := setupRouter()
@@ -244,9 +330,11 @@ func TestUpdateUserRolesNotFound(t *testing.T) {
 	defer mockDB.Close()
 	db = mockDB
 
-	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET is_cook = $1, is_eater = $2 WHERE id = $3")).
-		WithArgs(false, true, "99").
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(updateUserRolesStmt)).
+		WithArgs(false, true, nil, "99").
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
 
 	payload := `{"is_cook":false,"is_eater":true}`
 	r := setupRouter()
@@ -264,22 +352,22 @@ func TestUpdateUserDefaults(t *testing.T) {
 	defer mockDB.Close()
 	db = mockDB
 
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(true).WillReturnRows(mealOptionRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
 	mock.ExpectBegin()
-	prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO user_defaults (user_id, day_of_week, lunch, dinner) VALUES ($1, $2, $3, $4) ON CONFLICT (user_id, day_of_week) DO UPDATE SET lunch = EXCLUDED.lunch, dinner = EXCLUDED.dinner"))
-	// Simulate updating two default records.
-	prep.ExpectExec().WithArgs("4", 0, 3, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-	prep.ExpectExec().WithArgs("4", 1, 1, 2).WillReturnResult(sqlmock.NewResult(2, 1))
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(updateUserDefaultsStmt))
+	// Simulate updating two days × two periods of default records.
+	prep.ExpectExec().WithArgs(4, 0, 1, 3).WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs(4, 0, 2, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs(4, 1, 1, 1).WillReturnResult(sqlmock.NewResult(2, 1))
+	prep.ExpectExec().WithArgs(4, 1, 2, 2).WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
 
 	// Payload: two default settings.
-	defaults := []struct {
-		DayOfWeek int `json:"day_of_week"`
-		Lunch     int `json:"lunch"`
-		Dinner    int `json:"dinner"`
-		UserID    int `json:"user_id"`
-	}{
-		{DayOfWeek: 0, Lunch: 3, Dinner: 1, UserID: 4},
-		{DayOfWeek: 1, Lunch: 1, Dinner: 2, UserID: 4},
+	defaults := []UserDefault{
+		{DayOfWeek: 0, Options: map[int]int{1: 3, 2: 1}, UserID: 4},
+		{DayOfWeek: 1, Options: map[int]int{1: 1, 2: 2}, UserID: 4},
 	}
 	payload, _ := json.Marshal(defaults)
 	r := setupRouter()
@@ -320,8 +408,8 @@ func TestGetCookSchedules(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	expected := `{
-		"2026-04-06": {"lunch": {"cook_user_id": 5, "cook_user_name": "Mother"}, "dinner": null},
-		"2026-04-07": {"lunch": null, "dinner": {"cook_user_id": 2, "cook_user_name": "Father"}}
+		"2026-04-06": {"1": {"cook_user_id": 5, "cook_user_name": "Mother"}, "2": null},
+		"2026-04-07": {"1": null, "2": {"cook_user_id": 2, "cook_user_name": "Father"}}
 	}`
 	assert.JSONEq(t, expected, w.Body.String())
 }
@@ -333,10 +421,12 @@ func TestBulkUpdateCookSchedules(t *testing.T) {
 	defer mockDB.Close()
 	db = mockDB
 
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
 	mock.ExpectBegin()
 	prep := mock.ExpectPrepare(regexp.QuoteMeta(bulkUpdateCookSchedulesStmt))
-	prep.ExpectExec().WithArgs("2026-04-06", 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
-	prep.ExpectExec().WithArgs("2026-04-06", 2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs("2026-04-06", 1, sqlmock.AnyArg(), 1, "PUT /api/cook-schedules").WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs("2026-04-06", 2, sqlmock.AnyArg(), 1, "PUT /api/cook-schedules").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	cookID := 5
@@ -362,9 +452,10 @@ func TestDeleteCookSchedules(t *testing.T) {
 	defer mockDB.Close()
 	db = mockDB
 
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
 	mock.ExpectBegin()
 	prep := mock.ExpectPrepare(regexp.QuoteMeta(deleteCookSchedulesStmt))
-	prep.ExpectExec().WithArgs("2026-04-06", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WithArgs("2026-04-06", 1, 1, "DELETE /api/cook-schedules").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
 	entries := []CookScheduleDelete{{Date: "2026-04-06", MealPeriod: 1}}
@@ -408,17 +499,20 @@ func TestGetCookDefaultSchedules(t *testing.T) {
 	assert.JSONEq(t, expected, w.Body.String())
 }
 
-// TestUpdateCookDefaultSchedules verifies PUT /api/cook-default-schedules.
+// TestUpdateCookDefaultSchedules verifies PUT /api/cook-default-schedules logs each
+// change with the caller and the endpoint.
 func TestUpdateCookDefaultSchedules(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer mockDB.Close()
 	db = mockDB
 
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
 	mock.ExpectBegin()
 	prep := mock.ExpectPrepare(regexp.QuoteMeta(updateCookDefaultSchedulesStmt))
-	prep.ExpectExec().WithArgs(1, 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
-	prep.ExpectExec().WithArgs(1, 2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs(1, 1, 5, 1, "PUT /api/cook-default-schedules").WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs(1, 2, nil, 1, "PUT /api/cook-default-schedules").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	cookID := 5
@@ -435,4 +529,5 @@ func TestUpdateCookDefaultSchedules(t *testing.T) {
 	t.Log("\n", func() string { var b bytes.Buffer; json.Indent(&b, w.Body.Bytes(), "", "  "); return b.String() }())
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.JSONEq(t, `{"message":"Cook default schedules updated"}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MealOption is one row of the meal_options master (e.g. なし / 家 / 弁当).
+type MealOption struct {
+	ID         int     `json:"id"`
+	Label      string  `json:"label"`
+	SortOrder  int     `json:"sort_order"`
+	Color      *string `json:"color"`
+	EatsAtHome bool    `json:"eats_at_home"`
+	IsSkip     bool    `json:"is_skip"`
+	IsBento    bool    `json:"is_bento"`
+	Active     bool    `json:"active"`
+}
+
+// MealOptionCreate is the request body for POST /api/meal-options.
+type MealOptionCreate struct {
+	Label      string  `json:"label"`
+	SortOrder  *int    `json:"sort_order"` // nil = append to the end
+	Color      *string `json:"color"`
+	EatsAtHome bool    `json:"eats_at_home"`
+	IsSkip     bool    `json:"is_skip"`
+	IsBento    bool    `json:"is_bento"`
+}
+
+// MealOptionPatch is the request body for PATCH /api/meal-options/:option_id.
+// Nil fields are left unchanged.
+type MealOptionPatch struct {
+	Label      *string `json:"label"`
+	SortOrder  *int    `json:"sort_order"`
+	Color      *string `json:"color"`
+	EatsAtHome *bool   `json:"eats_at_home"`
+	IsSkip     *bool   `json:"is_skip"`
+	IsBento    *bool   `json:"is_bento"`
+	Active     *bool   `json:"active"`
+}
+
+// colorPattern restricts colours to #rrggbb so they can be used in CSS as-is.
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// getMealOptionsQuery lists options in display order; $1=true includes inactive options.
+const getMealOptionsQuery = `SELECT id, label, sort_order, color, eats_at_home, is_skip, is_bento, active
+FROM meal_options
+WHERE active OR $1
+ORDER BY sort_order, id`
+
+// loadMealOptions returns all meal options (including inactive ones) keyed by id.
+func loadMealOptions() (map[int]MealOption, error) {
+	options, err := queryMealOptions(true)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int]MealOption, len(options))
+	for _, o := range options {
+		result[o.ID] = o
+	}
+	return result, nil
+}
+
+func queryMealOptions(includeInactive bool) ([]MealOption, error) {
+	rows, err := db.Query(getMealOptionsQuery, includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	options := []MealOption{}
+	for rows.Next() {
+		o, err := scanMealOption(rows)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, o)
+	}
+	return options, rows.Err()
+}
+
+// getMealOptions returns active meal options in display order.
+// Pass include_inactive=true to also list retired options.
+func getMealOptions(c *gin.Context) {
+	options, err := queryMealOptions(c.Query("include_inactive") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+const createMealOptionStmt = `INSERT INTO meal_options (label, sort_order, color, eats_at_home, is_skip, is_bento)
+VALUES ($1, COALESCE($2::int, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM meal_options)), $3, $4, $5, $6)
+RETURNING id, label, sort_order, color, eats_at_home, is_skip, is_bento, active`
+
+// createMealOption adds a new meal option such as 外食.
+func createMealOption(c *gin.Context) {
+	var req MealOptionCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	label := strings.TrimSpace(req.Label)
+	if label == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "label is required"})
+		return
+	}
+	if req.Color != nil && !colorPattern.MatchString(*req.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "color must be in #rrggbb format"})
+		return
+	}
+	var color interface{}
+	if req.Color != nil {
+		color = *req.Color
+	}
+	row := db.QueryRow(createMealOptionStmt, label, nullableInt(req.SortOrder), color, req.EatsAtHome, req.IsSkip, req.IsBento)
+	o, err := scanMealOption(row)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, o)
+}
+
+const updateMealOptionStmt = `UPDATE meal_options SET
+    label        = COALESCE($1::text, label),
+    sort_order   = COALESCE($2::int, sort_order),
+    color        = CASE WHEN $3::bool THEN $4::text ELSE color END,
+    eats_at_home = COALESCE($5::bool, eats_at_home),
+    is_skip      = COALESCE($6::bool, is_skip),
+    is_bento     = COALESCE($7::bool, is_bento),
+    active       = COALESCE($8::bool, active)
+WHERE id = $9
+RETURNING id, label, sort_order, color, eats_at_home, is_skip, is_bento, active`
+
+// updateMealOption changes the label, order, colour or flags of a meal option.
+// Options are retired with active=false rather than deleted, since meals reference them.
+// An empty color string clears the colour.
+func updateMealOption(c *gin.Context) {
+	optionID := c.Param("option_id")
+	var req MealOptionPatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var label interface{}
+	if req.Label != nil {
+		trimmed := strings.TrimSpace(*req.Label)
+		if trimmed == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "label must not be empty"})
+			return
+		}
+		label = trimmed
+	}
+	var color interface{}
+	if req.Color != nil && *req.Color != "" {
+		if !colorPattern.MatchString(*req.Color) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "color must be in #rrggbb format"})
+			return
+		}
+		color = *req.Color
+	}
+	row := db.QueryRow(updateMealOptionStmt, label, nullableInt(req.SortOrder), req.Color != nil, color,
+		nullableBool(req.EatsAtHome), nullableBool(req.IsSkip), nullableBool(req.IsBento), nullableBool(req.Active), optionID)
+	o, err := scanMealOption(row)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "meal option not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, o)
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMealOption(row rowScanner) (MealOption, error) {
+	var o MealOption
+	var color sql.NullString
+	if err := row.Scan(&o.ID, &o.Label, &o.SortOrder, &color, &o.EatsAtHome, &o.IsSkip, &o.IsBento, &o.Active); err != nil {
+		return o, err
+	}
+	if color.Valid {
+		o.Color = &color.String
+	}
+	return o, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// mealOptionRows returns the standard なし/家/弁当 options as returned by getMealOptionsQuery.
+func mealOptionRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "label", "sort_order", "color", "eats_at_home", "is_skip", "is_bento", "active"}).
+		AddRow(1, "なし", 1, nil, false, true, false, true).
+		AddRow(2, "家", 2, "#c8e6c9", true, false, false, true).
+		AddRow(3, "弁当", 3, "#ffe0b2", false, false, true, true)
+}
+
+// TestGetMealOptions verifies GET /api/meal-options returns the master in display order.
+func TestGetMealOptions(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(false).WillReturnRows(mealOptionRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/meal-options", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"id":1,"label":"なし","sort_order":1,"color":null,"eats_at_home":false,"is_skip":true,"is_bento":false,"active":true},
+		{"id":2,"label":"家","sort_order":2,"color":"#c8e6c9","eats_at_home":true,"is_skip":false,"is_bento":false,"active":true},
+		{"id":3,"label":"弁当","sort_order":3,"color":"#ffe0b2","eats_at_home":false,"is_skip":false,"is_bento":true,"active":true}
+	]`, w.Body.String())
+}
+
+// TestCreateMealOption verifies POST /api/meal-options.
+func TestCreateMealOption(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(createMealOptionStmt)).
+		WithArgs("外食", nil, "#bbdefb", false, false, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "label", "sort_order", "color", "eats_at_home", "is_skip", "is_bento", "active"}).
+			AddRow(4, "外食", 4, "#bbdefb", false, false, false, true))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/meal-options", bytes.NewBufferString(`{"label":"外食","color":"#bbdefb"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":4,"label":"外食","sort_order":4,"color":"#bbdefb","eats_at_home":false,"is_skip":false,"is_bento":false,"active":true}`, w.Body.String())
+}
+
+// TestCreateMealOptionInvalidColor verifies that colours outside #rrggbb are rejected.
+func TestCreateMealOptionInvalidColor(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/meal-options", bytes.NewBufferString(`{"label":"外食","color":"red;"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateMealOption verifies PATCH /api/meal-options/:option_id retiring an option.
+func TestUpdateMealOption(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(updateMealOptionStmt)).
+		WithArgs(nil, nil, false, nil, nil, nil, nil, false, "3").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "label", "sort_order", "color", "eats_at_home", "is_skip", "is_bento", "active"}).
+			AddRow(3, "弁当", 3, nil, false, false, true, false))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/api/meal-options/3", bytes.NewBufferString(`{"active":false}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":3,"label":"弁当","sort_order":3,"color":null,"eats_at_home":false,"is_skip":false,"is_bento":true,"active":false}`, w.Body.String())
+}
+
+// TestBulkUpdateMealsInvalidOption verifies that an unknown option id is rejected
+// before the transaction starts.
+func TestBulkUpdateMealsInvalidOption(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(true).WillReturnRows(mealOptionRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/meals/bulk-update",
+		bytes.NewBufferString(`[{"user_id":1,"date":"2024-02-04","options":{"1":9}}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[{"index":0,"field":"options.1","message":"unknown meal option 9"}]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
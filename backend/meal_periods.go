@@ -0,0 +1,216 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MealPeriod is one row of the meal_periods master (e.g. 朝食 / 昼食 / 夕食).
+type MealPeriod struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	SortOrder  int     `json:"sort_order"`
+	CutoffTime *string `json:"cutoff_time"` // "HH:MM" local time, nil = no cutoff
+	CutoffMode string  `json:"cutoff_mode"` // reject / acknowledge / notify, see cutoffs.go
+	PacksBento bool    `json:"packs_bento"` // its cook packs the 弁当, see bento.go
+	Active     bool    `json:"active"`
+}
+
+// MealPeriodCreate is the request body for POST /api/meal-periods.
+type MealPeriodCreate struct {
+	Name       string  `json:"name"`
+	SortOrder  *int    `json:"sort_order"` // nil = append to the end
+	CutoffTime *string `json:"cutoff_time"`
+	CutoffMode *string `json:"cutoff_mode"` // nil = notify
+	PacksBento bool    `json:"packs_bento"`
+}
+
+// MealPeriodPatch is the request body for PATCH /api/meal-periods/:period_id.
+// Nil fields are left unchanged.
+type MealPeriodPatch struct {
+	Name       *string `json:"name"`
+	SortOrder  *int    `json:"sort_order"`
+	CutoffTime *string `json:"cutoff_time"` // "" clears the cutoff
+	CutoffMode *string `json:"cutoff_mode"`
+	PacksBento *bool   `json:"packs_bento"`
+	Active     *bool   `json:"active"`
+}
+
+// getMealPeriodsQuery lists periods in display order; $1=true includes inactive periods.
+const getMealPeriodsQuery = `SELECT id, name, sort_order, TO_CHAR(cutoff_time, 'HH24:MI'), cutoff_mode, packs_bento, active
+FROM meal_periods
+WHERE active OR $1
+ORDER BY sort_order, id`
+
+// loadMealPeriods returns all meal periods (including inactive ones) keyed by id.
+func loadMealPeriods() (map[int]MealPeriod, error) {
+	periods, err := queryMealPeriods(true)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int]MealPeriod, len(periods))
+	for _, p := range periods {
+		result[p.ID] = p
+	}
+	return result, nil
+}
+
+func queryMealPeriods(includeInactive bool) ([]MealPeriod, error) {
+	rows, err := db.Query(getMealPeriodsQuery, includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	periods := []MealPeriod{}
+	for rows.Next() {
+		p, err := scanMealPeriod(rows)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+// getMealPeriods returns active meal periods in display order.
+// Pass include_inactive=true to also list retired periods.
+func getMealPeriods(c *gin.Context) {
+	periods, err := queryMealPeriods(c.Query("include_inactive") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, periods)
+}
+
+const createMealPeriodStmt = `INSERT INTO meal_periods (name, sort_order, cutoff_time, cutoff_mode, packs_bento)
+VALUES ($1, COALESCE($2::int, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM meal_periods)), $3::time, COALESCE($4::text, 'notify'), $5)
+RETURNING id, name, sort_order, TO_CHAR(cutoff_time, 'HH24:MI'), cutoff_mode, packs_bento, active`
+
+// createMealPeriod adds a new meal period such as 朝食 or 夜食.
+func createMealPeriod(c *gin.Context) {
+	var req MealPeriodCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	var cutoff interface{}
+	if req.CutoffTime != nil {
+		if !validCutoffTime(*req.CutoffTime) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cutoff_time must be in HH:MM format"})
+			return
+		}
+		cutoff = *req.CutoffTime
+	}
+	var mode interface{}
+	if req.CutoffMode != nil {
+		if !validCutoffMode(*req.CutoffMode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cutoff_mode must be reject, acknowledge or notify"})
+			return
+		}
+		mode = *req.CutoffMode
+	}
+	row := db.QueryRow(createMealPeriodStmt, name, nullableInt(req.SortOrder), cutoff, mode, req.PacksBento)
+	p, err := scanMealPeriod(row)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, p)
+}
+
+const updateMealPeriodStmt = `UPDATE meal_periods SET
+    name        = COALESCE($1::text, name),
+    sort_order  = COALESCE($2::int, sort_order),
+    cutoff_time = CASE WHEN $3::bool THEN $4::time ELSE cutoff_time END,
+    cutoff_mode = COALESCE($5::text, cutoff_mode),
+    packs_bento = COALESCE($6::bool, packs_bento),
+    active      = COALESCE($7::bool, active)
+WHERE id = $8
+RETURNING id, name, sort_order, TO_CHAR(cutoff_time, 'HH24:MI'), cutoff_mode, packs_bento, active`
+
+// updateMealPeriod renames, reorders or retires a meal period.
+// Periods are retired with active=false rather than deleted, since meals reference them.
+func updateMealPeriod(c *gin.Context) {
+	periodID := c.Param("period_id")
+	var req MealPeriodPatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var name interface{}
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		if trimmed == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+			return
+		}
+		name = trimmed
+	}
+	var cutoff interface{}
+	if req.CutoffTime != nil && *req.CutoffTime != "" {
+		if !validCutoffTime(*req.CutoffTime) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cutoff_time must be in HH:MM format"})
+			return
+		}
+		cutoff = *req.CutoffTime
+	}
+	var mode interface{}
+	if req.CutoffMode != nil {
+		if !validCutoffMode(*req.CutoffMode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cutoff_mode must be reject, acknowledge or notify"})
+			return
+		}
+		mode = *req.CutoffMode
+	}
+	row := db.QueryRow(updateMealPeriodStmt, name, nullableInt(req.SortOrder), req.CutoffTime != nil, cutoff,
+		mode, nullableBool(req.PacksBento), nullableBool(req.Active), periodID)
+	p, err := scanMealPeriod(row)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "meal period not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func validCutoffTime(s string) bool {
+	_, err := time.Parse("15:04", s)
+	return err == nil
+}
+
+func scanMealPeriod(row rowScanner) (MealPeriod, error) {
+	var p MealPeriod
+	var cutoff sql.NullString
+	if err := row.Scan(&p.ID, &p.Name, &p.SortOrder, &cutoff, &p.CutoffMode, &p.PacksBento, &p.Active); err != nil {
+		return p, err
+	}
+	if cutoff.Valid {
+		p.CutoffTime = &cutoff.String
+	}
+	return p, nil
+}
+
+// sortedPeriodIDs returns the keys of a per-period map in ascending order,
+// so statements are executed in a deterministic order.
+func sortedPeriodIDs(m map[int]int) []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
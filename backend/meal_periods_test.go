@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// mealPeriodRows returns the standard 昼食/夕食 periods as returned by getMealPeriodsQuery.
+func mealPeriodRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "sort_order", "cutoff_time", "cutoff_mode", "packs_bento", "active"}).
+		AddRow(1, "昼食", 2, nil, "notify", true, true).
+		AddRow(2, "夕食", 3, "15:00", "notify", false, true)
+}
+
+// TestGetMealPeriods verifies GET /api/meal-periods returns the master in display order.
+func TestGetMealPeriods(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(false).WillReturnRows(mealPeriodRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/meal-periods", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"id":1,"name":"昼食","sort_order":2,"cutoff_time":null,"cutoff_mode":"notify","packs_bento":true,"active":true},
+		{"id":2,"name":"夕食","sort_order":3,"cutoff_time":"15:00","cutoff_mode":"notify","packs_bento":false,"active":true}
+	]`, w.Body.String())
+}
+
+// TestCreateMealPeriod verifies POST /api/meal-periods.
+func TestCreateMealPeriod(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(createMealPeriodStmt)).
+		WithArgs("朝食", 1, "06:30", "reject", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sort_order", "cutoff_time", "cutoff_mode", "packs_bento", "active"}).
+			AddRow(3, "朝食", 1, "06:30", "reject", false, true))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/meal-periods", bytes.NewBufferString(`{"name":"朝食","sort_order":1,"cutoff_time":"06:30","cutoff_mode":"reject"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":3,"name":"朝食","sort_order":1,"cutoff_time":"06:30","cutoff_mode":"reject","packs_bento":false,"active":true}`, w.Body.String())
+}
+
+// TestCreateMealPeriodInvalidCutoff verifies that cutoff_time must be HH:MM.
+func TestCreateMealPeriodInvalidCutoff(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/meal-periods", bytes.NewBufferString(`{"name":"夜食","cutoff_time":"25:00"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateMealPeriodClearCutoff verifies that an empty cutoff_time clears the cutoff.
+func TestUpdateMealPeriodClearCutoff(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(updateMealPeriodStmt)).
+		WithArgs(nil, nil, true, nil, nil, nil, nil, "2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sort_order", "cutoff_time", "cutoff_mode", "packs_bento", "active"}).
+			AddRow(2, "夕食", 3, nil, "notify", false, true))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/api/meal-periods/2", bytes.NewBufferString(`{"cutoff_time":""}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":2,"name":"夕食","sort_order":3,"cutoff_time":null,"cutoff_mode":"notify","packs_bento":false,"active":true}`, w.Body.String())
+}
+
+// TestBulkUpdateMealsInvalidPeriod verifies that an unknown meal period is rejected
+// before the transaction starts.
+func TestBulkUpdateMealsInvalidPeriod(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(true).WillReturnRows(mealOptionRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/meals/bulk-update",
+		bytes.NewBufferString(`[{"user_id":1,"date":"2024-02-04","options":{"7":2}}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[{"index":0,"field":"options.7","message":"unknown meal period 7"}]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// MenuEntry is one period of GET /api/menus: the resolved cook (nil = 各自) and what
+// they plan to serve. Dishes and Ingredients are empty and the rest nil when no menu is
+// set.
+type MenuEntry struct {
+	Cook        *CookAssignment  `json:"cook"`
+	Dishes      []string         `json:"dishes"`
+	Notes       *string          `json:"notes"`
+	RecipeURL   *string          `json:"recipe_url"`
+	Ingredients []MenuIngredient `json:"ingredients"`
+}
+
+// MenuIngredient is what one serving of a dish on the menu needs. Dish is one of the
+// menu's dishes; Unit may be empty for countable things ("卵 1").
+type MenuIngredient struct {
+	Dish     string  `json:"dish"`
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+}
+
+// MenuUpdate is one element of the PUT /api/menus request body. It replaces the menu
+// of its date and period, ingredients included; with no dishes, notes or recipe_url
+// the menu is removed. Each of RecipeIDs adds the recipe's title to Dishes and its
+// ingredients, per serving, to Ingredients.
+type MenuUpdate struct {
+	Date        string           `json:"date"`
+	MealPeriod  int              `json:"meal_period"`
+	Dishes      []string         `json:"dishes"`
+	Notes       *string          `json:"notes"`
+	RecipeURL   *string          `json:"recipe_url"`
+	Ingredients []MenuIngredient `json:"ingredients"`
+	RecipeIDs   []int            `json:"recipe_ids"`
+}
+
+// getMenusQuery lists every active period of every day in [$1, $2] with its cook,
+// resolved by getCookSchedulesQuery itself, and its menu if one is set.
+const getMenusQuery = `WITH cooks (date, meal_period, cook_user_id, cook_user_name) AS (` + getCookSchedulesQuery + `
+)
+SELECT k.date, k.meal_period, k.cook_user_id, k.cook_user_name,
+    COALESCE(mn.dishes, '{}'), mn.notes, mn.recipe_url
+FROM cooks k
+LEFT JOIN menus mn ON mn.date = k.date::date AND mn.meal_period = k.meal_period`
+
+// upsertMenuStmt replaces the menu of ($1, $2); $6 is the member who wrote it.
+const upsertMenuStmt = `INSERT INTO menus (date, meal_period, dishes, notes, recipe_url, updated_by)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (date, meal_period) DO UPDATE SET
+    dishes = EXCLUDED.dishes, notes = EXCLUDED.notes, recipe_url = EXCLUDED.recipe_url,
+    updated_by = EXCLUDED.updated_by, updated_at = now()`
+
+const deleteMenuStmt = "DELETE FROM menus WHERE date = $1 AND meal_period = $2"
+
+// getMenuIngredientsQuery lists the ingredients of the menus in [$1, $2] in the order
+// they were entered.
+const getMenuIngredientsQuery = `SELECT TO_CHAR(date, 'YYYY-MM-DD'), meal_period, dish, name, quantity, unit
+FROM menu_ingredients
+WHERE date BETWEEN $1 AND $2
+ORDER BY date, meal_period, id`
+
+const deleteMenuIngredientsStmt = "DELETE FROM menu_ingredients WHERE date = $1 AND meal_period = $2"
+
+const insertMenuIngredientStmt = `INSERT INTO menu_ingredients (date, meal_period, dish, name, quantity, unit)
+VALUES ($1, $2, $3, $4, $5, $6)`
+
+// getMenus returns the menus of a date range keyed by date and then meal period id,
+// alongside the cook of each period and the ingredients of each menu.
+func getMenus(c *gin.Context) {
+	startDate, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter. Must be a positive integer."})
+		return
+	}
+	endDate := startDate.AddDate(0, 0, days-1).Format("2006-01-02")
+
+	ingredients, err := loadMenuIngredients(startDate.Format("2006-01-02"), endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rows, err := db.Query(getMenusQuery, startDate.Format("2006-01-02"), endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	result := make(map[string]map[int]*MenuEntry)
+	for rows.Next() {
+		var dateStr string
+		var periodID int
+		var cookUserID sql.NullInt64
+		var cookUserName, notes, recipeURL sql.NullString
+		e := &MenuEntry{}
+		if err := rows.Scan(&dateStr, &periodID, &cookUserID, &cookUserName,
+			pq.Array(&e.Dishes), &notes, &recipeURL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if cookUserID.Valid {
+			e.Cook = &CookAssignment{CookUserID: int(cookUserID.Int64), CookUserName: cookUserName.String}
+		}
+		if e.Dishes == nil {
+			e.Dishes = []string{}
+		}
+		e.Notes, e.RecipeURL = nullString(notes), nullString(recipeURL)
+		if e.Ingredients = ingredients[dateStr][periodID]; e.Ingredients == nil {
+			e.Ingredients = []MenuIngredient{}
+		}
+		if _, ok := result[dateStr]; !ok {
+			result[dateStr] = make(map[int]*MenuEntry)
+		}
+		result[dateStr][periodID] = e
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// bulkUpdateMenus writes the menus of several periods in one transaction. Only the
+// cook of a period may set its menu; admins may set any, including 各自 periods.
+func bulkUpdateMenus(c *gin.Context) {
+	var updates []MenuUpdate
+	if err := c.ShouldBindJSON(&updates); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	v, err := newValidator(validatePeriods)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var recipeIDs []int
+	for _, m := range updates {
+		recipeIDs = append(recipeIDs, m.RecipeIDs...)
+	}
+	recipes, err := loadRecipes(recipeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range updates {
+		for _, id := range updates[i].RecipeIDs {
+			if r, ok := recipes[id]; ok {
+				updates[i].addRecipe(r)
+			} else {
+				v.fail(i, "recipe_ids", "unknown recipe %d", id)
+			}
+		}
+	}
+	for i, m := range updates {
+		v.date(i, "date", m.Date)
+		v.period(i, "meal_period", m.MealPeriod, false)
+		if m.RecipeURL != nil && strings.TrimSpace(*m.RecipeURL) != "" {
+			v.webURL(i, "recipe_url", strings.TrimSpace(*m.RecipeURL))
+		}
+		dishes := textItems(m.Dishes)
+		for j, in := range m.Ingredients {
+			v.ingredient(i, j, dishes, in)
+		}
+	}
+	if v.respond(c) {
+		return
+	}
+
+	caller := currentUser(c)
+	denied, err := forbiddenMenuUpdates(caller, updates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(denied) > 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the cook of a period can edit its menu", "rows": denied})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	upsert, err := tx.Prepare(upsertMenuStmt)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer upsert.Close()
+	remove, err := tx.Prepare(deleteMenuStmt)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer remove.Close()
+	removeIngredients, err := tx.Prepare(deleteMenuIngredientsStmt)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer removeIngredients.Close()
+	addIngredient, err := tx.Prepare(insertMenuIngredientStmt)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer addIngredient.Close()
+	for _, m := range updates {
+		dishes := textItems(m.Dishes)
+		notes, recipeURL := optionalText(m.Notes), optionalText(m.RecipeURL)
+		if len(dishes) == 0 && notes == nil && recipeURL == nil {
+			// Ingredients go with the menu (ON DELETE CASCADE).
+			_, err = remove.Exec(m.Date, m.MealPeriod)
+		} else {
+			err = writeMenu(upsert, removeIngredients, addIngredient, m, dishes, notes, recipeURL, caller.ID)
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Menus updated"})
+}
+
+// addRecipe puts a recipe from the library on the menu, scaling its ingredients down
+// to one serving.
+func (m *MenuUpdate) addRecipe(r Recipe) {
+	m.Dishes = append(m.Dishes, r.Title)
+	for _, in := range r.Ingredients {
+		m.Ingredients = append(m.Ingredients, MenuIngredient{
+			Dish: r.Title, Name: in.Name, Quantity: in.Quantity / float64(r.Servings), Unit: in.Unit})
+	}
+}
+
+// writeMenu upserts one menu and replaces its ingredients with the statements of
+// bulkUpdateMenus.
+func writeMenu(upsert, removeIngredients, addIngredient *sql.Stmt, m MenuUpdate, dishes []string, notes, recipeURL interface{}, by int) error {
+	if _, err := upsert.Exec(m.Date, m.MealPeriod, pq.Array(dishes), notes, recipeURL, by); err != nil {
+		return err
+	}
+	if _, err := removeIngredients.Exec(m.Date, m.MealPeriod); err != nil {
+		return err
+	}
+	for _, in := range m.Ingredients {
+		if _, err := addIngredient.Exec(m.Date, m.MealPeriod, strings.TrimSpace(in.Dish), strings.TrimSpace(in.Name),
+			in.Quantity, strings.TrimSpace(in.Unit)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadMenuIngredients returns the ingredients of the menus in [from, to] keyed by date
+// and then period id.
+func loadMenuIngredients(from, to string) (map[string]map[int][]MenuIngredient, error) {
+	rows, err := db.Query(getMenuIngredientsQuery, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := map[string]map[int][]MenuIngredient{}
+	for rows.Next() {
+		var date string
+		var periodID int
+		var in MenuIngredient
+		if err := rows.Scan(&date, &periodID, &in.Dish, &in.Name, &in.Quantity, &in.Unit); err != nil {
+			return nil, err
+		}
+		if result[date] == nil {
+			result[date] = map[int][]MenuIngredient{}
+		}
+		result[date][periodID] = append(result[date][periodID], in)
+	}
+	return result, rows.Err()
+}
+
+// forbiddenMenuUpdates returns the rows of a menu update whose period the caller does
+// not cook. Admins may write every row. Dates must already be validated.
+func forbiddenMenuUpdates(caller User, updates []MenuUpdate) ([]ForbiddenRow, error) {
+	if caller.IsAdmin || len(updates) == 0 {
+		return nil, nil
+	}
+	from, to := updates[0].Date, updates[0].Date
+	for _, m := range updates {
+		if m.Date < from {
+			from = m.Date
+		}
+		if m.Date > to {
+			to = m.Date
+		}
+	}
+	cooks, err := loadCookAssignments(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var denied []ForbiddenRow
+	for i, m := range updates {
+		if a := cooks[m.Date][m.MealPeriod]; a == nil || a.CookUserID != caller.ID {
+			denied = append(denied, ForbiddenRow{Index: i, UserID: caller.ID, Date: m.Date,
+				Reason: "you do not cook this period"})
+		}
+	}
+	return denied, nil
+}
+
+// webURL checks an absolute http or https URL.
+func (v *validator) webURL(i int, field, s string) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.fail(i, field, "must be an http or https URL")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetMenus verifies every period is listed with its cook and ingredients, and
+// periods without a menu come back empty.
+func TestGetMenus(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMenuIngredientsQuery)).WithArgs("2025-02-16", "2025-02-16").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "dish", "name", "quantity", "unit"}).
+			AddRow("2025-02-16", 2, "カレー", "豚肉", 80.0, "g").
+			AddRow("2025-02-16", 2, "カレー", "玉ねぎ", 0.5, "個"))
+	mock.ExpectQuery(regexp.QuoteMeta(getMenusQuery)).WithArgs("2025-02-16", "2025-02-16").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name", "dishes", "notes", "recipe_url"}).
+			AddRow("2025-02-16", 1, nil, nil, "{}", nil, nil).
+			AddRow("2025-02-16", 2, 5, "Mother", "{カレー,サラダ}", "辛さ控えめ", "https://example.com/curry"))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/menus?date=2025-02-16&days=1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"2025-02-16": {
+		"1": {"cook": null, "dishes": [], "notes": null, "recipe_url": null, "ingredients": []},
+		"2": {"cook": {"cook_user_id":5,"cook_user_name":"Mother"}, "dishes": ["カレー","サラダ"], "notes": "辛さ控えめ", "recipe_url": "https://example.com/curry",
+			"ingredients": [
+				{"dish":"カレー","name":"豚肉","quantity":80,"unit":"g"},
+				{"dish":"カレー","name":"玉ねぎ","quantity":0.5,"unit":"個"}
+			]}
+	}}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBulkUpdateMenus verifies the cook of a period can write its menu, replacing its
+// ingredients, and that an emptied menu is deleted rather than stored.
+func TestBulkUpdateMenus(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 5, Name: "Mother", IsCook: true, Active: true})
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getCookSchedulesQuery)).WithArgs("2025-02-16", "2025-02-17").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name"}).
+			AddRow("2025-02-16", 1, nil, nil).
+			AddRow("2025-02-16", 2, 5, "Mother").
+			AddRow("2025-02-17", 1, nil, nil).
+			AddRow("2025-02-17", 2, 5, "Mother"))
+	mock.ExpectBegin()
+	upsert := mock.ExpectPrepare(regexp.QuoteMeta(upsertMenuStmt))
+	remove := mock.ExpectPrepare(regexp.QuoteMeta(deleteMenuStmt))
+	removeIngredients := mock.ExpectPrepare(regexp.QuoteMeta(deleteMenuIngredientsStmt))
+	addIngredient := mock.ExpectPrepare(regexp.QuoteMeta(insertMenuIngredientStmt))
+	upsert.ExpectExec().WithArgs("2025-02-16", 2, pq.Array([]string{"カレー", "サラダ"}), nil, "https://example.com/curry", 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	removeIngredients.ExpectExec().WithArgs("2025-02-16", 2).WillReturnResult(sqlmock.NewResult(0, 3))
+	addIngredient.ExpectExec().WithArgs("2025-02-16", 2, "カレー", "豚肉", 80.0, "g").WillReturnResult(sqlmock.NewResult(1, 1))
+	remove.ExpectExec().WithArgs("2025-02-17", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/menus", bytes.NewBufferString(`[
+		{"date":"2025-02-16","meal_period":2,"dishes":["カレー"," サラダ",""],"recipe_url":"https://example.com/curry",
+		 "ingredients":[{"dish":"カレー","name":" 豚肉","quantity":80,"unit":"g "}]},
+		{"date":"2025-02-17","meal_period":2,"dishes":[],"notes":" "}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBulkUpdateMenusRejected verifies bad recipe links and ingredients are a 422, and
+// that members cannot write the menu of a period they do not cook, including 各自
+// periods.
+func TestBulkUpdateMenusRejected(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/menus",
+		bytes.NewBufferString(`[{"date":"2025-02-16","meal_period":2,"dishes":["カレー"],"recipe_url":"javascript:alert(1)",
+			"ingredients":[{"dish":"シチュー","name":"","quantity":0}]}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[
+		{"index":0,"field":"recipe_url","message":"must be an http or https URL"},
+		{"index":0,"field":"ingredients.0.dish","message":"must be one of the menu's dishes"},
+		{"index":0,"field":"ingredients.0.name","message":"must not be empty"},
+		{"index":0,"field":"ingredients.0.quantity","message":"must be greater than 0"}
+	]}`, w.Body.String())
+
+	asCaller(t, User{ID: 5, Name: "Mother", IsCook: true, Active: true})
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getCookSchedulesQuery)).WithArgs("2025-02-16", "2025-02-16").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name"}).
+			AddRow("2025-02-16", 1, nil, nil).
+			AddRow("2025-02-16", 2, 1, "John"))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/menus", bytes.NewBufferString(`[
+		{"date":"2025-02-16","meal_period":1,"dishes":["うどん"]},
+		{"date":"2025-02-16","meal_period":2,"dishes":["カレー"]}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error":"only the cook of a period can edit its menu","rows":[
+		{"index":0,"user_id":5,"date":"2025-02-16","reason":"you do not cook this period"},
+		{"index":1,"user_id":5,"date":"2025-02-16","reason":"you do not cook this period"}
+	]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBulkUpdateMenusFromRecipe verifies a recipe from the library adds its title and
+// per-serving ingredients, and that unknown recipes are a 422.
+func TestBulkUpdateMenusFromRecipe(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getRecipesByIDQuery)).WithArgs(pq.Array([]int{4})).
+		WillReturnRows(recipeRows().AddRow(4, "卵焼き", 2, 10, "{弁当向け}", "{}", 5))
+	mock.ExpectQuery(regexp.QuoteMeta(getRecipeIngredientsQuery)).WithArgs(pq.Array([]int64{4})).
+		WillReturnRows(recipeIngredientRows().AddRow(4, "卵", 3.0, "個"))
+	mock.ExpectBegin()
+	upsert := mock.ExpectPrepare(regexp.QuoteMeta(upsertMenuStmt))
+	mock.ExpectPrepare(regexp.QuoteMeta(deleteMenuStmt))
+	removeIngredients := mock.ExpectPrepare(regexp.QuoteMeta(deleteMenuIngredientsStmt))
+	addIngredient := mock.ExpectPrepare(regexp.QuoteMeta(insertMenuIngredientStmt))
+	upsert.ExpectExec().WithArgs("2025-02-17", 1, pq.Array([]string{"おにぎり", "卵焼き"}), nil, nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	removeIngredients.ExpectExec().WithArgs("2025-02-17", 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	addIngredient.ExpectExec().WithArgs("2025-02-17", 1, "卵焼き", "卵", 1.5, "個").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/menus",
+		bytes.NewBufferString(`[{"date":"2025-02-17","meal_period":1,"dishes":["おにぎり"],"recipe_ids":[4]}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getRecipesByIDQuery)).WithArgs(pq.Array([]int{9})).WillReturnRows(recipeRows())
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/menus",
+		bytes.NewBufferString(`[{"date":"2025-02-17","meal_period":1,"recipe_ids":[9]}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[
+		{"index":0,"field":"recipe_ids","message":"unknown recipe 9"}
+	]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
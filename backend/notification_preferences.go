@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// NotificationPreference is a member's notification settings, read and written by
+// GET/PUT /api/users/:user_id/notification-preferences. They apply to events
+// addressed to the member, not to household-wide ones. Events limits the kinds they
+// get (empty = all); ChannelID sends them to one channel only (nil = every channel);
+// between QuietStart and QuietEnd ("HH:MM" local time, may wrap midnight) their events
+// are held and sent once it ends; SlackMemberID is used to @-mention them in Slack.
+type NotificationPreference struct {
+	UserID        int      `json:"user_id"`
+	Events        []string `json:"events"`
+	ChannelID     *int     `json:"channel_id"`
+	QuietStart    *string  `json:"quiet_start"`
+	QuietEnd      *string  `json:"quiet_end"`
+	SlackMemberID *string  `json:"slack_member_id"`
+}
+
+// NotificationPreferenceView is the response of GET
+// /api/users/:user_id/notification-preferences: the preferences and the active
+// channels the member may choose from.
+type NotificationPreferenceView struct {
+	NotificationPreference
+	Channels []NotificationChannelChoice `json:"channels"`
+}
+
+// NotificationChannelChoice is an active channel as members see it, without its
+// settings.
+type NotificationChannelChoice struct {
+	ID   int    `json:"id"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// slackMemberIDPattern matches Slack member ids such as U012AB3CD.
+var slackMemberIDPattern = regexp.MustCompile(`^[UW][A-Z0-9]{2,}$`)
+
+// getNotificationPreferenceQuery returns member $1's preferences, or empty values when
+// they have none.
+const getNotificationPreferenceQuery = `SELECT u.id, COALESCE(p.events, '{}'), p.channel_id,
+    TO_CHAR(p.quiet_start, 'HH24:MI'), TO_CHAR(p.quiet_end, 'HH24:MI'), p.slack_member_id
+FROM users u
+LEFT JOIN notification_preferences p ON p.user_id = u.id
+WHERE u.id = $1`
+
+const getNotificationPreferencesQuery = `SELECT user_id, events, channel_id,
+    TO_CHAR(quiet_start, 'HH24:MI'), TO_CHAR(quiet_end, 'HH24:MI'), slack_member_id
+FROM notification_preferences`
+
+const updateNotificationPreferenceStmt = `INSERT INTO notification_preferences
+    (user_id, events, channel_id, quiet_start, quiet_end, slack_member_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id) DO UPDATE SET
+    events = EXCLUDED.events, channel_id = EXCLUDED.channel_id, quiet_start = EXCLUDED.quiet_start,
+    quiet_end = EXCLUDED.quiet_end, slack_member_id = EXCLUDED.slack_member_id, updated_at = now()`
+
+// getNotificationPreferences returns a member's notification preferences. Members may
+// only read their own; admins may read anyone's.
+func getNotificationPreferences(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if caller := currentUser(c); !caller.IsAdmin && userID != caller.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only see your own notification preferences"})
+		return
+	}
+	p, err := scanNotificationPreference(db.QueryRow(getNotificationPreferenceQuery, userID))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	channels, err := loadNotificationChannels()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	view := NotificationPreferenceView{NotificationPreference: p, Channels: []NotificationChannelChoice{}}
+	for _, ch := range channels {
+		if ch.Active {
+			view.Channels = append(view.Channels, NotificationChannelChoice{ID: ch.ID, Kind: ch.Kind, Name: ch.Name})
+		}
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+// updateNotificationPreferences replaces a member's notification preferences and
+// applies them to the next notification. Members may only change their own; admins
+// may change anyone's.
+func updateNotificationPreferences(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if caller := currentUser(c); !caller.IsAdmin && userID != caller.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own notification preferences"})
+		return
+	}
+	var req NotificationPreference
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	v, err := newValidator(validateUsers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := v.users[userID]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	p := NotificationPreference{UserID: userID, Events: textItems(req.Events), ChannelID: req.ChannelID}
+	for j, e := range p.Events {
+		if _, ok := notificationEvents[e]; !ok {
+			v.fail(0, "events."+strconv.Itoa(j), "unknown event")
+		}
+	}
+	if p.ChannelID != nil {
+		channels, err := loadNotificationChannels()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		active := false
+		for _, ch := range channels {
+			active = active || (ch.ID == *p.ChannelID && ch.Active)
+		}
+		if !active {
+			v.fail(0, "channel_id", "must be an active notification channel")
+		}
+	}
+	if s, ok := optionalText(req.QuietStart).(string); ok {
+		p.QuietStart = &s
+	}
+	if s, ok := optionalText(req.QuietEnd).(string); ok {
+		p.QuietEnd = &s
+	}
+	v.quietHours(p.QuietStart, p.QuietEnd)
+	if s, ok := optionalText(req.SlackMemberID).(string); ok {
+		p.SlackMemberID = &s
+		if !slackMemberIDPattern.MatchString(s) {
+			v.fail(0, "slack_member_id", "must be a Slack member ID such as U012AB3CD")
+		}
+	}
+	if v.respond(c) {
+		return
+	}
+	if _, err := db.Exec(updateNotificationPreferenceStmt, userID, pq.Array(p.Events), nullableInt(p.ChannelID),
+		optionalText(p.QuietStart), optionalText(p.QuietEnd), optionalText(p.SlackMemberID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	reloadNotifiers()
+	c.JSON(http.StatusOK, p)
+}
+
+// quietHours checks that quiet hours are HH:MM times, set together and not equal.
+func (v *validator) quietHours(start, end *string) {
+	valid := true
+	for _, f := range []struct {
+		field string
+		value *string
+	}{{"quiet_start", start}, {"quiet_end", end}} {
+		if f.value == nil {
+			continue
+		}
+		if _, err := time.Parse("15:04", *f.value); err != nil || len(*f.value) != 5 {
+			v.fail(0, f.field, "must be a time in HH:MM format")
+			valid = false
+		}
+	}
+	switch {
+	case (start == nil) != (end == nil):
+		v.fail(0, "quiet_end", "must be set together with quiet_start")
+	case valid && start != nil && *start == *end:
+		v.fail(0, "quiet_end", "must differ from quiet_start")
+	}
+}
+
+// wants reports whether the member wants events of kind: it is one of their events,
+// or they have none.
+func (p NotificationPreference) wants(kind string) bool {
+	return len(p.Events) == 0 || containsString(p.Events, kind)
+}
+
+// quiet reports whether now is within the member's quiet hours.
+func (p NotificationPreference) quiet(now time.Time) bool {
+	if p.QuietStart == nil || p.QuietEnd == nil {
+		return false
+	}
+	clock, start, end := now.Format("15:04"), *p.QuietStart, *p.QuietEnd
+	if start < end {
+		return clock >= start && clock < end
+	}
+	return clock >= start || clock < end
+}
+
+// loadNotificationPreferences returns the members' preferences keyed by user id.
+func loadNotificationPreferences() (map[int]NotificationPreference, error) {
+	rows, err := db.Query(getNotificationPreferencesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	prefs := map[int]NotificationPreference{}
+	for rows.Next() {
+		p, err := scanNotificationPreference(rows)
+		if err != nil {
+			return nil, err
+		}
+		prefs[p.UserID] = p
+	}
+	return prefs, rows.Err()
+}
+
+func scanNotificationPreference(row rowScanner) (NotificationPreference, error) {
+	var p NotificationPreference
+	var events pq.StringArray
+	var channelID sql.NullInt64
+	var start, end, slackID sql.NullString
+	if err := row.Scan(&p.UserID, &events, &channelID, &start, &end, &slackID); err != nil {
+		return p, err
+	}
+	p.Events = []string(events)
+	if p.Events == nil {
+		p.Events = []string{}
+	}
+	p.ChannelID = nullInt(channelID)
+	p.QuietStart, p.QuietEnd, p.SlackMemberID = nullString(start), nullString(end), nullString(slackID)
+	return p, nil
+}
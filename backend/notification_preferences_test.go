@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func notificationPreferenceRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"user_id", "events", "channel_id", "quiet_start", "quiet_end", "slack_member_id"})
+}
+
+// TestGetNotificationPreferences verifies members see their preferences with the
+// active channels to choose from, and not anyone else's.
+func TestGetNotificationPreferences(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationPreferenceQuery)).WithArgs(3).
+		WillReturnRows(notificationPreferenceRows().AddRow(3, "{late_meal_change}", 1, "22:00", "07:00", "U0TARO"))
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(notificationChannelRows().
+		AddRow(1, "slack", "家族", "https://hooks.slack.example/x", nil, nil, nil, nil, nil, nil, "{}", "{}", true).
+		AddRow(2, "line", "停止中", nil, "tok", nil, nil, nil, nil, nil, "{}", "{}", false))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/users/3/notification-preferences", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":3,"events":["late_meal_change"],"channel_id":1,"quiet_start":"22:00","quiet_end":"07:00",
+		"slack_member_id":"U0TARO","channels":[{"id":1,"kind":"slack","name":"家族"}]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/users/4/notification-preferences", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateNotificationPreferences verifies the preferences are trimmed, stored and
+// loaded into the dispatcher.
+func TestUpdateNotificationPreferences(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	t.Setenv("SLACK_WEBHOOK_URL", "")
+	t.Cleanup(func() { dispatch.set(nil, nil) })
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+
+	channels := func() *sqlmock.Rows {
+		return notificationChannelRows().
+			AddRow(1, "slack", "家族", "https://hooks.slack.example/x", nil, nil, nil, nil, nil, nil, "{}", "{}", true)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(channels())
+	mock.ExpectExec(regexp.QuoteMeta(updateNotificationPreferenceStmt)).
+		WithArgs(3, pq.Array([]string{"late_meal_change", "ack_reminder"}), 1, "22:00", "07:00", "U0TARO").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(channels())
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationPreferencesQuery)).WillReturnRows(notificationPreferenceRows().
+		AddRow(3, "{late_meal_change,ack_reminder}", 1, "22:00", "07:00", "U0TARO"))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/users/3/notification-preferences", bytes.NewBufferString(`{
+		"events":["late_meal_change"," ack_reminder",""],"channel_id":1,"quiet_start":"22:00","quiet_end":"07:00",
+		"slack_member_id":" U0TARO "}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":3,"events":["late_meal_change","ack_reminder"],"channel_id":1,
+		"quiet_start":"22:00","quiet_end":"07:00","slack_member_id":"U0TARO"}`, w.Body.String())
+	assert.Len(t, dispatch.prefs, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateNotificationPreferencesRejected verifies every invalid field is reported
+// in one 422.
+func TestUpdateNotificationPreferencesRejected(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(notificationChannelRows().
+		AddRow(2, "line", "停止中", nil, "tok", nil, nil, nil, nil, nil, "{}", "{}", false))
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+
+	r := setupRouter()
+	for _, tc := range []struct{ body, want string }{
+		{`{"events":["nope"],"channel_id":2,"quiet_start":"7:00","quiet_end":"25:00","slack_member_id":"@taro"}`, `[
+			{"index":0,"field":"events.0","message":"unknown event"},
+			{"index":0,"field":"channel_id","message":"must be an active notification channel"},
+			{"index":0,"field":"quiet_start","message":"must be a time in HH:MM format"},
+			{"index":0,"field":"quiet_end","message":"must be a time in HH:MM format"},
+			{"index":0,"field":"slack_member_id","message":"must be a Slack member ID such as U012AB3CD"}]`},
+		{`{"quiet_start":"22:00","quiet_end":"22:00"}`, `[{"index":0,"field":"quiet_end","message":"must differ from quiet_start"}]`},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/users/3/notification-preferences", bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, tc.body)
+		assert.JSONEq(t, `{"error":"validation failed","errors":`+tc.want+`}`, w.Body.String(), tc.body)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestNotificationPreferenceWants verifies event filtering and quiet hours, including
+// ones that wrap midnight.
+func TestNotificationPreferenceWants(t *testing.T) {
+	at := func(clock string) time.Time {
+		tm, _ := time.Parse("15:04", clock)
+		return tm
+	}
+	start, end := "22:00", "07:00"
+	p := NotificationPreference{Events: []string{eventLateMealChange}, QuietStart: &start, QuietEnd: &end}
+	assert.False(t, p.wants(eventBentoReminder))
+	assert.True(t, p.wants(eventLateMealChange))
+	for clock, want := range map[string]bool{"21:59": false, "22:00": true, "03:00": true, "07:00": false, "12:00": false} {
+		assert.Equal(t, want, p.quiet(at(clock)), clock)
+	}
+	start, end = "13:00", "15:00"
+	for clock, want := range map[string]bool{"12:59": false, "13:00": true, "14:59": true, "15:00": false} {
+		assert.Equal(t, want, p.quiet(at(clock)), clock)
+	}
+	assert.True(t, NotificationPreference{}.wants(eventAckReminder))
+	assert.False(t, NotificationPreference{}.quiet(at("03:00")))
+}
@@ -0,0 +1,721 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Event kinds published to the dispatcher. A channel with an empty events list
+// receives all of them.
+const (
+	eventLateMealChange     = "late_meal_change"
+	eventCookScheduleChange = "cook_schedule_change"
+	eventAckReminder        = "ack_reminder"
+	eventBentoReminder      = "bento_reminder"
+	eventTest               = "test"
+)
+
+// notificationEvents are the kinds a channel can subscribe to, with the subject
+// used for email.
+var notificationEvents = map[string]string{
+	eventLateMealChange:     "食事予定の直前変更",
+	eventCookScheduleChange: "料理担当の変更",
+	eventAckReminder:        "直前変更の確認のお願い",
+	eventBentoReminder:      "明日のお弁当",
+}
+
+// Channel kinds of notification_channels.
+const (
+	channelSlack   = "slack"
+	channelWebhook = "webhook"
+	channelEmail   = "email"
+	channelLINE    = "line"
+)
+
+// defaultLINENotifyURL is used by line channels without a url.
+const defaultLINENotifyURL = "https://notify-api.line.me/api/notify"
+
+const defaultSMTPPort = 587
+
+// Event is one notification: what happened and the lines to send. Recipients are the
+// members it is for; without any it is for the whole household.
+type Event struct {
+	Kind       string
+	Messages   []string
+	Recipients []Recipient
+}
+
+// Recipient is a member an event is addressed to. The dispatcher fills in
+// SlackMemberID from their preferences.
+type Recipient struct {
+	UserID        int    `json:"user_id"`
+	Name          string `json:"name"`
+	SlackMemberID string `json:"slack_member_id,omitempty"`
+}
+
+// lines returns the messages, preceded by the recipients' names when the event is
+// addressed to someone.
+func (e Event) lines() []string {
+	if len(e.Recipients) == 0 {
+		return e.Messages
+	}
+	return append([]string{mentions(e.Recipients, false)}, e.Messages...)
+}
+
+// mentions addresses recipients: a Slack mention when slack is set and they have a
+// member id, else their name.
+func mentions(rs []Recipient, slack bool) string {
+	parts := make([]string, len(rs))
+	for i, r := range rs {
+		if slack && r.SlackMemberID != "" {
+			parts[i] = "<@" + r.SlackMemberID + ">"
+		} else {
+			parts[i] = r.Name + " さん"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// mergeEvents combines events of the same kind for the same recipients, so each
+// member gets one notification per request. Order of first appearance is kept.
+func mergeEvents(events []Event) []Event {
+	var merged []Event
+	index := map[string]int{}
+	for _, e := range events {
+		key := e.Kind
+		for _, r := range e.Recipients {
+			key += "," + strconv.Itoa(r.UserID)
+		}
+		if i, ok := index[key]; ok {
+			merged[i].Messages = append(merged[i].Messages, e.Messages...)
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, Event{Kind: e.Kind, Messages: append([]string(nil), e.Messages...), Recipients: e.Recipients})
+	}
+	return merged
+}
+
+// Notifier delivers an event to one destination.
+type Notifier interface {
+	Notify(e Event) error
+}
+
+// notifyClient is shared by the HTTP notifiers so a dead endpoint cannot hang a sender.
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// smtpSendMail is swapped out by tests.
+var smtpSendMail = smtp.SendMail
+
+// slackNotifier posts to a Slack incoming webhook, mentioning the recipients or, for
+// household events, the channel.
+type slackNotifier struct {
+	url string
+}
+
+func (n slackNotifier) Notify(e Event) error {
+	head := "<!channel>"
+	if len(e.Recipients) > 0 {
+		head = mentions(e.Recipients, true)
+	}
+	body, err := json.Marshal(map[string]string{"text": head + "\n" + strings.Join(e.Messages, "\n")})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return postNotification(req)
+}
+
+// webhookNotifier posts {"event": kind, "messages": [...], "recipients": [...]} as
+// JSON, with the token as a bearer token when set. recipients is omitted for household
+// events.
+type webhookNotifier struct {
+	url, token string
+}
+
+func (n webhookNotifier) Notify(e Event) error {
+	body, err := json.Marshal(struct {
+		Event      string      `json:"event"`
+		Messages   []string    `json:"messages"`
+		Recipients []Recipient `json:"recipients,omitempty"`
+	}{e.Kind, e.Messages, e.Recipients})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	return postNotification(req)
+}
+
+// lineNotifier posts a form with message= and a bearer token, as LINE Notify does.
+type lineNotifier struct {
+	url, token string
+}
+
+func (n lineNotifier) Notify(e Event) error {
+	form := url.Values{"message": {"\n" + strings.Join(e.lines(), "\n")}}
+	req, err := http.NewRequest("POST", n.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+n.token)
+	return postNotification(req)
+}
+
+// emailNotifier sends a plain-text mail through an SMTP server, authenticating when a
+// username is set.
+type emailNotifier struct {
+	host               string
+	port               int
+	username, password string
+	from               string
+	to                 []string
+}
+
+func (n emailNotifier) Notify(e Event) error {
+	subject := notificationEvents[e.Kind]
+	if subject == "" {
+		subject = "お知らせ"
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", "[食事予定] "+subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.Join(e.lines(), "\r\n") + "\r\n")
+
+	var auth smtp.Auth
+	if n.username != "" {
+		auth = smtp.PlainAuth("", n.username, n.password, n.host)
+	}
+	return smtpSendMail(net.JoinHostPort(n.host, strconv.Itoa(n.port)), auth, n.from, n.to, []byte(msg.String()))
+}
+
+// postNotification sends req and treats any status from 300 up as a failure.
+func postNotification(req *http.Request) error {
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("non-OK status: %s", resp.Status)
+	}
+	return nil
+}
+
+// subscription is a notifier and the events it receives; empty events means all.
+// channelID is its notification_channels id, 0 for SLACK_WEBHOOK_URL.
+type subscription struct {
+	name      string
+	channelID int
+	events    []string
+	notifier  Notifier
+}
+
+func (s subscription) wants(kind string) bool {
+	return len(s.events) == 0 || kind == eventTest || containsString(s.events, kind)
+}
+
+// dispatcher fans events out to the household's active notification channels,
+// honouring the preferences of the members an event is addressed to. It holds both in
+// memory; reload re-reads them after they change. Events for members in their quiet
+// hours are held in memory too, so they are lost if the process restarts.
+type dispatcher struct {
+	mu    sync.RWMutex
+	subs  []subscription
+	prefs map[int]NotificationPreference
+	now   func() time.Time // nil = time.Now; for quiet hours
+	held  []Event          // one recipient each, waiting for their quiet hours to end
+}
+
+// releaseHeldInterval is how often held events are checked for delivery.
+const releaseHeldInterval = time.Minute
+
+// dispatch is the process-wide dispatcher. It starts empty, so nothing is sent until
+// main loads the channels.
+var dispatch = &dispatcher{}
+
+func (d *dispatcher) set(subs []subscription, prefs map[int]NotificationPreference) {
+	d.mu.Lock()
+	d.subs, d.prefs = subs, prefs
+	d.mu.Unlock()
+}
+
+// reload replaces the subscriptions with the active channels in the database plus,
+// for compatibility, a Slack channel for SLACK_WEBHOOK_URL when it is set, and the
+// members' preferences. If the channels cannot be read, only the latter Slack channel
+// is kept; if the preferences cannot, every member gets everything everywhere.
+func (d *dispatcher) reload() error {
+	var subs []subscription
+	if u := os.Getenv("SLACK_WEBHOOK_URL"); u != "" {
+		subs = append(subs, subscription{name: "SLACK_WEBHOOK_URL", notifier: slackNotifier{url: u}})
+	}
+	channels, err := loadNotificationChannels()
+	if err == nil {
+		for _, ch := range channels {
+			if ch.Active {
+				subs = append(subs, ch.subscription())
+			}
+		}
+	}
+	prefs, prefsErr := loadNotificationPreferences()
+	d.set(subs, prefs)
+	if err != nil {
+		return err
+	}
+	return prefsErr
+}
+
+// publish sends each event to every subscribed channel, logging failures. An event
+// for recipients goes only to those who want its kind, and only to the channels they
+// chose; it is dropped when nobody is left. Recipients in their quiet hours get their
+// own copy later, from releaseHeld. publish blocks until all channels have been tried;
+// handlers call it with go.
+func (d *dispatcher) publish(events ...Event) {
+	d.mu.RLock()
+	subs, prefs, now := d.subs, d.prefs, d.now
+	d.mu.RUnlock()
+	if now == nil {
+		now = time.Now
+	}
+	at := now()
+	var held []Event
+	for _, e := range events {
+		if len(e.Messages) == 0 {
+			continue
+		}
+		var recipients []Recipient
+		for _, r := range e.Recipients {
+			p, ok := prefs[r.UserID]
+			if ok && !p.wants(e.Kind) {
+				continue
+			}
+			if ok && p.quiet(at) {
+				held = append(held, Event{Kind: e.Kind, Messages: e.Messages, Recipients: []Recipient{r}})
+				continue
+			}
+			recipients = append(recipients, r)
+		}
+		if len(e.Recipients) > 0 && len(recipients) == 0 {
+			continue
+		}
+		e.Recipients = recipients
+		deliver(e, subs, prefs)
+	}
+	if len(held) > 0 {
+		d.mu.Lock()
+		d.held = append(d.held, held...)
+		d.mu.Unlock()
+	}
+}
+
+// releaseHeld sends the held events of members whose quiet hours are over, one
+// notification per member and kind, with their preferences as they are now. Events of
+// a kind they no longer want are dropped.
+func (d *dispatcher) releaseHeld() {
+	d.mu.Lock()
+	subs, prefs, now := d.subs, d.prefs, d.now
+	if now == nil {
+		now = time.Now
+	}
+	at := now()
+	var due, kept []Event
+	for _, e := range d.held {
+		p, ok := prefs[e.Recipients[0].UserID]
+		switch {
+		case ok && p.quiet(at):
+			kept = append(kept, e)
+		case !ok || p.wants(e.Kind):
+			due = append(due, e)
+		}
+	}
+	d.held = kept
+	d.mu.Unlock()
+	for _, e := range mergeEvents(due) {
+		deliver(e, subs, prefs)
+	}
+}
+
+// runReleaseHeld delivers events held over quiet hours as they end. It runs until the
+// process exits.
+func runReleaseHeld() {
+	for range time.Tick(releaseHeldInterval) {
+		dispatch.releaseHeld()
+	}
+}
+
+// deliver sends e to every subscription that wants it. Recipients are @-mentioned
+// with their Slack member id, and each channel gets only the recipients who chose it.
+func deliver(e Event, subs []subscription, prefs map[int]NotificationPreference) {
+	recipients := make([]Recipient, len(e.Recipients))
+	for i, r := range e.Recipients {
+		if id := prefs[r.UserID].SlackMemberID; id != nil {
+			r.SlackMemberID = *id
+		}
+		recipients[i] = r
+	}
+	for _, s := range subs {
+		if !s.wants(e.Kind) {
+			continue
+		}
+		sent := e
+		if len(e.Recipients) > 0 {
+			sent.Recipients = nil
+			for _, r := range recipients {
+				if ch := prefs[r.UserID].ChannelID; ch == nil || *ch == s.channelID {
+					sent.Recipients = append(sent.Recipients, r)
+				}
+			}
+			if len(sent.Recipients) == 0 {
+				continue
+			}
+		}
+		if err := s.notifier.Notify(sent); err != nil {
+			log.Printf("failed to send %s notification to %s: %v", e.Kind, s.name, err)
+		}
+	}
+}
+
+// NotificationChannel is one row of notification_channels. Secrets are never
+// returned; TokenSet and SMTPPasswordSet tell whether they are stored.
+type NotificationChannel struct {
+	ID              int      `json:"id"`
+	Kind            string   `json:"kind"`
+	Name            string   `json:"name"`
+	URL             *string  `json:"url"`
+	Token           string   `json:"-"`
+	TokenSet        bool     `json:"token_set"`
+	SMTPHost        *string  `json:"smtp_host"`
+	SMTPPort        *int     `json:"smtp_port"`
+	SMTPUsername    *string  `json:"smtp_username"`
+	SMTPPassword    string   `json:"-"`
+	SMTPPasswordSet bool     `json:"smtp_password_set"`
+	EmailFrom       *string  `json:"email_from"`
+	EmailTo         []string `json:"email_to"`
+	Events          []string `json:"events"`
+	Active          bool     `json:"active"`
+}
+
+// NotificationChannelInput is the request body for POST and PUT
+// /api/notification-channels. On PUT a nil token or smtp_password keeps the stored
+// one and "" clears it. Active defaults to true.
+type NotificationChannelInput struct {
+	Kind         string   `json:"kind"`
+	Name         string   `json:"name"`
+	URL          *string  `json:"url"`
+	Token        *string  `json:"token"`
+	SMTPHost     *string  `json:"smtp_host"`
+	SMTPPort     *int     `json:"smtp_port"`
+	SMTPUsername *string  `json:"smtp_username"`
+	SMTPPassword *string  `json:"smtp_password"`
+	EmailFrom    *string  `json:"email_from"`
+	EmailTo      []string `json:"email_to"`
+	Events       []string `json:"events"`
+	Active       *bool    `json:"active"`
+}
+
+const notificationChannelColumns = `id, kind, name, url, token, smtp_host, smtp_port, smtp_username, smtp_password,
+    email_from, email_to, events, active`
+
+const getNotificationChannelsQuery = `SELECT ` + notificationChannelColumns + `
+FROM notification_channels
+ORDER BY id`
+
+const createNotificationChannelStmt = `INSERT INTO notification_channels
+    (kind, name, url, token, smtp_host, smtp_port, smtp_username, smtp_password, email_from, email_to, events, active)
+VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12)
+RETURNING ` + notificationChannelColumns
+
+// updateNotificationChannelStmt replaces channel $13. A NULL $4 or $8 keeps the stored
+// secret and an empty string clears it.
+const updateNotificationChannelStmt = `UPDATE notification_channels SET
+    kind = $1, name = $2, url = $3,
+    token = CASE WHEN $4::text IS NULL THEN token ELSE NULLIF($4, '') END,
+    smtp_host = $5, smtp_port = $6, smtp_username = $7,
+    smtp_password = CASE WHEN $8::text IS NULL THEN smtp_password ELSE NULLIF($8, '') END,
+    email_from = $9, email_to = $10, events = $11, active = $12
+WHERE id = $13
+RETURNING ` + notificationChannelColumns
+
+const deleteNotificationChannelStmt = "DELETE FROM notification_channels WHERE id = $1"
+
+// loadNotificationChannels returns every channel, including inactive ones.
+func loadNotificationChannels() ([]NotificationChannel, error) {
+	rows, err := db.Query(getNotificationChannelsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	channels := []NotificationChannel{}
+	for rows.Next() {
+		ch, err := scanNotificationChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, ch)
+	}
+	return channels, rows.Err()
+}
+
+func scanNotificationChannel(row rowScanner) (NotificationChannel, error) {
+	var ch NotificationChannel
+	var u, token, host, username, password, from sql.NullString
+	var port sql.NullInt64
+	var to, events pq.StringArray
+	if err := row.Scan(&ch.ID, &ch.Kind, &ch.Name, &u, &token, &host, &port, &username, &password,
+		&from, &to, &events, &ch.Active); err != nil {
+		return ch, err
+	}
+	ch.URL, ch.SMTPHost, ch.SMTPUsername, ch.EmailFrom = nullString(u), nullString(host), nullString(username), nullString(from)
+	ch.SMTPPort = nullInt(port)
+	ch.Token, ch.TokenSet = token.String, token.Valid
+	ch.SMTPPassword, ch.SMTPPasswordSet = password.String, password.Valid
+	ch.EmailTo, ch.Events = []string(to), []string(events)
+	if ch.EmailTo == nil {
+		ch.EmailTo = []string{}
+	}
+	if ch.Events == nil {
+		ch.Events = []string{}
+	}
+	return ch, nil
+}
+
+// subscription builds the notifier for the channel.
+func (ch NotificationChannel) subscription() subscription {
+	s := subscription{name: fmt.Sprintf("%s channel %d (%s)", ch.Kind, ch.ID, ch.Name), channelID: ch.ID, events: ch.Events}
+	deref := func(p *string) string {
+		if p == nil {
+			return ""
+		}
+		return *p
+	}
+	switch ch.Kind {
+	case channelSlack:
+		s.notifier = slackNotifier{url: deref(ch.URL)}
+	case channelWebhook:
+		s.notifier = webhookNotifier{url: deref(ch.URL), token: ch.Token}
+	case channelLINE:
+		u := deref(ch.URL)
+		if u == "" {
+			u = defaultLINENotifyURL
+		}
+		s.notifier = lineNotifier{url: u, token: ch.Token}
+	case channelEmail:
+		port := defaultSMTPPort
+		if ch.SMTPPort != nil {
+			port = *ch.SMTPPort
+		}
+		s.notifier = emailNotifier{host: deref(ch.SMTPHost), port: port, username: deref(ch.SMTPUsername),
+			password: ch.SMTPPassword, from: deref(ch.EmailFrom), to: ch.EmailTo}
+	}
+	return s
+}
+
+// getNotificationChannels lists the household's notification channels.
+func getNotificationChannels(c *gin.Context) {
+	channels, err := loadNotificationChannels()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, channels)
+}
+
+// createNotificationChannel adds a channel and starts sending to it.
+func createNotificationChannel(c *gin.Context) {
+	in, ok := bindNotificationChannel(c, false)
+	if !ok {
+		return
+	}
+	ch, err := scanNotificationChannel(db.QueryRow(createNotificationChannelStmt, notificationChannelArgs(in)...))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	reloadNotifiers()
+	c.JSON(http.StatusCreated, ch)
+}
+
+// updateNotificationChannel replaces a channel's settings.
+func updateNotificationChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	in, ok := bindNotificationChannel(c, true)
+	if !ok {
+		return
+	}
+	ch, err := scanNotificationChannel(db.QueryRow(updateNotificationChannelStmt, append(notificationChannelArgs(in), id)...))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "notification channel not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	reloadNotifiers()
+	c.JSON(http.StatusOK, ch)
+}
+
+// deleteNotificationChannel removes a channel.
+func deleteNotificationChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	res, err := db.Exec(deleteNotificationChannelStmt, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "notification channel not found"})
+		return
+	}
+	reloadNotifiers()
+	c.JSON(http.StatusOK, gin.H{"message": "Notification channel deleted"})
+}
+
+// testNotificationChannel sends a test message to one channel, active or not, and
+// reports whether it went through.
+func testNotificationChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	channels, err := loadNotificationChannels()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, ch := range channels {
+		if ch.ID != id {
+			continue
+		}
+		msg := fmt.Sprintf("%s さんによるテスト通知です（%s）", currentUser(c).Name, ch.Name)
+		if err := ch.subscription().notifier.Notify(Event{Kind: eventTest, Messages: []string{msg}}); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Test notification sent"})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "notification channel not found"})
+}
+
+// reloadNotifiers picks up a change to notification_channels. The change itself is
+// already committed, so a failure is only logged.
+func reloadNotifiers() {
+	if err := dispatch.reload(); err != nil {
+		log.Printf("failed to reload notification channels: %v", err)
+	}
+}
+
+// bindNotificationChannel reads and validates the request body, trimming it in place.
+// Secrets are only required on create; on update the stored ones may be kept.
+func bindNotificationChannel(c *gin.Context, update bool) (NotificationChannelInput, bool) {
+	var in NotificationChannelInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return in, false
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	in.EmailTo, in.Events = textItems(in.EmailTo), textItems(in.Events)
+	v := &validator{}
+	if in.Name == "" {
+		v.fail(0, "name", "must not be empty")
+	}
+	for j, e := range in.Events {
+		if _, ok := notificationEvents[e]; !ok {
+			v.fail(0, fmt.Sprintf("events.%d", j), "unknown event")
+		}
+	}
+	present := func(s *string) bool { return s != nil && strings.TrimSpace(*s) != "" }
+	needSecret := func(s *string) bool { return present(s) || (update && s == nil) }
+	if present(in.URL) {
+		v.webURL(0, "url", strings.TrimSpace(*in.URL))
+	}
+	switch in.Kind {
+	case channelSlack, channelWebhook:
+		if !present(in.URL) {
+			v.fail(0, "url", "must not be empty")
+		}
+	case channelLINE:
+		if !needSecret(in.Token) {
+			v.fail(0, "token", "must not be empty")
+		}
+	case channelEmail:
+		if !present(in.SMTPHost) {
+			v.fail(0, "smtp_host", "must not be empty")
+		}
+		if in.SMTPPort != nil && (*in.SMTPPort < 1 || *in.SMTPPort > 65535) {
+			v.fail(0, "smtp_port", "must be between 1 and 65535")
+		}
+		if !present(in.EmailFrom) {
+			v.fail(0, "email_from", "must not be empty")
+		}
+		if len(in.EmailTo) == 0 {
+			v.fail(0, "email_to", "must not be empty")
+		}
+	default:
+		v.fail(0, "kind", "must be one of slack, webhook, email, line")
+	}
+	if v.respond(c) {
+		return in, false
+	}
+	return in, true
+}
+
+// notificationChannelArgs are $1-$12 of the create and update statements. Secrets
+// are passed as given so that update can tell nil (keep) from "" (clear).
+func notificationChannelArgs(in NotificationChannelInput) []interface{} {
+	secret := func(s *string) interface{} {
+		if s == nil {
+			return nil
+		}
+		return strings.TrimSpace(*s)
+	}
+	var port interface{}
+	if in.SMTPPort != nil {
+		port = *in.SMTPPort
+	}
+	active := in.Active == nil || *in.Active
+	return []interface{}{in.Kind, in.Name, optionalText(in.URL), secret(in.Token), optionalText(in.SMTPHost), port,
+		optionalText(in.SMTPUsername), secret(in.SMTPPassword), optionalText(in.EmailFrom),
+		pq.Array(in.EmailTo), pq.Array(in.Events), active}
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func notificationChannelRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "kind", "name", "url", "token", "smtp_host", "smtp_port", "smtp_username",
+		"smtp_password", "email_from", "email_to", "events", "active"})
+}
+
+// notificationStandIn subscribes a webhook channel on a local server to every event
+// and returns the events it receives.
+func notificationStandIn(t *testing.T) <-chan Event {
+	events := make(chan Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Event    string   `json:"event"`
+			Messages []string `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		events <- Event{Kind: body.Event, Messages: body.Messages}
+	}))
+	t.Cleanup(srv.Close)
+	dispatch.set([]subscription{{name: "stand-in", notifier: webhookNotifier{url: srv.URL}}}, nil)
+	t.Cleanup(func() { dispatch.set(nil, nil) })
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification was sent")
+		return Event{}
+	}
+}
+
+// TestNotifiers verifies what each HTTP notifier sends, and that error statuses are
+// reported.
+func TestNotifiers(t *testing.T) {
+	type request struct {
+		contentType, auth, body string
+	}
+	requests := make(chan request, 1)
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header.Get("Content-Type"), r.Header.Get("Authorization"), string(body)}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	e := Event{Kind: eventLateMealChange, Messages: []string{"一行目", "二行目"}}
+
+	assert.NoError(t, slackNotifier{url: srv.URL}.Notify(e))
+	r := <-requests
+	assert.Equal(t, "application/json", r.contentType)
+	assert.JSONEq(t, `{"text":"<!channel>\n一行目\n二行目"}`, r.body)
+
+	assert.NoError(t, webhookNotifier{url: srv.URL, token: "secret"}.Notify(e))
+	r = <-requests
+	assert.Equal(t, "Bearer secret", r.auth)
+	assert.JSONEq(t, `{"event":"late_meal_change","messages":["一行目","二行目"]}`, r.body)
+
+	assert.NoError(t, lineNotifier{url: srv.URL, token: "line-token"}.Notify(e))
+	r = <-requests
+	assert.Equal(t, "application/x-www-form-urlencoded", r.contentType)
+	assert.Equal(t, "Bearer line-token", r.auth)
+	assert.Equal(t, "message=%0A%E4%B8%80%E8%A1%8C%E7%9B%AE%0A%E4%BA%8C%E8%A1%8C%E7%9B%AE", r.body)
+
+	status = http.StatusUnauthorized
+	assert.EqualError(t, lineNotifier{url: srv.URL}.Notify(e), "non-OK status: 401 Unauthorized")
+	<-requests
+}
+
+// TestEmailNotifier verifies the mail is addressed, authenticated and encoded for
+// Japanese text.
+func TestEmailNotifier(t *testing.T) {
+	var addr, from string
+	var to []string
+	var msg []byte
+	var auth smtp.Auth
+	prev := smtpSendMail
+	smtpSendMail = func(a string, au smtp.Auth, f string, t []string, m []byte) error {
+		addr, auth, from, to, msg = a, au, f, t, m
+		return nil
+	}
+	t.Cleanup(func() { smtpSendMail = prev })
+
+	n := emailNotifier{host: "smtp.example.com", port: 587, username: "meals", password: "pw",
+		from: "meals@example.com", to: []string{"a@example.com", "b@example.com"}}
+	assert.NoError(t, n.Notify(Event{Kind: eventBentoReminder, Messages: []string{"明日のお弁当は 2 個です"}}))
+	assert.Equal(t, "smtp.example.com:587", addr)
+	assert.NotNil(t, auth)
+	assert.Equal(t, "meals@example.com", from)
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, to)
+	assert.Contains(t, string(msg), "To: a@example.com, b@example.com\r\n")
+	assert.Contains(t, string(msg), "Subject: =?UTF-8?b?")
+	assert.True(t, strings.HasSuffix(string(msg), "\r\n\r\n明日のお弁当は 2 個です\r\n"))
+
+	n.username = ""
+	assert.NoError(t, n.Notify(Event{Kind: eventAckReminder, Messages: []string{"確認をお願いします"}}))
+	assert.Nil(t, auth)
+}
+
+type notifierFunc func(Event) error
+
+func (f notifierFunc) Notify(e Event) error { return f(e) }
+
+// TestDispatcherPublish verifies events only reach the channels subscribed to them,
+// and that a failing channel does not stop the others.
+func TestDispatcherPublish(t *testing.T) {
+	var got []string
+	record := func(name string, err error) Notifier {
+		return notifierFunc(func(e Event) error {
+			got = append(got, name+":"+e.Kind)
+			return err
+		})
+	}
+	d := &dispatcher{}
+	d.set([]subscription{
+		{name: "broken", notifier: record("broken", errors.New("down"))},
+		{name: "cooks", events: []string{eventCookScheduleChange}, notifier: record("cooks", nil)},
+		{name: "all", notifier: record("all", nil)},
+	}, nil)
+	d.publish(Event{Kind: eventLateMealChange, Messages: []string{"x"}})
+	d.publish(Event{Kind: eventCookScheduleChange, Messages: []string{"x"}})
+	d.publish(Event{Kind: eventCookScheduleChange})
+	assert.Equal(t, []string{
+		"broken:late_meal_change", "all:late_meal_change",
+		"broken:cook_schedule_change", "cooks:cook_schedule_change", "all:cook_schedule_change",
+	}, got)
+}
+
+// TestDispatcherReload verifies only active channels are loaded, and that
+// SLACK_WEBHOOK_URL is kept even when the table cannot be read.
+func TestDispatcherReload(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.slack.example/x")
+	d := &dispatcher{}
+
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(notificationChannelRows().
+		AddRow(1, "line", "家族LINE", nil, "tok", nil, nil, nil, nil, nil, "{}", "{late_meal_change}", true).
+		AddRow(2, "webhook", "停止中", "https://example.com/hook", nil, nil, nil, nil, nil, nil, "{}", "{}", false))
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationPreferencesQuery)).WillReturnRows(notificationPreferenceRows().
+		AddRow(3, "{}", 1, nil, nil, "U0TARO"))
+	assert.NoError(t, d.reload())
+	if assert.Len(t, d.subs, 2) {
+		assert.Equal(t, slackNotifier{url: "https://hooks.slack.example/x"}, d.subs[0].notifier)
+		assert.Equal(t, lineNotifier{url: defaultLINENotifyURL, token: "tok"}, d.subs[1].notifier)
+		assert.Equal(t, []string{eventLateMealChange}, d.subs[1].events)
+		assert.Equal(t, 1, d.subs[1].channelID)
+	}
+	assert.Equal(t, "U0TARO", *d.prefs[3].SlackMemberID)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnError(errors.New("no table"))
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationPreferencesQuery)).WillReturnRows(notificationPreferenceRows())
+	assert.Error(t, d.reload())
+	assert.Len(t, d.subs, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestNotificationChannels verifies admins can list channels without their secrets,
+// add one (which is loaded right away) and delete one.
+func TestNotificationChannels(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	t.Setenv("SLACK_WEBHOOK_URL", "")
+	t.Cleanup(func() { dispatch.set(nil, nil) })
+
+	emailRow := []driver.Value{2, "email", "母の携帯", nil, nil, "smtp.example.com", 587, "meals", "pw",
+		"meals@example.com", "{mother@example.com}", "{bento_reminder}", true}
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(notificationChannelRows().AddRow(emailRow...))
+	mock.ExpectQuery(regexp.QuoteMeta(createNotificationChannelStmt)).
+		WithArgs("email", "母の携帯", nil, nil, "smtp.example.com", 587, "meals", "pw", "meals@example.com",
+			pq.Array([]string{"mother@example.com"}), pq.Array([]string{"bento_reminder"}), true).
+		WillReturnRows(notificationChannelRows().AddRow(emailRow...))
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(notificationChannelRows().AddRow(emailRow...))
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationPreferencesQuery)).WillReturnRows(notificationPreferenceRows())
+	mock.ExpectExec(regexp.QuoteMeta(deleteNotificationChannelStmt)).WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	channel := `{"id":2,"kind":"email","name":"母の携帯","url":null,"token_set":false,
+		"smtp_host":"smtp.example.com","smtp_port":587,"smtp_username":"meals","smtp_password_set":true,
+		"email_from":"meals@example.com","email_to":["mother@example.com"],"events":["bento_reminder"],"active":true}`
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/notification-channels", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "["+channel+"]", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/notification-channels", bytes.NewBufferString(`{"kind":"email","name":" 母の携帯 ",
+		"smtp_host":"smtp.example.com","smtp_port":587,"smtp_username":"meals","smtp_password":"pw",
+		"email_from":"meals@example.com","email_to":["mother@example.com",""],"events":["bento_reminder"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, channel, w.Body.String())
+	if assert.Len(t, dispatch.subs, 1) {
+		assert.Equal(t, emailNotifier{host: "smtp.example.com", port: 587, username: "meals", password: "pw",
+			from: "meals@example.com", to: []string{"mother@example.com"}}, dispatch.subs[0].notifier)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/notification-channels/9", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateNotificationChannel verifies an omitted token keeps the stored one, and
+// that unknown channels are a 404.
+func TestUpdateNotificationChannel(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	t.Setenv("SLACK_WEBHOOK_URL", "")
+	t.Cleanup(func() { dispatch.set(nil, nil) })
+
+	args := []driver.Value{"line", "家族LINE", nil, nil, nil, nil, nil, nil, nil,
+		pq.Array([]string{}), pq.Array([]string{}), false}
+	mock.ExpectQuery(regexp.QuoteMeta(updateNotificationChannelStmt)).WithArgs(append(args, 1)...).
+		WillReturnRows(notificationChannelRows().AddRow(1, "line", "家族LINE", nil, "tok", nil, nil, nil, nil, nil, "{}", "{}", false))
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(notificationChannelRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationPreferencesQuery)).WillReturnRows(notificationPreferenceRows())
+	mock.ExpectQuery(regexp.QuoteMeta(updateNotificationChannelStmt)).WithArgs(append(args, 9)...).
+		WillReturnRows(notificationChannelRows())
+
+	r := setupRouter()
+	body := `{"kind":"line","name":"家族LINE","active":false}`
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/api/notification-channels/1", http.StatusOK},
+		{"/api/notification-channels/9", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", tc.path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.path)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCreateNotificationChannelRejected verifies the fields each kind needs, and that
+// members cannot manage channels.
+func TestCreateNotificationChannelRejected(t *testing.T) {
+	r := setupRouter()
+	for _, tc := range []struct{ body, want string }{
+		{`{"kind":"slack","name":"x"}`, `[{"index":0,"field":"url","message":"must not be empty"}]`},
+		{`{"kind":"webhook","name":" ","url":"ftp://example.com","events":["late_meal_change","nope"]}`, `[
+			{"index":0,"field":"name","message":"must not be empty"},
+			{"index":0,"field":"events.1","message":"unknown event"},
+			{"index":0,"field":"url","message":"must be an http or https URL"}]`},
+		{`{"kind":"line","name":"x","token":" "}`, `[{"index":0,"field":"token","message":"must not be empty"}]`},
+		{`{"kind":"email","name":"x","smtp_port":0}`, `[
+			{"index":0,"field":"smtp_host","message":"must not be empty"},
+			{"index":0,"field":"smtp_port","message":"must be between 1 and 65535"},
+			{"index":0,"field":"email_from","message":"must not be empty"},
+			{"index":0,"field":"email_to","message":"must not be empty"}]`},
+		{`{"kind":"sms","name":"x"}`, `[{"index":0,"field":"kind","message":"must be one of slack, webhook, email, line"}]`},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/notification-channels", bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, tc.body)
+		assert.JSONEq(t, `{"error":"validation failed","errors":`+tc.want+`}`, w.Body.String(), tc.body)
+	}
+
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/notification-channels", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// TestTestNotificationChannel verifies a test message reaches an inactive channel and
+// that a failing endpoint is a 502.
+func TestTestNotificationChannel(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	rows := func() *sqlmock.Rows {
+		return notificationChannelRows().
+			AddRow(1, "webhook", "家族", srv.URL+"/ok", nil, nil, nil, nil, nil, nil, "{}", "{late_meal_change}", false).
+			AddRow(2, "webhook", "壊れた", srv.URL+"/broken", nil, nil, nil, nil, nil, nil, "{}", "{}", true)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(rows())
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(rows())
+	mock.ExpectQuery(regexp.QuoteMeta(getNotificationChannelsQuery)).WillReturnRows(rows())
+
+	r := setupRouter()
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/api/notification-channels/1/test", http.StatusOK},
+		{"/api/notification-channels/2/test", http.StatusBadGateway},
+		{"/api/notification-channels/3/test", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", tc.path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.path)
+	}
+	assert.JSONEq(t, `{"event":"test","messages":["John さんによるテスト通知です（壊れた）"]}`, body)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCookScheduleNotifications verifies changed overrides are announced to the
+// household and unchanged ones are not.
+func TestCookScheduleNotifications(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	events := notificationStandIn(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(bulkUpdateCookSchedulesStmt))
+	prep.ExpectExec().WithArgs("2026-04-06", 1, 5, 1, "PUT /api/cook-schedules").WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithArgs("2026-04-06", 2, nil, 1, "PUT /api/cook-schedules").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+	mock.ExpectBegin()
+	mock.ExpectPrepare(regexp.QuoteMeta(updateCookDefaultSchedulesStmt)).ExpectExec().
+		WithArgs(1, 2, 5, 1, "PUT /api/cook-default-schedules").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/cook-schedules", bytes.NewBufferString(`[
+		{"date":"2026-04-06","meal_period":1,"cook_user_id":5},
+		{"date":"2026-04-06","meal_period":2,"cook_user_id":null}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, Event{Kind: eventCookScheduleChange, Messages: []string{
+		"John さんが 2026-04-06 の夕食の料理担当を「各自」に変更しました",
+	}}, nextEvent(t, events))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/cook-default-schedules", bytes.NewBufferString(`[
+		{"day_of_week":1,"meal_period":2,"cook_user_id":5}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, Event{Kind: eventCookScheduleChange, Messages: []string{
+		"John さんが毎週月曜の夕食の料理担当を「Mother さん」に変更しました",
+	}}, nextEvent(t, events))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDispatcherRecipients verifies events for members skip those who do not want
+// them, hold those in quiet hours, go only to the channels they chose, and
+// @-mention them in Slack.
+func TestDispatcherRecipients(t *testing.T) {
+	type delivery struct {
+		channel string
+		e       Event
+	}
+	var got []delivery
+	record := func(name string) Notifier {
+		return notifierFunc(func(e Event) error {
+			got = append(got, delivery{name, e})
+			return nil
+		})
+	}
+	quietStart, quietEnd, slackID, line := "22:00", "07:00", "U0MOTHER", 2
+	d := &dispatcher{now: func() time.Time { return time.Date(2025, 2, 16, 23, 0, 0, 0, time.Local) }}
+	d.set([]subscription{
+		{name: "slack", channelID: 1, notifier: record("slack")},
+		{name: "line", channelID: 2, notifier: record("line")},
+	}, map[int]NotificationPreference{
+		3: {UserID: 3, Events: []string{}, QuietStart: &quietStart, QuietEnd: &quietEnd},
+		4: {UserID: 4, Events: []string{eventBentoReminder}},
+		5: {UserID: 5, Events: []string{}, ChannelID: &line, SlackMemberID: &slackID},
+	})
+	taro, hanako, mother := Recipient{UserID: 3, Name: "Taro"}, Recipient{UserID: 4, Name: "Hanako"}, Recipient{UserID: 5, Name: "Mother"}
+	john := Recipient{UserID: 1, Name: "John"}
+
+	d.publish(
+		Event{Kind: eventLateMealChange, Messages: []string{"a"}, Recipients: []Recipient{taro, hanako}},
+		Event{Kind: eventLateMealChange, Messages: []string{"b"}, Recipients: []Recipient{mother, john}},
+		Event{Kind: eventCookScheduleChange, Messages: []string{"c"}},
+	)
+	mother.SlackMemberID = slackID
+	assert.Equal(t, []delivery{
+		{"slack", Event{Kind: eventLateMealChange, Messages: []string{"b"}, Recipients: []Recipient{john}}},
+		{"line", Event{Kind: eventLateMealChange, Messages: []string{"b"}, Recipients: []Recipient{mother, john}}},
+		{"slack", Event{Kind: eventCookScheduleChange, Messages: []string{"c"}}},
+		{"line", Event{Kind: eventCookScheduleChange, Messages: []string{"c"}}},
+	}, got)
+	assert.Equal(t, []Event{{Kind: eventLateMealChange, Messages: []string{"a"}, Recipients: []Recipient{taro}}}, d.held)
+}
+
+// TestDispatcherReleaseHeld verifies events held over quiet hours are sent together
+// once they end, and dropped if the member has stopped wanting them.
+func TestDispatcherReleaseHeld(t *testing.T) {
+	var got []Event
+	now := time.Date(2025, 2, 16, 23, 0, 0, 0, time.Local)
+	d := &dispatcher{now: func() time.Time { return now }}
+	quietStart, quietEnd, slackID := "22:00", "07:00", "U0TARO"
+	taroPrefs := NotificationPreference{UserID: 3, Events: []string{}, QuietStart: &quietStart, QuietEnd: &quietEnd, SlackMemberID: &slackID}
+	d.set([]subscription{{name: "slack", notifier: notifierFunc(func(e Event) error {
+		got = append(got, e)
+		return nil
+	})}}, map[int]NotificationPreference{3: taroPrefs})
+	taro := Recipient{UserID: 3, Name: "Taro"}
+
+	d.publish(Event{Kind: eventLateMealChange, Messages: []string{"a"}, Recipients: []Recipient{taro}})
+	d.publish(Event{Kind: eventBentoReminder, Messages: []string{"b"}, Recipients: []Recipient{taro}},
+		Event{Kind: eventLateMealChange, Messages: []string{"c"}, Recipients: []Recipient{taro}})
+	d.releaseHeld()
+	assert.Empty(t, got)
+	assert.Len(t, d.held, 3)
+
+	now = time.Date(2025, 2, 17, 7, 0, 0, 0, time.Local)
+	taroPrefs.Events = []string{eventLateMealChange}
+	d.set(d.subs, map[int]NotificationPreference{3: taroPrefs})
+	d.releaseHeld()
+	taro.SlackMemberID = slackID
+	assert.Equal(t, []Event{{Kind: eventLateMealChange, Messages: []string{"a", "c"}, Recipients: []Recipient{taro}}}, got)
+	assert.Empty(t, d.held)
+}
+
+// TestSlackMentions verifies events for members mention them instead of the channel,
+// by member id when known and by name otherwise.
+func TestSlackMentions(t *testing.T) {
+	bodies := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer srv.Close()
+
+	assert.NoError(t, slackNotifier{url: srv.URL}.Notify(Event{Kind: eventLateMealChange, Messages: []string{"変更"},
+		Recipients: []Recipient{{UserID: 5, Name: "Mother", SlackMemberID: "U0MOTHER"}, {UserID: 3, Name: "Taro"}}}))
+	assert.JSONEq(t, `{"text":"<@U0MOTHER> Taro さん\n変更"}`, <-bodies)
+
+	assert.NoError(t, webhookNotifier{url: srv.URL}.Notify(Event{Kind: eventAckReminder, Messages: []string{"確認"},
+		Recipients: []Recipient{{UserID: 5, Name: "Mother"}}}))
+	assert.JSONEq(t, `{"event":"ack_reminder","messages":["確認"],"recipients":[{"user_id":5,"name":"Mother"}]}`, <-bodies)
+}
+
+// TestMergeEvents verifies messages for the same kind and recipients are sent together.
+func TestMergeEvents(t *testing.T) {
+	taro, mother := Recipient{UserID: 3, Name: "Taro"}, Recipient{UserID: 5, Name: "Mother"}
+	assert.Equal(t, []Event{
+		{Kind: eventLateMealChange, Messages: []string{"a", "c"}, Recipients: []Recipient{mother, taro}},
+		{Kind: eventLateMealChange, Messages: []string{"b"}, Recipients: []Recipient{taro}},
+	}, mergeEvents([]Event{
+		{Kind: eventLateMealChange, Messages: []string{"a"}, Recipients: []Recipient{mother, taro}},
+		{Kind: eventLateMealChange, Messages: []string{"b"}, Recipients: []Recipient{taro}},
+		{Kind: eventLateMealChange, Messages: []string{"c"}, Recipients: []Recipient{mother, taro}},
+	}))
+}
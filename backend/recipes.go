@@ -0,0 +1,471 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// bentoRecipeTag marks recipes GET /api/recipes/suggestions offers for 弁当 days.
+const bentoRecipeTag = "弁当向け"
+
+// Recipe is one entry of the shared recipe library. Ingredient quantities are for
+// Servings people; PrepMinutes is nil when unknown.
+type Recipe struct {
+	ID          int                `json:"id"`
+	Title       string             `json:"title"`
+	Servings    int                `json:"servings"`
+	PrepMinutes *int               `json:"prep_minutes"`
+	Tags        []string           `json:"tags"`
+	Ingredients []RecipeIngredient `json:"ingredients"`
+	Steps       []string           `json:"steps"`
+	CreatedBy   *int               `json:"created_by"`
+}
+
+// RecipeIngredient is one ingredient of a recipe, for all of its servings.
+type RecipeIngredient struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+}
+
+// RecipeInput is the request body for POST /api/recipes and PUT /api/recipes/:id.
+// PUT replaces the whole recipe.
+type RecipeInput struct {
+	Title       string             `json:"title"`
+	Servings    int                `json:"servings"`
+	PrepMinutes *int               `json:"prep_minutes"`
+	Tags        []string           `json:"tags"`
+	Ingredients []RecipeIngredient `json:"ingredients"`
+	Steps       []string           `json:"steps"`
+}
+
+// RecipeSuggestion is one day of GET /api/recipes/suggestions: who takes a 弁当 that
+// day and the 弁当向け recipes to pick from.
+type RecipeSuggestion struct {
+	Date    string   `json:"date"`
+	Names   []string `json:"names"`
+	Recipes []Recipe `json:"recipes"`
+}
+
+// recipeColumns are the columns scanRecipe reads, from recipes r.
+const recipeColumns = `r.id, r.title, r.servings, r.prep_minutes, r.tags, r.steps, r.created_by`
+
+// getRecipesQuery lists the recipes carrying every tag in $1 and, unless $2 is empty,
+// whose title, steps or ingredient names match the ILIKE pattern $2.
+const getRecipesQuery = `SELECT ` + recipeColumns + `
+FROM recipes r
+WHERE r.tags @> $1::text[]
+    AND ($2::text = '' OR r.title ILIKE $2 OR array_to_string(r.steps, ' ') ILIKE $2
+        OR EXISTS (SELECT 1 FROM recipe_ingredients i WHERE i.recipe_id = r.id AND i.name ILIKE $2))
+ORDER BY r.title, r.id`
+
+const getRecipeQuery = `SELECT ` + recipeColumns + ` FROM recipes r WHERE r.id = $1`
+
+const getRecipesByIDQuery = `SELECT ` + recipeColumns + ` FROM recipes r WHERE r.id = ANY($1)`
+
+// getRecipeIngredientsQuery lists the ingredients of the recipes in $1 in the order
+// they were entered.
+const getRecipeIngredientsQuery = `SELECT recipe_id, name, quantity, unit
+FROM recipe_ingredients
+WHERE recipe_id = ANY($1)
+ORDER BY recipe_id, id`
+
+const createRecipeStmt = `INSERT INTO recipes (title, servings, prep_minutes, tags, steps, created_by)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id`
+
+const updateRecipeStmt = `UPDATE recipes SET
+    title = $1, servings = $2, prep_minutes = $3, tags = $4, steps = $5, updated_at = now()
+WHERE id = $6
+RETURNING created_by`
+
+const deleteRecipeStmt = "DELETE FROM recipes WHERE id = $1"
+
+const deleteRecipeIngredientsStmt = "DELETE FROM recipe_ingredients WHERE recipe_id = $1"
+
+const insertRecipeIngredientStmt = `INSERT INTO recipe_ingredients (recipe_id, name, quantity, unit)
+VALUES ($1, $2, $3, $4)`
+
+// getBentoEatersQuery lists, per date in [$1, $2], the members whose choice for any
+// active period (resolved as in getMealsQuery) is an option with is_bento set, in
+// display order.
+const getBentoEatersQuery = `WITH choices AS (
+    SELECT d.date, u.id AS user_id, u.name, u.display_order,
+        COALESCE(m.meal_option, ud.meal_option, 1) AS meal_option
+    FROM users u
+    CROSS JOIN generate_series($1::date, $2::date, '1 day') AS d(date)
+    CROSS JOIN meal_periods p
+    LEFT JOIN meals m ON m.user_id = u.id AND m.date = d.date AND m.meal_period = p.id
+    LEFT JOIN user_defaults ud ON ud.user_id = u.id
+        AND ud.day_of_week = EXTRACT(DOW FROM d.date)
+        AND ud.meal_period = p.id
+    WHERE u.is_eater = true AND u.active = true AND p.active = true
+)
+SELECT TO_CHAR(c.date, 'YYYY-MM-DD'), c.name
+FROM choices c
+JOIN meal_options o ON o.id = c.meal_option AND o.is_bento
+GROUP BY c.date, c.user_id, c.name, c.display_order
+ORDER BY c.date, c.display_order, c.user_id`
+
+// getRecipes lists the library. ?tag= may repeat and all tags must match; ?q= searches
+// titles, steps and ingredient names.
+func getRecipes(c *gin.Context) {
+	tags := textItems(c.QueryArray("tag"))
+	recipes, err := queryRecipes(tags, strings.TrimSpace(c.Query("q")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, recipes)
+}
+
+func getRecipe(c *gin.Context) {
+	id, ok := recipeID(c)
+	if !ok {
+		return
+	}
+	r, err := scanRecipe(db.QueryRow(getRecipeQuery, id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+		return
+	}
+	if err == nil {
+		err = attachRecipeIngredients([]*Recipe{&r})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// createRecipe adds a recipe to the library.
+func createRecipe(c *gin.Context) {
+	var req RecipeInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	r, ok := validRecipe(c, req)
+	if !ok {
+		return
+	}
+	caller := currentUser(c)
+	r.CreatedBy = &caller.ID
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.QueryRow(createRecipeStmt, r.Title, r.Servings, nullableInt(r.PrepMinutes),
+		pq.Array(r.Tags), pq.Array(r.Steps), caller.ID).Scan(&r.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := insertRecipeIngredients(tx, r); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, r)
+}
+
+// updateRecipe replaces a recipe, ingredients included.
+func updateRecipe(c *gin.Context) {
+	id, ok := recipeID(c)
+	if !ok {
+		return
+	}
+	var req RecipeInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	r, ok := validRecipe(c, req)
+	if !ok {
+		return
+	}
+	r.ID = id
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var createdBy sql.NullInt64
+	err = tx.QueryRow(updateRecipeStmt, r.Title, r.Servings, nullableInt(r.PrepMinutes),
+		pq.Array(r.Tags), pq.Array(r.Steps), id).Scan(&createdBy)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+		return
+	}
+	if err == nil {
+		r.CreatedBy = nullInt(createdBy)
+		_, err = tx.Exec(deleteRecipeIngredientsStmt, id)
+	}
+	if err == nil {
+		err = insertRecipeIngredients(tx, r)
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// deleteRecipe removes a recipe. Menus that used it keep their copied dishes.
+func deleteRecipe(c *gin.Context) {
+	id, ok := recipeID(c)
+	if !ok {
+		return
+	}
+	res, err := db.Exec(deleteRecipeStmt, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Recipe deleted"})
+}
+
+// getRecipeSuggestions offers the 弁当向け recipes for every day of a range on which
+// someone takes a 弁当. Days without one are left out.
+func getRecipeSuggestions(c *gin.Context) {
+	startDate, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter. Must be a positive integer."})
+		return
+	}
+	endDate := startDate.AddDate(0, 0, days-1).Format("2006-01-02")
+
+	rows, err := db.Query(getBentoEatersQuery, startDate.Format("2006-01-02"), endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	suggestions := []RecipeSuggestion{}
+	for rows.Next() {
+		var date, name string
+		if err := rows.Scan(&date, &name); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if n := len(suggestions); n == 0 || suggestions[n-1].Date != date {
+			suggestions = append(suggestions, RecipeSuggestion{Date: date})
+		}
+		s := &suggestions[len(suggestions)-1]
+		s.Names = append(s.Names, name)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(suggestions) == 0 {
+		c.JSON(http.StatusOK, suggestions)
+		return
+	}
+	recipes, err := queryRecipes([]string{bentoRecipeTag}, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range suggestions {
+		suggestions[i].Recipes = recipes
+	}
+	c.JSON(http.StatusOK, suggestions)
+}
+
+func recipeID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipe id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// validRecipe trims a recipe request into a Recipe, or writes a 422 with every problem.
+// Tags are deduplicated; blank tags and steps are dropped.
+func validRecipe(c *gin.Context, req RecipeInput) (Recipe, bool) {
+	v := &validator{}
+	r := Recipe{
+		Title:       strings.TrimSpace(req.Title),
+		Servings:    req.Servings,
+		PrepMinutes: req.PrepMinutes,
+		Tags:        textItems(req.Tags),
+		Ingredients: []RecipeIngredient{},
+		Steps:       []string{},
+	}
+	if r.Title == "" {
+		v.fail(0, "title", "must not be empty")
+	}
+	if r.Servings < 1 {
+		v.fail(0, "servings", "must be at least 1")
+	}
+	if r.PrepMinutes != nil && *r.PrepMinutes < 0 {
+		v.fail(0, "prep_minutes", "must not be negative")
+	}
+	for j, in := range req.Ingredients {
+		v.amount(0, fmt.Sprintf("ingredients.%d.", j), in.Name, in.Quantity)
+		r.Ingredients = append(r.Ingredients, RecipeIngredient{
+			Name: strings.TrimSpace(in.Name), Quantity: in.Quantity, Unit: strings.TrimSpace(in.Unit)})
+	}
+	for _, s := range req.Steps {
+		if s = strings.TrimSpace(s); s != "" {
+			r.Steps = append(r.Steps, s)
+		}
+	}
+	return r, !v.respond(c)
+}
+
+func insertRecipeIngredients(tx *sql.Tx, r Recipe) error {
+	if len(r.Ingredients) == 0 {
+		return nil
+	}
+	stmt, err := tx.Prepare(insertRecipeIngredientStmt)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, in := range r.Ingredients {
+		if _, err := stmt.Exec(r.ID, in.Name, in.Quantity, in.Unit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryRecipes runs getRecipesQuery with the ingredients attached. q is plain text;
+// LIKE wildcards in it match literally.
+func queryRecipes(tags []string, q string) ([]Recipe, error) {
+	pattern := ""
+	if q != "" {
+		pattern = "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q) + "%"
+	}
+	rows, err := db.Query(getRecipesQuery, pq.Array(tags), pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	recipes := []Recipe{}
+	for rows.Next() {
+		r, err := scanRecipe(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	ptrs := make([]*Recipe, len(recipes))
+	for i := range recipes {
+		ptrs[i] = &recipes[i]
+	}
+	return recipes, attachRecipeIngredients(ptrs)
+}
+
+// loadRecipes returns the recipes with the given ids, ingredients included, keyed by
+// id. Unknown ids are left out.
+func loadRecipes(ids []int) (map[int]Recipe, error) {
+	result := map[int]Recipe{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	rows, err := db.Query(getRecipesByIDQuery, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recipes []*Recipe
+	for rows.Next() {
+		r, err := scanRecipe(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := attachRecipeIngredients(recipes); err != nil {
+		return nil, err
+	}
+	for _, r := range recipes {
+		result[r.ID] = *r
+	}
+	return result, nil
+}
+
+// attachRecipeIngredients loads the ingredients of recipes with one query.
+func attachRecipeIngredients(recipes []*Recipe) error {
+	if len(recipes) == 0 {
+		return nil
+	}
+	byID := map[int]*Recipe{}
+	ids := make([]int64, len(recipes))
+	for i, r := range recipes {
+		byID[r.ID] = r
+		ids[i] = int64(r.ID)
+	}
+	rows, err := db.Query(getRecipeIngredientsQuery, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var in RecipeIngredient
+		if err := rows.Scan(&id, &in.Name, &in.Quantity, &in.Unit); err != nil {
+			return err
+		}
+		if r := byID[id]; r != nil {
+			r.Ingredients = append(r.Ingredients, in)
+		}
+	}
+	return rows.Err()
+}
+
+// scanRecipe reads recipeColumns. Ingredients start empty; see attachRecipeIngredients.
+func scanRecipe(row rowScanner) (Recipe, error) {
+	r := Recipe{Ingredients: []RecipeIngredient{}}
+	var prep, createdBy sql.NullInt64
+	if err := row.Scan(&r.ID, &r.Title, &r.Servings, &prep,
+		pq.Array(&r.Tags), pq.Array(&r.Steps), &createdBy); err != nil {
+		return r, err
+	}
+	r.PrepMinutes, r.CreatedBy = nullInt(prep), nullInt(createdBy)
+	if r.Tags == nil {
+		r.Tags = []string{}
+	}
+	if r.Steps == nil {
+		r.Steps = []string{}
+	}
+	return r, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// recipeRows returns the columns scanned by scanRecipe.
+func recipeRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "title", "servings", "prep_minutes", "tags", "steps", "created_by"})
+}
+
+func recipeIngredientRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"recipe_id", "name", "quantity", "unit"})
+}
+
+// TestGetRecipes verifies tags and text search reach the query, with LIKE wildcards
+// escaped, and that ingredients are attached to their recipe.
+func TestGetRecipes(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getRecipesQuery)).WithArgs(pq.Array([]string{"弁当向け"}), `%100\%%`).
+		WillReturnRows(recipeRows().
+			AddRow(4, "卵焼き", 2, 10, "{弁当向け}", "{卵を溶く,焼く}", 5).
+			AddRow(7, "鶏そぼろ", 4, nil, "{弁当向け,作り置き}", "{}", nil))
+	mock.ExpectQuery(regexp.QuoteMeta(getRecipeIngredientsQuery)).WithArgs(pq.Array([]int64{4, 7})).
+		WillReturnRows(recipeIngredientRows().
+			AddRow(4, "卵", 3.0, "個").
+			AddRow(4, "砂糖", 1.0, "大さじ"))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/recipes?tag=弁当向け&tag=+&q=100%25", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"id":4,"title":"卵焼き","servings":2,"prep_minutes":10,"tags":["弁当向け"],
+		 "ingredients":[{"name":"卵","quantity":3,"unit":"個"},{"name":"砂糖","quantity":1,"unit":"大さじ"}],
+		 "steps":["卵を溶く","焼く"],"created_by":5},
+		{"id":7,"title":"鶏そぼろ","servings":4,"prep_minutes":null,"tags":["弁当向け","作り置き"],
+		 "ingredients":[],"steps":[],"created_by":null}
+	]`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCreateRecipe verifies a cook can add a recipe, trimmed, in one transaction.
+func TestCreateRecipe(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+	asCaller(t, User{ID: 5, Name: "Mother", IsCook: true, Active: true})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(createRecipeStmt)).
+		WithArgs("卵焼き", 2, nil, pq.Array([]string{"弁当向け"}), pq.Array([]string{"焼く"}), 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectPrepare(regexp.QuoteMeta(insertRecipeIngredientStmt)).ExpectExec().
+		WithArgs(4, "卵", 3.0, "個").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/recipes", bytes.NewBufferString(`{"title":" 卵焼き ","servings":2,
+		"tags":["弁当向け"," 弁当向け",""],"ingredients":[{"name":"卵 ","quantity":3,"unit":"個"}],"steps":["焼く",""]}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":4,"title":"卵焼き","servings":2,"prep_minutes":null,"tags":["弁当向け"],
+		"ingredients":[{"name":"卵","quantity":3,"unit":"個"}],"steps":["焼く"],"created_by":5}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCreateRecipeRejected verifies every invalid field is reported in one 422, and
+// that members who do not cook cannot write to the library.
+func TestCreateRecipeRejected(t *testing.T) {
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/recipes", bytes.NewBufferString(`{"title":" ","servings":0,"prep_minutes":-5,
+		"ingredients":[{"name":"卵","quantity":0}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[
+		{"index":0,"field":"title","message":"must not be empty"},
+		{"index":0,"field":"servings","message":"must be at least 1"},
+		{"index":0,"field":"prep_minutes","message":"must not be negative"},
+		{"index":0,"field":"ingredients.0.quantity","message":"must be greater than 0"}
+	]}`, w.Body.String())
+
+	asCaller(t, User{ID: 3, Name: "Taro", IsEater: true, Active: true})
+	for _, tc := range []struct{ method, path string }{
+		{"POST", "/api/recipes"}, {"PUT", "/api/recipes/4"}, {"DELETE", "/api/recipes/4"},
+	} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(tc.method, tc.path, bytes.NewBufferString(`{"title":"卵焼き","servings":2}`))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, tc.method)
+	}
+}
+
+// TestUpdateRecipe verifies PUT replaces the ingredients and keeps the author, and that
+// unknown recipes are a 404.
+func TestUpdateRecipe(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(updateRecipeStmt)).
+		WithArgs("卵焼き", 2, 15, pq.Array([]string{}), pq.Array([]string{}), 4).
+		WillReturnRows(sqlmock.NewRows([]string{"created_by"}).AddRow(5))
+	mock.ExpectExec(regexp.QuoteMeta(deleteRecipeIngredientsStmt)).WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(updateRecipeStmt)).
+		WithArgs("卵焼き", 2, 15, pq.Array([]string{}), pq.Array([]string{}), 9).
+		WillReturnRows(sqlmock.NewRows([]string{"created_by"}))
+	mock.ExpectRollback()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/recipes/4", bytes.NewBufferString(`{"title":"卵焼き","servings":2,"prep_minutes":15}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":4,"title":"卵焼き","servings":2,"prep_minutes":15,"tags":[],"ingredients":[],"steps":[],"created_by":5}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/recipes/9", bytes.NewBufferString(`{"title":"卵焼き","servings":2,"prep_minutes":15}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeleteRecipe verifies a missing recipe is a 404.
+func TestDeleteRecipe(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectExec(regexp.QuoteMeta(deleteRecipeStmt)).WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(deleteRecipeStmt)).WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r := setupRouter()
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/api/recipes/4", http.StatusOK},
+		{"/api/recipes/9", http.StatusNotFound},
+		{"/api/recipes/x", http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", tc.path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.path)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetRecipeSuggestions verifies 弁当向け recipes are offered on the days someone
+// takes a 弁当, and only on those.
+func TestGetRecipeSuggestions(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getBentoEatersQuery)).WithArgs("2025-02-17", "2025-02-19").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "name"}).
+			AddRow("2025-02-17", "John").
+			AddRow("2025-02-17", "Taro").
+			AddRow("2025-02-19", "Taro"))
+	mock.ExpectQuery(regexp.QuoteMeta(getRecipesQuery)).WithArgs(pq.Array([]string{bentoRecipeTag}), "").
+		WillReturnRows(recipeRows().AddRow(4, "卵焼き", 2, 10, "{弁当向け}", "{}", 5))
+	mock.ExpectQuery(regexp.QuoteMeta(getRecipeIngredientsQuery)).WithArgs(pq.Array([]int64{4})).
+		WillReturnRows(recipeIngredientRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/recipes/suggestions?date=2025-02-17&days=3", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	recipe := `{"id":4,"title":"卵焼き","servings":2,"prep_minutes":10,"tags":["弁当向け"],"ingredients":[],"steps":[],"created_by":5}`
+	assert.JSONEq(t, `[
+		{"date":"2025-02-17","names":["John","Taro"],"recipes":[`+recipe+`]},
+		{"date":"2025-02-19","names":["Taro"],"recipes":[`+recipe+`]}
+	]`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
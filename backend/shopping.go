@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShoppingList is the response of GET /api/shopping-list: the menus that went into it
+// and the ingredients to buy, merged across days.
+type ShoppingList struct {
+	From  string         `json:"from"`
+	To    string         `json:"to"`
+	Meals []ShoppingMeal `json:"meals"`
+	Items []ShoppingItem `json:"items"`
+}
+
+// ShoppingMeal is one menu with ingredients and the number of servings it is bought
+// for: the members eating at home plus guests.
+type ShoppingMeal struct {
+	Date       string   `json:"date"`
+	MealPeriod int      `json:"meal_period"`
+	Servings   int      `json:"servings"`
+	Dishes     []string `json:"dishes"`
+}
+
+// ShoppingItem is one ingredient to buy. Quantities in g or ml are shown in kg or L
+// from 1000 up.
+type ShoppingItem struct {
+	Name     string   `json:"name"`
+	Quantity float64  `json:"quantity"`
+	Unit     string   `json:"unit"`
+	Dishes   []string `json:"dishes"`
+}
+
+// getShoppingListQuery lists the ingredients of the menus in [$1, $2] with the number
+// of servings of their period: members whose choice (resolved as in getMealsQuery)
+// has eats_at_home set, plus guests.
+const getShoppingListQuery = `WITH choices AS (
+    SELECT d.date, p.id AS meal_period, COALESCE(m.meal_option, ud.meal_option, 1) AS meal_option
+    FROM users u
+    CROSS JOIN generate_series($1::date, $2::date, '1 day') AS d(date)
+    CROSS JOIN meal_periods p
+    LEFT JOIN meals m ON m.user_id = u.id AND m.date = d.date AND m.meal_period = p.id
+    LEFT JOIN user_defaults ud ON ud.user_id = u.id
+        AND ud.day_of_week = EXTRACT(DOW FROM d.date)
+        AND ud.meal_period = p.id
+    WHERE u.is_eater = true AND u.active = true AND p.active = true
+), eaters AS (
+    SELECT c.date, c.meal_period, COUNT(*) AS count
+    FROM choices c
+    JOIN meal_options o ON o.id = c.meal_option AND o.eats_at_home
+    GROUP BY c.date, c.meal_period
+), guests AS (
+    SELECT date, meal_period, SUM(count) AS count
+    FROM meal_guests
+    WHERE date BETWEEN $1 AND $2
+    GROUP BY date, meal_period
+)
+SELECT TO_CHAR(i.date, 'YYYY-MM-DD'), i.meal_period, COALESCE(e.count, 0) + COALESCE(g.count, 0),
+    i.dish, i.name, i.quantity, i.unit
+FROM menu_ingredients i
+JOIN meal_periods p ON p.id = i.meal_period AND p.active
+LEFT JOIN eaters e ON e.date = i.date AND e.meal_period = i.meal_period
+LEFT JOIN guests g ON g.date = i.date AND g.meal_period = i.meal_period
+WHERE i.date BETWEEN $1 AND $2
+ORDER BY i.date, p.sort_order, i.meal_period, i.id`
+
+// unitConversions maps the units that can be merged to a base unit and the amount of
+// it one unit stands for. Keys are lower case and half width.
+var unitConversions = map[string]struct {
+	base   string
+	factor float64
+}{
+	"g":    {"g", 1},
+	"グラム":  {"g", 1},
+	"kg":   {"g", 1000},
+	"キロ":   {"g", 1000},
+	"ml":   {"ml", 1},
+	"cc":   {"ml", 1},
+	"l":    {"ml", 1000},
+	"リットル": {"ml", 1000},
+	"カップ":  {"ml", 200},
+	"大さじ":  {"ml", 15},
+	"小さじ":  {"ml", 5},
+}
+
+// getShoppingList adds up the ingredients of the menus of a date range for the number
+// of people eating at home. format=text returns a plain-text checklist instead of JSON.
+func getShoppingList(c *gin.Context) {
+	startDate, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter. Must be a positive integer."})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "text" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Use json or text."})
+		return
+	}
+	from := startDate.Format("2006-01-02")
+	to := startDate.AddDate(0, 0, days-1).Format("2006-01-02")
+
+	rows, err := db.Query(getShoppingListQuery, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := ShoppingList{From: from, To: to, Meals: []ShoppingMeal{}, Items: []ShoppingItem{}}
+	// Items are merged on the width-folded name and base unit, in order of first use.
+	index := map[string]int{}
+	bases := []string{}
+	for rows.Next() {
+		var date string
+		var periodID, servings int
+		var in MenuIngredient
+		if err := rows.Scan(&date, &periodID, &servings, &in.Dish, &in.Name, &in.Quantity, &in.Unit); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		n := len(list.Meals)
+		if n == 0 || list.Meals[n-1].Date != date || list.Meals[n-1].MealPeriod != periodID {
+			list.Meals = append(list.Meals, ShoppingMeal{Date: date, MealPeriod: periodID, Servings: servings, Dishes: []string{}})
+			n++
+		}
+		list.Meals[n-1].Dishes = appendUnique(list.Meals[n-1].Dishes, in.Dish)
+		if servings == 0 {
+			continue
+		}
+		unit, quantity := normalizeUnit(in.Unit, in.Quantity*float64(servings))
+		key := foldWidth(strings.ToLower(in.Name)) + "\x00" + unit
+		i, ok := index[key]
+		if !ok {
+			i = len(list.Items)
+			index[key] = i
+			list.Items = append(list.Items, ShoppingItem{Name: in.Name, Unit: unit, Dishes: []string{}})
+			bases = append(bases, unit)
+		}
+		list.Items[i].Quantity += quantity
+		list.Items[i].Dishes = appendUnique(list.Items[i].Dishes, in.Dish)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range list.Items {
+		list.Items[i].Quantity, list.Items[i].Unit = displayQuantity(list.Items[i].Quantity, bases[i])
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, list)
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "買い物リスト %s〜%s\n", from, to)
+	if len(list.Items) == 0 {
+		b.WriteString("（材料の登録された献立がありません）\n")
+	}
+	for _, it := range list.Items {
+		fmt.Fprintf(&b, "[ ] %s %s%s（%s）\n", it.Name,
+			strconv.FormatFloat(it.Quantity, 'f', -1, 64), it.Unit, strings.Join(it.Dishes, "、"))
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(b.String()))
+}
+
+// normalizeUnit converts a quantity to the base unit of unitConversions when it has
+// one. Other units are kept as typed, so they only merge with the same spelling.
+func normalizeUnit(unit string, quantity float64) (string, float64) {
+	if u, ok := unitConversions[foldWidth(strings.ToLower(unit))]; ok {
+		return u.base, quantity * u.factor
+	}
+	return unit, quantity
+}
+
+// displayQuantity switches g and ml to kg and L from 1000 up and rounds to two decimals.
+func displayQuantity(quantity float64, unit string) (float64, string) {
+	switch {
+	case unit == "g" && quantity >= 1000:
+		quantity, unit = quantity/1000, "kg"
+	case unit == "ml" && quantity >= 1000:
+		quantity, unit = quantity/1000, "L"
+	}
+	return math.Round(quantity*100) / 100, unit
+}
+
+// foldWidth turns full-width ASCII (ｇ, ＭＬ) into half width.
+func foldWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '！' && r <= '～' {
+			return r - 0xFEE0
+		}
+		return r
+	}, s)
+}
+
+func appendUnique(items []string, s string) []string {
+	if containsString(items, s) {
+		return items
+	}
+	return append(items, s)
+}
+
+// ingredient checks one ingredient of a menu update. dishes are the menu's dishes
+// after textItems.
+func (v *validator) ingredient(i, j int, dishes []string, in MenuIngredient) {
+	field := fmt.Sprintf("ingredients.%d.", j)
+	if d := strings.TrimSpace(in.Dish); d == "" || !containsString(dishes, d) {
+		v.fail(i, field+"dish", "must be one of the menu's dishes")
+	}
+	v.amount(i, field, in.Name, in.Quantity)
+}
+
+// amount checks the name and quantity of an ingredient. field is the "ingredients.N."
+// prefix of the error fields.
+func (v *validator) amount(i int, field, name string, quantity float64) {
+	if strings.TrimSpace(name) == "" {
+		v.fail(i, field+"name", "must not be empty")
+	}
+	if !(quantity > 0) {
+		v.fail(i, field+"quantity", "must be greater than 0")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// shoppingListRows returns two days of curry for 3 and then 2 servings, plus a lunch
+// nobody eats at home.
+func shoppingListRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"date", "meal_period", "servings", "dish", "name", "quantity", "unit"}).
+		AddRow("2025-02-16", 1, 0, "うどん", "うどん", 1.0, "玉").
+		AddRow("2025-02-16", 2, 3, "カレー", "豚肉", 150.0, "g").
+		AddRow("2025-02-16", 2, 3, "カレー", "玉ねぎ", 0.5, "個").
+		AddRow("2025-02-16", 2, 3, "サラダ", "ドレッシング", 1.0, "大さじ").
+		AddRow("2025-02-17", 2, 2, "カレー", "豚肉", 0.3, "ｋｇ").
+		AddRow("2025-02-17", 2, 2, "スープ", "牛乳", 0.5, "カップ").
+		AddRow("2025-02-17", 2, 2, "スープ", "ドレッシング", 10.0, "ml")
+}
+
+// TestGetShoppingList verifies quantities are multiplied by the servings, merged across
+// days and units, and that nothing is bought for a period nobody eats at home.
+func TestGetShoppingList(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getShoppingListQuery)).WithArgs("2025-02-16", "2025-02-17").
+		WillReturnRows(shoppingListRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getShoppingListQuery)).WithArgs("2025-02-16", "2025-02-17").
+		WillReturnRows(shoppingListRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/shopping-list?date=2025-02-16&days=2", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"from":"2025-02-16","to":"2025-02-17",
+		"meals":[
+			{"date":"2025-02-16","meal_period":1,"servings":0,"dishes":["うどん"]},
+			{"date":"2025-02-16","meal_period":2,"servings":3,"dishes":["カレー","サラダ"]},
+			{"date":"2025-02-17","meal_period":2,"servings":2,"dishes":["カレー","スープ"]}
+		],
+		"items":[
+			{"name":"豚肉","quantity":1.05,"unit":"kg","dishes":["カレー"]},
+			{"name":"玉ねぎ","quantity":1.5,"unit":"個","dishes":["カレー"]},
+			{"name":"ドレッシング","quantity":65,"unit":"ml","dishes":["サラダ","スープ"]},
+			{"name":"牛乳","quantity":200,"unit":"ml","dishes":["スープ"]}
+		]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/shopping-list?date=2025-02-16&days=2&format=text", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "買い物リスト 2025-02-16〜2025-02-17\n"+
+		"[ ] 豚肉 1.05kg（カレー）\n"+
+		"[ ] 玉ねぎ 1.5個（カレー）\n"+
+		"[ ] ドレッシング 65ml（サラダ、スープ）\n"+
+		"[ ] 牛乳 200ml（スープ）\n", w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetShoppingListBadRequest verifies the query parameters are checked before the
+// database is touched.
+func TestGetShoppingListBadRequest(t *testing.T) {
+	r := setupRouter()
+	for _, q := range []string{"date=bad&days=1", "date=2025-02-16", "date=2025-02-16&days=1&format=csv"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/shopping-list?"+q, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, q)
+	}
+}
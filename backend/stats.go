@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CookStats is one cook's workload in GET /api/stats/cooks. Periods counts the
+// periods they were resolved as cook for, keyed by meal period id; PeopleCookedFor sums
+// the eaters whose option had eats_at_home set in those periods; LateChanges counts the
+// meal changes made within notifyLeadTime or past the cutoff that hit those periods.
+type CookStats struct {
+	CookUserID      int         `json:"cook_user_id"`
+	CookUserName    string      `json:"cook_user_name"`
+	Periods         map[int]int `json:"periods"`
+	Total           int         `json:"total"`
+	PeopleCookedFor int         `json:"people_cooked_for"`
+	LateChanges     int         `json:"late_changes"`
+}
+
+// getCookStatsQuery totals, per cook and meal period in [$1, $2], the periods they
+// cook (resolved by getCookSchedulesQuery), the home eaters of those periods (resolved
+// as in getMealsQuery) and the late changes to them. $3 is notifyLeadTime in seconds;
+// like bulk-update, a change is late when made within it of the date's UTC midnight.
+const getCookStatsQuery = `WITH cooks (date, meal_period, cook_user_id, cook_user_name) AS (` + getCookSchedulesQuery + `
+), home AS (
+    SELECT d.date, p.id AS meal_period, COUNT(*) AS eaters
+    FROM users u
+    CROSS JOIN generate_series($1::date, $2::date, '1 day') AS d(date)
+    CROSS JOIN meal_periods p
+    LEFT JOIN meals m ON m.user_id = u.id AND m.date = d.date AND m.meal_period = p.id
+    LEFT JOIN user_defaults ud ON ud.user_id = u.id
+        AND ud.day_of_week = EXTRACT(DOW FROM d.date)
+        AND ud.meal_period = p.id
+    JOIN meal_options o ON o.id = COALESCE(m.meal_option, ud.meal_option, 1)
+    WHERE u.is_eater = true AND u.active = true AND p.active = true AND o.eats_at_home
+    GROUP BY d.date, p.id
+), late AS (
+    SELECT date, meal_period, COUNT(*) AS changes
+    FROM meal_changes
+    WHERE date BETWEEN $1 AND $2
+        AND (cutoff_mode IS NOT NULL
+            OR changed_at >= (date::timestamp AT TIME ZONE 'UTC') - make_interval(secs => $3))
+    GROUP BY date, meal_period
+)
+SELECT k.cook_user_id, k.cook_user_name, k.meal_period, COUNT(*),
+    COALESCE(SUM(h.eaters), 0), COALESCE(SUM(l.changes), 0)
+FROM cooks k
+JOIN users u ON u.id = k.cook_user_id
+LEFT JOIN home h ON h.date = k.date::date AND h.meal_period = k.meal_period
+LEFT JOIN late l ON l.date = k.date::date AND l.meal_period = k.meal_period
+GROUP BY u.display_order, k.cook_user_id, k.cook_user_name, k.meal_period
+ORDER BY u.display_order, k.cook_user_id, k.meal_period`
+
+// getCookStats returns each cook's workload over [from, to], in display order.
+// Cooks without a period in the range are omitted.
+func getCookStats(c *gin.Context) {
+	from, to, ok := statsRange(c)
+	if !ok {
+		return
+	}
+	rows, err := db.Query(getCookStatsQuery, from, to, notifyLeadTime.Seconds())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	result := []*CookStats{}
+	for rows.Next() {
+		var s CookStats
+		var periodID, count, eaters, late int
+		if err := rows.Scan(&s.CookUserID, &s.CookUserName, &periodID, &count, &eaters, &late); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// Rows are ordered by cook, so a cook's periods are contiguous.
+		if n := len(result); n == 0 || result[n-1].CookUserID != s.CookUserID {
+			s.Periods = map[int]int{}
+			result = append(result, &s)
+		}
+		cs := result[len(result)-1]
+		cs.Periods[periodID] = count
+		cs.Total += count
+		cs.PeopleCookedFor += eaters
+		cs.LateChanges += late
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// maxStatsDays bounds the range of one stats request; each query expands every day
+// of it for every member or period.
+const maxStatsDays = 366
+
+// MemberStats is one eater's attendance in GET /api/stats/members. Options counts
+// their resolved choices per meal option id; HomeMeals and Skipped total the ones with
+// eats_at_home and is_skip. Deviations counts explicit meals that differ from the weekday
+// default, and LateChanges the changes to their meals within notifyLeadTime or past the cutoff.
+type MemberStats struct {
+	UserID      int         `json:"user_id"`
+	UserName    string      `json:"user_name"`
+	Options     map[int]int `json:"options"`
+	HomeMeals   int         `json:"home_meals"`
+	Skipped     int         `json:"skipped"`
+	Deviations  int         `json:"deviations"`
+	LateChanges int         `json:"late_changes"`
+}
+
+// getMemberStatsQuery counts, per eater and resolved option in [$1, $2], the periods
+// resolved as in getMealsQuery and how many were explicit meals that differ from the
+// weekday default (なし(1) when there is none). $3 is notifyLeadTime in seconds; late
+// changes are counted as in getCookStatsQuery.
+const getMemberStatsQuery = `WITH choices AS (
+    SELECT u.id AS user_id, m.meal_option AS explicit, COALESCE(ud.meal_option, 1) AS fallback,
+        COALESCE(m.meal_option, ud.meal_option, 1) AS meal_option
+    FROM users u
+    CROSS JOIN generate_series($1::date, $2::date, '1 day') AS d(date)
+    CROSS JOIN meal_periods p
+    LEFT JOIN meals m ON m.user_id = u.id AND m.date = d.date AND m.meal_period = p.id
+    LEFT JOIN user_defaults ud ON ud.user_id = u.id
+        AND ud.day_of_week = EXTRACT(DOW FROM d.date)
+        AND ud.meal_period = p.id
+    WHERE u.is_eater = true AND u.active = true AND p.active = true
+), late AS (
+    SELECT user_id, COUNT(*) AS changes
+    FROM meal_changes
+    WHERE date BETWEEN $1 AND $2
+        AND (cutoff_mode IS NOT NULL
+            OR changed_at >= (date::timestamp AT TIME ZONE 'UTC') - make_interval(secs => $3))
+    GROUP BY user_id
+)
+SELECT u.id, u.name, c.meal_option, o.eats_at_home, o.is_skip, COUNT(*),
+    COUNT(*) FILTER (WHERE c.explicit IS DISTINCT FROM c.fallback AND c.explicit IS NOT NULL),
+    COALESCE(l.changes, 0)
+FROM choices c
+JOIN users u ON u.id = c.user_id
+JOIN meal_options o ON o.id = c.meal_option
+LEFT JOIN late l ON l.user_id = u.id
+GROUP BY u.display_order, u.id, u.name, c.meal_option, o.eats_at_home, o.is_skip, l.changes
+ORDER BY u.display_order, u.id, c.meal_option`
+
+// getMemberStats returns each active eater's attendance over [from, to], in display order.
+func getMemberStats(c *gin.Context) {
+	from, to, ok := statsRange(c)
+	if !ok {
+		return
+	}
+	rows, err := db.Query(getMemberStatsQuery, from, to, notifyLeadTime.Seconds())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	result := []*MemberStats{}
+	for rows.Next() {
+		var s MemberStats
+		var optionID, count, deviations int
+		var eatsAtHome, isSkip bool
+		if err := rows.Scan(&s.UserID, &s.UserName, &optionID, &eatsAtHome, &isSkip, &count, &deviations, &s.LateChanges); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// Rows are ordered by user, so a user's options are contiguous.
+		if n := len(result); n == 0 || result[n-1].UserID != s.UserID {
+			s.Options = map[int]int{}
+			result = append(result, &s)
+		}
+		ms := result[len(result)-1]
+		ms.Options[optionID] = count
+		if eatsAtHome {
+			ms.HomeMeals += count
+		}
+		if isSkip {
+			ms.Skipped += count
+		}
+		ms.Deviations += deviations
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// statsRange reads ?from= and ?to= (YYYY-MM-DD, inclusive), writing a 400 when they are
+// missing, malformed, reversed or more than maxStatsDays apart.
+func statsRange(c *gin.Context) (from, to string, ok bool) {
+	f, errFrom := time.Parse("2006-01-02", c.Query("from"))
+	t, errTo := time.Parse("2006-01-02", c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return "", "", false
+	}
+	if t.Before(f) || t.Sub(f) >= maxStatsDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("to must be on or after from, within %d days", maxStatsDays)})
+		return "", "", false
+	}
+	return c.Query("from"), c.Query("to"), true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetCookStats verifies per-period rows are folded into one entry per cook.
+func TestGetCookStats(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getCookStatsQuery)).WithArgs("2025-02-01", "2025-02-28", notifyLeadTime.Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"cook_user_id", "cook_user_name", "meal_period", "count", "eaters", "late"}).
+			AddRow(5, "Mother", 1, 4, 6, 0).
+			AddRow(5, "Mother", 2, 12, 40, 3).
+			AddRow(1, "John", 2, 8, 25, 1))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/stats/cooks?from=2025-02-01&to=2025-02-28", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"cook_user_id":5,"cook_user_name":"Mother","periods":{"1":4,"2":12},"total":16,"people_cooked_for":46,"late_changes":3},
+		{"cook_user_id":1,"cook_user_name":"John","periods":{"2":8},"total":8,"people_cooked_for":25,"late_changes":1}
+	]`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetMemberStats verifies per-option rows are folded into one entry per member,
+// with skipped counting every option marked is_skip.
+func TestGetMemberStats(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMemberStatsQuery)).WithArgs("2025-02-01", "2025-02-07", notifyLeadTime.Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_name", "meal_option", "eats_at_home", "is_skip", "count", "deviations", "late"}).
+			AddRow(3, "Taro", 1, false, true, 2, 2, 1).
+			AddRow(3, "Taro", 2, true, false, 7, 0, 1).
+			AddRow(3, "Taro", 3, false, false, 5, 3, 1).
+			AddRow(4, "Hanako", 2, true, false, 13, 0, 0).
+			AddRow(4, "Hanako", 4, false, true, 1, 1, 0))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/stats/members?from=2025-02-01&to=2025-02-07", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"user_id":3,"user_name":"Taro","options":{"1":2,"2":7,"3":5},"home_meals":7,"skipped":2,"deviations":5,"late_changes":1},
+		{"user_id":4,"user_name":"Hanako","options":{"2":13,"4":1},"home_meals":13,"skipped":1,"deviations":1,"late_changes":0}
+	]`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestStatsInvalidRange verifies from/to are required, well-formed, ordered and at
+// most maxStatsDays apart.
+func TestStatsInvalidRange(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	for _, q := range []string{"from=2025-02-01", "from=2025-02-01&to=bad", "from=2025-02-28&to=2025-02-01",
+		"from=2025-01-01&to=2026-01-02", "from=2000-01-01&to=2099-12-31"} {
+		for _, path := range []string{"/api/stats/cooks?", "/api/stats/members?"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path+q, nil)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, path+q)
+		}
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
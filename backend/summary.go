@@ -0,0 +1,160 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// OptionCount is how many eaters chose one meal option for a period, and who.
+type OptionCount struct {
+	MealOption int      `json:"meal_option"`
+	Label      string   `json:"label"`
+	Count      int      `json:"count"`
+	Names      []string `json:"names"`
+}
+
+// PeriodSummary is the headcount for one meal period of one day.
+// Cook is nil for 各自; EatsAtHome totals the options with eats_at_home set plus the
+// Guests of that period, who always eat at home. Dietary lists what to avoid for them.
+type PeriodSummary struct {
+	Cook       *CookAssignment `json:"cook"`
+	EatsAtHome int             `json:"eats_at_home"`
+	Guests     int             `json:"guests"`
+	Options    []OptionCount   `json:"options"`
+	Dietary    *PeriodDietary  `json:"dietary"`
+}
+
+// getSummaryQuery counts, per date in [$1, $2], active period and meal option, the active
+// eaters whose effective choice is that option: the explicit meal, else the weekday
+// default, else なし(1), as in getMealsQuery. Cooks come from getCookSchedulesQuery
+// itself so both endpoints resolve them identically. Every active option is listed
+// even when nobody chose it; retired options appear only when chosen in the range.
+// Each row repeats the period's meal_guests total and, as a JSON array (NULL when
+// nobody has needs), its home eaters with dietary needs that apply that day: members
+// whose choice has eats_at_home set, in display order, then guests with dietary_notes
+// in the order they were added.
+const getSummaryQuery = `WITH cooks (date, meal_period, cook_user_id, cook_user_name) AS (` + getCookSchedulesQuery + `
+), choices AS (
+    SELECT d.date, p.id AS meal_period, u.id AS user_id, u.name, u.display_order,
+        COALESCE(m.meal_option, ud.meal_option, 1) AS meal_option
+    FROM users u
+    CROSS JOIN generate_series($1::date, $2::date, '1 day') AS d(date)
+    CROSS JOIN meal_periods p
+    LEFT JOIN meals m ON m.user_id = u.id AND m.date = d.date AND m.meal_period = p.id
+    LEFT JOIN user_defaults ud ON ud.user_id = u.id
+        AND ud.day_of_week = EXTRACT(DOW FROM d.date)
+        AND ud.meal_period = p.id
+    WHERE u.is_eater = true AND u.active = true AND p.active = true
+), guests AS (
+    SELECT date, meal_period, SUM(count) AS count
+    FROM meal_guests
+    WHERE date BETWEEN $1 AND $2
+    GROUP BY date, meal_period
+), eaters AS (
+    SELECT c.date, c.meal_period, c.display_order AS sort_key, c.user_id::bigint AS seq, c.user_id, c.name,
+        dt.allergies, dt.dislikes, EXTRACT(DOW FROM c.date)::int = ANY(dt.vegetarian_days) AS vegetarian, dt.notes
+    FROM choices c
+    JOIN meal_options o ON o.id = c.meal_option AND o.eats_at_home
+    JOIN user_dietary dt ON dt.user_id = c.user_id
+    UNION ALL
+    SELECT g.date, g.meal_period, NULL, g.id, NULL, COALESCE(g.name, h.name || 'さんのゲスト'),
+        '{}', '{}', false, g.dietary_notes
+    FROM meal_guests g
+    JOIN users h ON h.id = g.host_user_id
+    WHERE g.date BETWEEN $1 AND $2 AND g.dietary_notes IS NOT NULL
+), dietary AS (
+    SELECT date, meal_period, json_agg(json_build_object(
+        'user_id', user_id, 'name', name, 'allergies', allergies, 'dislikes', dislikes,
+        'vegetarian', vegetarian, 'notes', notes) ORDER BY sort_key NULLS LAST, seq) AS eaters
+    FROM eaters
+    WHERE cardinality(allergies) > 0 OR cardinality(dislikes) > 0 OR vegetarian OR notes IS NOT NULL
+    GROUP BY date, meal_period
+)
+SELECT k.date, k.meal_period, k.cook_user_id, k.cook_user_name, o.id, o.label, o.eats_at_home,
+    COALESCE(g.count, 0), COUNT(c.user_id),
+    COALESCE(array_agg(c.name ORDER BY c.display_order, c.user_id) FILTER (WHERE c.user_id IS NOT NULL), '{}'),
+    dt.eaters::text
+FROM cooks k
+JOIN meal_periods p ON p.id = k.meal_period
+JOIN meal_options o ON o.active OR o.id IN (SELECT meal_option FROM choices)
+LEFT JOIN choices c ON c.date = k.date::date AND c.meal_period = k.meal_period AND c.meal_option = o.id
+LEFT JOIN guests g ON g.date = k.date::date AND g.meal_period = k.meal_period
+LEFT JOIN dietary dt ON dt.date = k.date::date AND dt.meal_period = k.meal_period
+GROUP BY k.date, p.sort_order, k.meal_period, k.cook_user_id, k.cook_user_name, o.sort_order, o.id, o.label,
+    o.eats_at_home, g.count, dt.eaters::text
+ORDER BY k.date, p.sort_order, k.meal_period, o.sort_order, o.id`
+
+// getSummary returns per-day, per-period headcounts for the cook, keyed by date and
+// then meal period id, together with the dietary needs of the home eaters, computed
+// in a single query.
+func getSummary(c *gin.Context) {
+	startDate, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter. Must be a positive integer."})
+		return
+	}
+	endDate := startDate.AddDate(0, 0, days-1).Format("2006-01-02")
+
+	rows, err := db.Query(getSummaryQuery, startDate.Format("2006-01-02"), endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	result := make(map[string]map[int]*PeriodSummary)
+	for rows.Next() {
+		var dateStr string
+		var periodID int
+		var cookUserID sql.NullInt64
+		var cookUserName sql.NullString
+		var oc OptionCount
+		var eatsAtHome bool
+		var guests int
+		var dietary sql.NullString
+		if err := rows.Scan(&dateStr, &periodID, &cookUserID, &cookUserName,
+			&oc.MealOption, &oc.Label, &eatsAtHome, &guests, &oc.Count, pq.Array(&oc.Names), &dietary); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if _, ok := result[dateStr]; !ok {
+			result[dateStr] = make(map[int]*PeriodSummary)
+		}
+		ps, ok := result[dateStr][periodID]
+		if !ok {
+			// Every row of a period repeats its dietary eaters; decode them once.
+			var eaters []DietaryEater
+			if dietary.Valid {
+				if err := json.Unmarshal([]byte(dietary.String), &eaters); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			ps = &PeriodSummary{EatsAtHome: guests, Guests: guests, Options: []OptionCount{}, Dietary: newPeriodDietary(eaters)}
+			if cookUserID.Valid {
+				ps.Cook = &CookAssignment{CookUserID: int(cookUserID.Int64), CookUserName: cookUserName.String}
+			}
+			result[dateStr][periodID] = ps
+		}
+		if eatsAtHome {
+			ps.EatsAtHome += oc.Count
+		}
+		ps.Options = append(ps.Options, oc)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
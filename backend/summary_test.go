@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetSummary verifies that one query's rows are grouped per date and period, with
+// the cook, guests and home eaters totalled once per period and the dietary needs of
+// the home eaters, repeated on each row, decoded once per period.
+func TestGetSummary(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	dinnerDietary := `[
+		{"user_id": 6, "name": "Father", "allergies": ["ピーナッツ", "そば"], "dislikes": [], "vegetarian": false, "notes": null},
+		{"user_id": 5, "name": "Mother", "allergies": ["そば"], "dislikes": ["セロリ"], "vegetarian": true, "notes": "辛いものは控えめに"},
+		{"user_id": null, "name": "おばあちゃん", "allergies": [], "dislikes": [], "vegetarian": false, "notes": "柔らかいもの"}
+	]`
+	mock.ExpectQuery(regexp.QuoteMeta(getSummaryQuery)).WithArgs("2025-02-16", "2025-02-16").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period", "cook_user_id", "cook_user_name",
+			"meal_option", "label", "eats_at_home", "guests", "count", "names", "dietary"}).
+			AddRow("2025-02-16", 1, nil, nil, 1, "なし", false, 0, 3, "{Father,Mother,Taro}", nil).
+			AddRow("2025-02-16", 1, nil, nil, 2, "家", true, 0, 0, "{}", nil).
+			AddRow("2025-02-16", 2, 5, "Mother", 1, "なし", false, 2, 0, "{}", dinnerDietary).
+			AddRow("2025-02-16", 2, 5, "Mother", 2, "家", true, 2, 2, "{Father,Mother}", dinnerDietary).
+			AddRow("2025-02-16", 2, 5, "Mother", 3, "弁当", false, 2, 1, "{Taro}", dinnerDietary))
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/summary?date=2025-02-16&days=1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"2025-02-16": {
+			"1": {"cook": null, "eats_at_home": 0, "guests": 0, "options": [
+				{"meal_option":1,"label":"なし","count":3,"names":["Father","Mother","Taro"]},
+				{"meal_option":2,"label":"家","count":0,"names":[]}
+			], "dietary": {"allergies": [], "vegetarian": false, "eaters": []}},
+			"2": {"cook": {"cook_user_id":5,"cook_user_name":"Mother"}, "eats_at_home": 4, "guests": 2, "options": [
+				{"meal_option":1,"label":"なし","count":0,"names":[]},
+				{"meal_option":2,"label":"家","count":2,"names":["Father","Mother"]},
+				{"meal_option":3,"label":"弁当","count":1,"names":["Taro"]}
+			], "dietary": {"allergies": ["そば", "ピーナッツ"], "vegetarian": true, "eaters": [
+				{"user_id":6,"name":"Father","allergies":["ピーナッツ","そば"],"dislikes":[],"vegetarian":false,"notes":null},
+				{"user_id":5,"name":"Mother","allergies":["そば"],"dislikes":["セロリ"],"vegetarian":true,"notes":"辛いものは控えめに"},
+				{"user_id":null,"name":"おばあちゃん","allergies":[],"dislikes":[],"vegetarian":false,"notes":"柔らかいもの"}
+			]}}
+		}
+	}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetSummaryInvalidParams verifies parameter validation happens before the query.
+func TestGetSummaryInvalidParams(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	for _, q := range []string{"date=2025-02-16", "date=bad&days=1", "date=2025-02-16&days=0"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/summary?"+q, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, q)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserCreate is the request body for POST /api/users.
+type UserCreate struct {
+	Name         string      `json:"name"`
+	IsCook       bool        `json:"is_cook"`
+	IsEater      *bool       `json:"is_eater"`      // nil = true
+	DisplayOrder *int        `json:"display_order"` // nil = append to the end
+	Defaults     map[int]int `json:"defaults"`      // meal period id -> option, copied to every weekday
+}
+
+// UserPatch is the request body for PATCH /api/users/:user_id.
+// Nil fields are left unchanged.
+type UserPatch struct {
+	Name         *string `json:"name"`
+	IsCook       *bool   `json:"is_cook"`
+	IsEater      *bool   `json:"is_eater"`
+	DisplayOrder *int    `json:"display_order"`
+	Active       *bool   `json:"active"`
+	// Reassign moves remaining cook assignments when is_cook or active is turned off;
+	// see updateUserRoles.
+	Reassign *CookReassign `json:"reassign"`
+}
+
+// createUserStmt inserts a user; a NULL display_order places the user after everyone else.
+const createUserStmt = `INSERT INTO users (name, is_cook, is_eater, display_order)
+VALUES ($1, $2, $3, COALESCE($4::int, (SELECT COALESCE(MAX(display_order), 0) + 1 FROM users)))
+RETURNING id, name, is_cook, is_eater, display_order, active, is_admin`
+
+const seedUserDefaultsStmt = `INSERT INTO user_defaults (user_id, day_of_week, meal_period, meal_option)
+SELECT $1, dow, $2, $3 FROM generate_series(0, 6) AS dow`
+
+// createUser adds a household member and optionally seeds a full week of user_defaults,
+// so getMealsQuery has real defaults instead of falling back to option 1.
+func createUser(c *gin.Context) {
+	var req UserCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	isEater := true
+	if req.IsEater != nil {
+		isEater = *req.IsEater
+	}
+	v, err := newValidator(validatePeriods | validateOptions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, periodID := range sortedPeriodIDs(req.Defaults) {
+		field := fmt.Sprintf("defaults.%d", periodID)
+		if v.period(0, field, periodID, false) {
+			v.option(0, field, req.Defaults[periodID])
+		}
+	}
+	if v.respond(c) {
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var u User
+	if err := tx.QueryRow(createUserStmt, name, req.IsCook, isEater, nullableInt(req.DisplayOrder)).
+		Scan(&u.ID, &u.Name, &u.IsCook, &u.IsEater, &u.DisplayOrder, &u.Active, &u.IsAdmin); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, periodID := range sortedPeriodIDs(req.Defaults) {
+		if _, err := tx.Exec(seedUserDefaultsStmt, u.ID, periodID, req.Defaults[periodID]); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, u)
+}
+
+const updateUserStmt = `UPDATE users SET
+    name          = COALESCE($1::text, name),
+    is_cook       = COALESCE($2::bool, is_cook),
+    is_eater      = COALESCE($3::bool, is_eater),
+    display_order = COALESCE($4::int, display_order),
+    active        = COALESCE($5::bool, active)
+WHERE id = $6
+RETURNING id, name, is_cook, is_eater, display_order, active, is_admin`
+
+// updateUser renames a user, changes roles or display order, or (re)activates them.
+// Turning is_cook off is checked against remaining assignments as in updateUserRoles,
+// and turning is_eater or active off is refused while the user still hosts guests.
+// Admins cannot deactivate themselves; as only admins reach this handler, the
+// household always keeps an active admin.
+func updateUser(c *gin.Context) {
+	userID := c.Param("user_id")
+	var req UserPatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var name interface{}
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		if trimmed == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+			return
+		}
+		name = trimmed
+	}
+	if req.Active != nil && !*req.Active && userID == strconv.Itoa(currentUser(c).ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot deactivate yourself"})
+		return
+	}
+	if !validReassignTarget(c, userID, req.Reassign) {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var u User
+	err = tx.QueryRow(updateUserStmt, name, nullableBool(req.IsCook), nullableBool(req.IsEater),
+		nullableInt(req.DisplayOrder), nullableBool(req.Active), userID).
+		Scan(&u.ID, &u.Name, &u.IsCook, &u.IsEater, &u.DisplayOrder, &u.Active, &u.IsAdmin)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// A deactivated member can no more cook than one whose is_cook is off.
+	if (req.IsCook != nil && !*req.IsCook) || (req.Active != nil && !*req.Active) {
+		conflict, err := releaseCookAssignments(tx, userID, req.Reassign, currentUser(c).ID, changeSource(c))
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if conflict != nil {
+			tx.Rollback()
+			cookRoleConflictResponse(c, conflict)
+			return
+		}
+	}
+	// Likewise their guests would drop out of GET /api/meals with them.
+	if (req.IsEater != nil && !*req.IsEater) || (req.Active != nil && !*req.Active) {
+		guests, err := hostedGuests(tx, userID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(guests) > 0 {
+			tx.Rollback()
+			hostedGuestsConflictResponse(c, guests)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+const deactivateUserStmt = "UPDATE users SET active = false WHERE id = $1"
+
+const deleteUserStmt = "DELETE FROM users WHERE id = $1"
+
+// UserDeletion is the optional body of DELETE /api/users/:user_id.
+type UserDeletion struct {
+	// Reassign moves remaining cook assignments of a deactivated member, as in UserPatch.
+	Reassign *CookReassign `json:"reassign"`
+}
+
+// deleteUser deactivates a user by default, keeping their meals and cook_schedules
+// history; like PATCH active=false, remaining cook assignments must be moved with
+// reassign first and hosted guests moved or removed (409 otherwise). mode=hard removes the row; meals and user_defaults
+// are then wiped by ON DELETE CASCADE and cook assignments become 各自 via
+// ON DELETE SET NULL. As in updateUser, admins cannot remove themselves.
+func deleteUser(c *gin.Context) {
+	userID := c.Param("user_id")
+	stmt, message := deactivateUserStmt, "User deactivated"
+	mode := c.DefaultQuery("mode", "deactivate")
+	switch mode {
+	case "deactivate":
+	case "hard":
+		stmt, message = deleteUserStmt, "User deleted"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode. Use deactivate or hard."})
+		return
+	}
+	var req UserDeletion
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if userID == strconv.Itoa(currentUser(c).ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot remove yourself"})
+		return
+	}
+	if !validReassignTarget(c, userID, req.Reassign) {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := tx.Exec(stmt, userID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if mode == "deactivate" {
+		conflict, err := releaseCookAssignments(tx, userID, req.Reassign, currentUser(c).ID, changeSource(c))
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if conflict != nil {
+			tx.Rollback()
+			cookRoleConflictResponse(c, conflict)
+			return
+		}
+		guests, err := hostedGuests(tx, userID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(guests) > 0 {
+			tx.Rollback()
+			hostedGuestsConflictResponse(c, guests)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// loadUsers returns every user, including deactivated ones, keyed by id.
+func loadUsers() (map[int]User, error) {
+	rows, err := db.Query(getUsersQuery, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := map[int]User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.IsCook, &u.IsEater, &u.DisplayOrder, &u.Active, &u.IsAdmin); err != nil {
+			return nil, err
+		}
+		users[u.ID] = u
+	}
+	return users, rows.Err()
+}
+
+// nullableBool converts an optional bool into a SQL parameter (nil = NULL).
+func nullableBool(b *bool) interface{} {
+	if b == nil {
+		return nil
+	}
+	return *b
+}
+
+// nullableInt converts an optional int into a SQL parameter (nil = NULL).
+func nullableInt(i *int) interface{} {
+	if i == nil {
+		return nil
+	}
+	return *i
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// userRows returns the users the handler tests refer to, as getUsersQuery scans them.
+// John is the admin; John and Mother have the cook role.
+func userRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}).
+		AddRow(1, "John", true, true, 1, true, true).
+		AddRow(2, "Paul", false, true, 2, true, false).
+		AddRow(3, "Taro", false, true, 3, true, false).
+		AddRow(4, "Hanako", false, true, 4, true, false).
+		AddRow(5, "Mother", true, true, 5, true, false)
+}
+
+// TestCreateUser verifies POST /api/users inserts the user and seeds a full week
+// of user_defaults in the same transaction.
+func TestCreateUser(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(true).WillReturnRows(mealOptionRows())
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(createUserStmt)).
+		WithArgs("Hanako", false, true, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}).
+			AddRow(6, "Hanako", false, true, 6, true, false))
+	mock.ExpectExec(regexp.QuoteMeta(seedUserDefaultsStmt)).
+		WithArgs(6, 1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 7))
+	mock.ExpectExec(regexp.QuoteMeta(seedUserDefaultsStmt)).
+		WithArgs(6, 2, 2).
+		WillReturnResult(sqlmock.NewResult(0, 7))
+	mock.ExpectCommit()
+
+	payload := `{"name":" Hanako ","defaults":{"1":3,"2":2}}`
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/users", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":6,"name":"Hanako","is_cook":false,"is_eater":true,"display_order":6,"active":true,"is_admin":false}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCreateUserEmptyName verifies that a blank name is rejected before touching the DB.
+func TestCreateUserEmptyName(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/users", bytes.NewBufferString(`{"name":"  "}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCreateUserInvalidDefaults verifies that unknown periods and options in defaults
+// are a 422 before the user is inserted.
+func TestCreateUserInvalidDefaults(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(true).WillReturnRows(mealOptionRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/users", bytes.NewBufferString(`{"name":"Hanako","defaults":{"1":9,"7":2}}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[
+		{"index":0,"field":"defaults.1","message":"unknown meal option 9"},
+		{"index":0,"field":"defaults.7","message":"unknown meal period 7"}]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateUser verifies PATCH /api/users/:user_id passes NULL for omitted fields.
+func TestUpdateUser(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(updateUserStmt)).
+		WithArgs("Taro", nil, nil, 1, nil, "3").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}).
+			AddRow(3, "Taro", false, true, 1, true, false))
+	mock.ExpectCommit()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/api/users/3", bytes.NewBufferString(`{"name":"Taro","display_order":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":3,"name":"Taro","is_cook":false,"is_eater":true,"display_order":1,"active":true,"is_admin":false}`, w.Body.String())
+}
+
+// TestUpdateUserNotFound verifies 404 when user_id does not exist.
+func TestUpdateUserNotFound(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(updateUserStmt)).
+		WithArgs(nil, nil, nil, nil, true, "99").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_cook", "is_eater", "display_order", "active", "is_admin"}))
+	mock.ExpectRollback()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/api/users/99", bytes.NewBufferString(`{"active":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestDeleteUser verifies that DELETE /api/users/:user_id deactivates by default
+// and only removes the row with mode=hard.
+func TestDeleteUser(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(deactivateUserStmt)).
+		WithArgs("2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(futureCookSchedulesQuery)).WithArgs("2").
+		WillReturnRows(sqlmock.NewRows([]string{"date", "meal_period"}))
+	mock.ExpectQuery(regexp.QuoteMeta(cookDefaultSchedulesOfQuery)).WithArgs("2").
+		WillReturnRows(sqlmock.NewRows([]string{"day_of_week", "meal_period"}))
+	mock.ExpectQuery(regexp.QuoteMeta(futureHostedGuestsQuery)).WithArgs("2").WillReturnRows(mealGuestRows())
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(deleteUserStmt)).
+		WithArgs("2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/users/2", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"User deactivated"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/users/2?mode=hard", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"User deleted"}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestRemoveSelf verifies that admins can neither deactivate nor delete themselves,
+// so the household is never left without an admin.
+func TestRemoveSelf(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	r := setupRouter()
+	for _, tc := range []struct{ method, path, body string }{
+		{"PATCH", "/api/users/1", `{"active":false}`},
+		{"DELETE", "/api/users/1", ""},
+		{"DELETE", "/api/users/1?mode=hard", ""},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.path)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FieldError describes one invalid field of one element of a bulk request body.
+// Index is the element's position in the body and Field its JSON name; for a meal
+// choice the period id is appended ("options.2").
+type FieldError struct {
+	Index   int    `json:"index"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Masters a validator loads, combined with |.
+const (
+	validatePeriods = 1 << iota
+	validateOptions
+	validateUsers
+)
+
+// validator checks the elements of a bulk request against the masters and collects
+// every problem, so a client can fix all rows at once. Write handlers run it before
+// opening their transaction, so bad input gets a 422 instead of a Postgres error.
+type validator struct {
+	periods map[int]MealPeriod
+	options map[int]MealOption
+	users   map[int]User
+	errs    []FieldError
+}
+
+// newValidator loads the given masters, including inactive entries so they can be
+// told apart from unknown ids.
+func newValidator(masters int) (*validator, error) {
+	v := &validator{}
+	var err error
+	if masters&validatePeriods != 0 {
+		if v.periods, err = loadMealPeriods(); err != nil {
+			return nil, err
+		}
+	}
+	if masters&validateOptions != 0 {
+		if v.options, err = loadMealOptions(); err != nil {
+			return nil, err
+		}
+	}
+	if masters&validateUsers != 0 {
+		if v.users, err = loadUsers(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (v *validator) fail(i int, field, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Index: i, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// date checks a YYYY-MM-DD date.
+func (v *validator) date(i int, field, s string) {
+	if !validDate(s) {
+		v.fail(i, field, "must be a date in YYYY-MM-DD format")
+	}
+}
+
+// dayOfWeek checks 0 (Sunday) to 6 (Saturday).
+func (v *validator) dayOfWeek(i int, field string, d int) {
+	if d < 0 || d > 6 {
+		v.fail(i, field, "must be 0 (Sunday) to 6 (Saturday)")
+	}
+}
+
+// period checks a meal period id. Only deletions may name an inactive period.
+func (v *validator) period(i int, field string, id int, allowInactive bool) bool {
+	p, ok := v.periods[id]
+	switch {
+	case !ok:
+		v.fail(i, field, "unknown meal period %d", id)
+	case !p.Active && !allowInactive:
+		v.fail(i, field, "meal period %d is inactive", id)
+	default:
+		return true
+	}
+	return false
+}
+
+// mealOptions checks the period ids and option ids of an options map. With allowUnset,
+// option 0 (leave unchanged) is accepted.
+func (v *validator) mealOptions(i int, options map[int]int, allowUnset bool) {
+	for _, periodID := range sortedPeriodIDs(options) {
+		field := fmt.Sprintf("options.%d", periodID)
+		if !v.period(i, field, periodID, false) {
+			continue
+		}
+		id := options[periodID]
+		if id == 0 && allowUnset {
+			continue
+		}
+		v.option(i, field, id)
+	}
+}
+
+// option checks that a meal option exists and is active.
+func (v *validator) option(i int, field string, id int) {
+	o, ok := v.options[id]
+	switch {
+	case !ok:
+		v.fail(i, field, "unknown meal option %d", id)
+	case !o.Active:
+		v.fail(i, field, "meal option %d is inactive", id)
+	}
+}
+
+// member checks that a user exists and is active.
+func (v *validator) member(i int, field string, id int) bool {
+	u, ok := v.users[id]
+	switch {
+	case !ok:
+		v.fail(i, field, "unknown user %d", id)
+	case !u.Active:
+		v.fail(i, field, "user %d is inactive", id)
+	default:
+		return true
+	}
+	return false
+}
+
+// cook checks a cook assignment: nil (各自) or an active member with the cook role.
+func (v *validator) cook(i int, field string, id *int) {
+	if id == nil || !v.member(i, field, *id) {
+		return
+	}
+	if !v.users[*id].IsCook {
+		v.fail(i, field, "user %d is not a cook", *id)
+	}
+}
+
+// respond writes the collected errors as a 422 and reports whether it did.
+func (v *validator) respond(c *gin.Context) bool {
+	if len(v.errs) == 0 {
+		return false
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "validation failed", "errors": v.errs})
+	return true
+}
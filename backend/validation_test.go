@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBulkUpdateCookSchedulesValidation verifies every invalid field of every row is
+// reported in one 422 and nothing is written.
+func TestBulkUpdateCookSchedulesValidation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/cook-schedules", bytes.NewBufferString(`[
+		{"date":"2026-04-06","meal_period":1,"cook_user_id":5},
+		{"date":"2026-04-31","meal_period":9,"cook_user_id":2},
+		{"date":"2026-04-07","meal_period":2,"cook_user_id":42}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[
+		{"index":1,"field":"date","message":"must be a date in YYYY-MM-DD format"},
+		{"index":1,"field":"meal_period","message":"unknown meal period 9"},
+		{"index":1,"field":"cook_user_id","message":"user 2 is not a cook"},
+		{"index":2,"field":"cook_user_id","message":"unknown user 42"}
+	]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBulkUpdateMealsValidation verifies meal rows are checked for the user, date and
+// each option before authorization or cutoffs are looked at.
+func TestBulkUpdateMealsValidation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getMealOptionsQuery)).WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "label", "sort_order", "color", "eats_at_home", "is_skip", "is_bento", "active"}).
+			AddRow(1, "なし", 1, nil, false, true, false, true).
+			AddRow(3, "弁当", 3, nil, false, false, true, false))
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/meals/bulk-update",
+		bytes.NewBufferString(`[{"user_id":9,"date":"04/06","options":{"1":3,"2":0}}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[
+		{"index":0,"field":"user_id","message":"unknown user 9"},
+		{"index":0,"field":"date","message":"must be a date in YYYY-MM-DD format"},
+		{"index":0,"field":"options.1","message":"meal option 3 is inactive"}
+	]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateCookDefaultSchedulesValidation verifies weekdays outside 0-6 are rejected.
+func TestUpdateCookDefaultSchedulesValidation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db = mockDB
+
+	mock.ExpectQuery(regexp.QuoteMeta(getMealPeriodsQuery)).WithArgs(true).WillReturnRows(mealPeriodRows())
+	mock.ExpectQuery(regexp.QuoteMeta(getUsersQuery)).WithArgs(true).WillReturnRows(userRows())
+
+	r := setupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/cook-default-schedules",
+		bytes.NewBufferString(`[{"day_of_week":7,"meal_period":1,"cook_user_id":null}]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[
+		{"index":0,"field":"day_of_week","message":"must be 0 (Sunday) to 6 (Saturday)"}
+	]}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,17 +3,63 @@ CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     name TEXT NOT NULL,
     is_cook  BOOL NOT NULL DEFAULT false,
-    is_eater BOOL NOT NULL DEFAULT true
+    is_eater BOOL NOT NULL DEFAULT true,
+    display_order INT NOT NULL DEFAULT 0,
+    -- active=false means deactivated: hidden from schedules, history kept.
+    active   BOOL NOT NULL DEFAULT true,
+    -- Admins manage members, roles and cook defaults, and may edit anyone's meals.
+    is_admin BOOL NOT NULL DEFAULT false,
+    -- bcrypt hashes; NULL = not set. A member logs in with either one.
+    password_hash TEXT,
+    pin_hash      TEXT,
+    -- Standing 弁当 preference (size, no rice, ...) shown to the cook; NULL = none.
+    bento_note    TEXT
 );
 
+-- Login sessions. Only the SHA-256 of the token is stored.
+CREATE TABLE IF NOT EXISTS sessions (
+    token_hash TEXT PRIMARY KEY,
+    user_id    INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- iCalendar feed tokens (calendar apps cannot log in). One per member, hashed like
+-- sessions; issuing a new token revokes the old one.
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_id    INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Meal periods table (Master data), e.g. 昼食 / 夕食.
+-- cutoff_time is the local time after which changes for that day count as late;
+-- cutoff_mode decides whether such changes are rejected, need the cook's
+-- acknowledgement, or are accepted with a notification.
+-- Retire periods with active=false instead of deleting them; meals reference them.
 CREATE TABLE IF NOT EXISTS meal_periods (
-    id SERIAL PRIMARY KEY
+    id SERIAL PRIMARY KEY,
+    name        TEXT NOT NULL,
+    sort_order  INT  NOT NULL DEFAULT 0,
+    cutoff_time TIME,  -- NULL = no cutoff
+    cutoff_mode TEXT NOT NULL DEFAULT 'notify'
+        CHECK (cutoff_mode IN ('reject', 'acknowledge', 'notify')),
+    packs_bento BOOL NOT NULL DEFAULT false,  -- its cook packs the 弁当 (昼食)
+    active      BOOL NOT NULL DEFAULT true
 );
 
 -- Meal options table (Master data)
--- This stores unique meal options (e.g., None, Home, Obento)
+-- This stores unique meal options (e.g., None, Home, Obento).
+-- Retire options with active=false instead of deleting them; meals reference them.
 CREATE TABLE IF NOT EXISTS meal_options (
-    id SERIAL PRIMARY KEY
+    id SERIAL PRIMARY KEY,
+    label        TEXT NOT NULL,
+    sort_order   INT  NOT NULL DEFAULT 0,
+    color        TEXT,  -- #rrggbb, NULL = no colour
+    eats_at_home BOOL NOT NULL DEFAULT false,
+    is_skip      BOOL NOT NULL DEFAULT false,  -- not eating at all (なし)
+    is_bento     BOOL NOT NULL DEFAULT false,  -- takes a packed lunch (弁当)
+    active       BOOL NOT NULL DEFAULT true
 );
 
 -- Meals table to store users' meal choices
@@ -21,17 +67,18 @@ CREATE TABLE IF NOT EXISTS meals (
     id SERIAL PRIMARY KEY,
     user_id INT REFERENCES users(id) ON DELETE CASCADE,  -- Foreign key to users table
     date DATE NOT NULL,  -- The date of the meal
-    meal_period INT REFERENCES meal_periods(id) ON DELETE SET NULL,  -- 0: lunch, 1: dinner
+    meal_period INT REFERENCES meal_periods(id) ON DELETE SET NULL,  -- Meal period (referencing meal_periods)
     meal_option INT REFERENCES meal_options(id) ON DELETE SET NULL,  -- Meal option (referencing meal_options)
     UNIQUE (user_id, date, meal_period)
 );
 
+-- User defaults: weekday-based default meal option per meal period.
 CREATE TABLE IF NOT EXISTS user_defaults (
     user_id INT REFERENCES users(id) ON DELETE CASCADE,
     day_of_week INT NOT NULL,  -- 0: Sun, 1: Mon,... 6: Sat
-    lunch INT,
-    dinner INT,
-    PRIMARY KEY (user_id, day_of_week)
+    meal_period INT REFERENCES meal_periods(id) ON DELETE CASCADE,
+    meal_option INT REFERENCES meal_options(id),
+    PRIMARY KEY (user_id, day_of_week, meal_period)
 );
 
 -- Cook schedule: weekday-based default cook assignment per meal period.
@@ -51,3 +98,172 @@ CREATE TABLE IF NOT EXISTS cook_schedules (
     cook_user_id INT REFERENCES users(id) ON DELETE SET NULL,
     PRIMARY KEY (date, meal_period)
 );
+
+-- Menu per date and meal period: what the cook plans to serve.
+-- updated_by is the member who last wrote it.
+CREATE TABLE IF NOT EXISTS menus (
+    date        DATE   NOT NULL,
+    meal_period INT    NOT NULL REFERENCES meal_periods(id) ON DELETE CASCADE,
+    dishes      TEXT[] NOT NULL DEFAULT '{}',
+    notes       TEXT,
+    recipe_url  TEXT,
+    updated_by  INT    REFERENCES users(id) ON DELETE SET NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (date, meal_period)
+);
+
+-- Per-serving ingredients of the dishes on a menu, for GET /api/shopping-list.
+CREATE TABLE IF NOT EXISTS menu_ingredients (
+    id          BIGSERIAL PRIMARY KEY,
+    date        DATE    NOT NULL,
+    meal_period INT     NOT NULL,
+    dish        TEXT    NOT NULL,
+    name        TEXT    NOT NULL,
+    quantity    NUMERIC NOT NULL CHECK (quantity > 0),
+    unit        TEXT    NOT NULL DEFAULT '',
+    FOREIGN KEY (date, meal_period) REFERENCES menus(date, meal_period) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS menu_ingredients_date_idx ON menu_ingredients (date, meal_period);
+
+-- Shared recipe library. Ingredient quantities are for the recipe's servings;
+-- tags are free text such as 弁当向け or 作り置き.
+CREATE TABLE IF NOT EXISTS recipes (
+    id           SERIAL PRIMARY KEY,
+    title        TEXT   NOT NULL,
+    servings     INT    NOT NULL CHECK (servings > 0),
+    prep_minutes INT    CHECK (prep_minutes >= 0),
+    steps        TEXT[] NOT NULL DEFAULT '{}',
+    tags         TEXT[] NOT NULL DEFAULT '{}',
+    created_by   INT    REFERENCES users(id) ON DELETE SET NULL,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS recipes_tags_idx ON recipes USING GIN (tags);
+
+CREATE TABLE IF NOT EXISTS recipe_ingredients (
+    id        BIGSERIAL PRIMARY KEY,
+    recipe_id INT     NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+    name      TEXT    NOT NULL,
+    quantity  NUMERIC NOT NULL CHECK (quantity > 0),
+    unit      TEXT    NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS recipe_ingredients_recipe_idx ON recipe_ingredients (recipe_id);
+
+-- Dietary profile per member, shown to the cook for the periods they eat at home.
+-- vegetarian_days are weekdays (0 = Sunday).
+CREATE TABLE IF NOT EXISTS user_dietary (
+    user_id         INT    PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    allergies       TEXT[] NOT NULL DEFAULT '{}',
+    dislikes        TEXT[] NOT NULL DEFAULT '{}',
+    vegetarian_days INT[]  NOT NULL DEFAULT '{}',
+    notes           TEXT,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Guests joining a meal: visitors who are not members (e.g. grandma for dinner).
+-- host_user_id is the member who invited them; they count towards the headcount.
+CREATE TABLE IF NOT EXISTS meal_guests (
+    id            BIGSERIAL PRIMARY KEY,
+    date          DATE NOT NULL,
+    meal_period   INT  NOT NULL REFERENCES meal_periods(id) ON DELETE CASCADE,
+    count         INT  NOT NULL CHECK (count > 0),
+    name          TEXT,
+    host_user_id  INT  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dietary_notes TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS meal_guests_date_idx ON meal_guests (date, meal_period);
+
+-- Audit log of meal changes, written in the same statement as the upsert.
+-- old_option NULL means the member had no explicit choice (their default applied).
+-- Append-only: rows are never updated except actor_id being cleared when that user is deleted.
+CREATE TABLE IF NOT EXISTS meal_changes (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     INT  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    date        DATE NOT NULL,
+    meal_period INT  NOT NULL,
+    old_option  INT,
+    new_option  INT  NOT NULL,
+    actor_id    INT  REFERENCES users(id) ON DELETE SET NULL,
+    source      TEXT NOT NULL,  -- endpoint that made the change, e.g. 'PUT /api/meals/bulk-update'
+    cutoff_mode TEXT,           -- mode applied when changed past the cutoff, or 'override'; NULL = in time
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS meal_changes_date_idx ON meal_changes (date, user_id);
+
+-- Late meal changes the cook has to confirm. cook_user_id is the cook resolved when
+-- the change was made; notified_at is the last notification (re-sent while pending).
+CREATE TABLE IF NOT EXISTS acknowledgements (
+    id              BIGSERIAL PRIMARY KEY,
+    meal_change_id  BIGINT NOT NULL UNIQUE REFERENCES meal_changes(id) ON DELETE CASCADE,
+    cook_user_id    INT    NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    notified_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    notify_count    INT    NOT NULL DEFAULT 1,
+    acknowledged_at TIMESTAMPTZ,
+    acknowledged_by INT    REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS acknowledgements_pending_idx ON acknowledgements (cook_user_id)
+    WHERE acknowledged_at IS NULL;
+
+-- Audit log of cook_schedules overrides.
+-- *_override=false means no override row (weekday default applied);
+-- *_override=true with a NULL cook means explicitly 各自.
+CREATE TABLE IF NOT EXISTS cook_schedule_changes (
+    id               BIGSERIAL PRIMARY KEY,
+    date             DATE NOT NULL,
+    meal_period      INT  NOT NULL,
+    old_override     BOOL NOT NULL,
+    old_cook_user_id INT,
+    new_override     BOOL NOT NULL,
+    new_cook_user_id INT,
+    actor_id         INT  REFERENCES users(id) ON DELETE SET NULL,
+    source           TEXT NOT NULL,
+    changed_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS cook_schedule_changes_date_idx ON cook_schedule_changes (date);
+
+-- Audit log of cook_default_schedules (weekday defaults); a NULL cook is 各自.
+CREATE TABLE IF NOT EXISTS cook_default_schedule_changes (
+    id               BIGSERIAL PRIMARY KEY,
+    day_of_week      INT  NOT NULL CHECK (day_of_week BETWEEN 0 AND 6),
+    meal_period      INT  NOT NULL,
+    old_cook_user_id INT,
+    new_cook_user_id INT,
+    actor_id         INT  REFERENCES users(id) ON DELETE SET NULL,
+    source           TEXT NOT NULL,
+    changed_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS cook_default_schedule_changes_dow_idx ON cook_default_schedule_changes (day_of_week);
+
+-- Where notifications are sent. kind decides which columns are used; an empty
+-- events list means every event kind.
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id            SERIAL PRIMARY KEY,
+    kind          TEXT    NOT NULL CHECK (kind IN ('slack', 'webhook', 'email', 'line')),
+    name          TEXT    NOT NULL,
+    url           TEXT,
+    token         TEXT,
+    smtp_host     TEXT,
+    smtp_port     INT     CHECK (smtp_port BETWEEN 1 AND 65535),
+    smtp_username TEXT,
+    smtp_password TEXT,
+    email_from    TEXT,
+    email_to      TEXT[]  NOT NULL DEFAULT '{}',
+    events        TEXT[]  NOT NULL DEFAULT '{}',
+    active        BOOLEAN NOT NULL DEFAULT true
+);
+
+-- How each member wants the notifications addressed to them: which events, on which
+-- channel (NULL = all), outside which quiet hours, and their Slack member id.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id         INT    PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    events          TEXT[] NOT NULL DEFAULT '{}',
+    channel_id      INT    REFERENCES notification_channels(id) ON DELETE SET NULL,
+    quiet_start     TIME,
+    quiet_end       TIME,
+    slack_member_id TEXT,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((quiet_start IS NULL) = (quiet_end IS NULL))
+);
@@ -0,0 +1,6 @@
+-- Migration: add display order and soft-deactivate flag to users.
+-- Deactivated users keep their meals / cook_schedules history.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_order INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS active BOOL NOT NULL DEFAULT true;
+
+UPDATE users SET display_order = id WHERE display_order = 0;
@@ -0,0 +1,15 @@
+-- Migration: move meal option labels from code into meal_options.
+-- Existing ids 1..3 keep their meaning (なし / 家 / 弁当).
+ALTER TABLE meal_options ADD COLUMN IF NOT EXISTS label        TEXT NOT NULL DEFAULT '';
+ALTER TABLE meal_options ADD COLUMN IF NOT EXISTS sort_order   INT  NOT NULL DEFAULT 0;
+ALTER TABLE meal_options ADD COLUMN IF NOT EXISTS color        TEXT;
+ALTER TABLE meal_options ADD COLUMN IF NOT EXISTS eats_at_home BOOL NOT NULL DEFAULT false;
+ALTER TABLE meal_options ADD COLUMN IF NOT EXISTS active       BOOL NOT NULL DEFAULT true;
+ALTER TABLE meal_options ALTER COLUMN label DROP DEFAULT;
+
+UPDATE meal_options SET label = 'なし', sort_order = 1, color = NULL,      eats_at_home = false WHERE id = 1;
+UPDATE meal_options SET label = '家',   sort_order = 2, color = '#c8e6c9', eats_at_home = true  WHERE id = 2;
+UPDATE meal_options SET label = '弁当', sort_order = 3, color = '#ffe0b2', eats_at_home = false WHERE id = 3;
+
+-- Rows were inserted with explicit ids; move the sequence past them for POST /api/meal-options.
+SELECT setval(pg_get_serial_sequence('meal_options', 'id'), (SELECT MAX(id) FROM meal_options));
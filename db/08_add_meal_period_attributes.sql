@@ -0,0 +1,34 @@
+-- Migration: make meal periods configurable.
+-- Existing ids 1 and 2 keep their meaning (昼食 / 夕食).
+ALTER TABLE meal_periods ADD COLUMN IF NOT EXISTS name        TEXT NOT NULL DEFAULT '';
+ALTER TABLE meal_periods ADD COLUMN IF NOT EXISTS sort_order  INT  NOT NULL DEFAULT 0;
+ALTER TABLE meal_periods ADD COLUMN IF NOT EXISTS cutoff_time TIME;
+ALTER TABLE meal_periods ADD COLUMN IF NOT EXISTS active      BOOL NOT NULL DEFAULT true;
+ALTER TABLE meal_periods ALTER COLUMN name DROP DEFAULT;
+
+UPDATE meal_periods SET name = '昼食', sort_order = 1 WHERE id = 1;
+UPDATE meal_periods SET name = '夕食', sort_order = 2 WHERE id = 2;
+
+-- Rows were inserted with explicit ids; move the sequence past them for POST /api/meal-periods.
+SELECT setval(pg_get_serial_sequence('meal_periods', 'id'), (SELECT MAX(id) FROM meal_periods));
+
+-- user_defaults: one row per (user, weekday, period) instead of lunch/dinner columns.
+BEGIN;
+ALTER TABLE user_defaults RENAME TO user_defaults_old;
+ALTER TABLE user_defaults_old RENAME CONSTRAINT user_defaults_pkey TO user_defaults_old_pkey;
+
+CREATE TABLE user_defaults (
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    day_of_week INT NOT NULL,
+    meal_period INT REFERENCES meal_periods(id) ON DELETE CASCADE,
+    meal_option INT REFERENCES meal_options(id),
+    PRIMARY KEY (user_id, day_of_week, meal_period)
+);
+
+INSERT INTO user_defaults (user_id, day_of_week, meal_period, meal_option)
+SELECT user_id, day_of_week, 1, lunch FROM user_defaults_old WHERE lunch IS NOT NULL
+UNION ALL
+SELECT user_id, day_of_week, 2, dinner FROM user_defaults_old WHERE dinner IS NOT NULL;
+
+DROP TABLE user_defaults_old;
+COMMIT;
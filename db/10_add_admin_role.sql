@@ -0,0 +1,7 @@
+-- Migration: add the admin role.
+-- Existing cooks (usually the parents) become admins so the household keeps
+-- someone who can manage members and cook defaults.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOL NOT NULL DEFAULT false;
+
+UPDATE users SET is_admin = true
+WHERE is_cook AND active AND NOT EXISTS (SELECT 1 FROM users WHERE is_admin);
@@ -0,0 +1,34 @@
+-- Migration: add append-only change history for meals and cook assignments.
+-- Both tables are new; no existing tables are modified.
+-- Audit log of meal changes, written in the same statement as the upsert.
+-- old_option NULL means the member had no explicit choice (their default applied).
+-- Append-only: rows are never updated except actor_id being cleared when that user is deleted.
+CREATE TABLE IF NOT EXISTS meal_changes (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     INT  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    date        DATE NOT NULL,
+    meal_period INT  NOT NULL,
+    old_option  INT,
+    new_option  INT  NOT NULL,
+    actor_id    INT  REFERENCES users(id) ON DELETE SET NULL,
+    source      TEXT NOT NULL,  -- endpoint that made the change, e.g. 'PUT /api/meals/bulk-update'
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS meal_changes_date_idx ON meal_changes (date, user_id);
+
+-- Audit log of cook_schedules overrides.
+-- *_override=false means no override row (weekday default applied);
+-- *_override=true with a NULL cook means explicitly 各自.
+CREATE TABLE IF NOT EXISTS cook_schedule_changes (
+    id               BIGSERIAL PRIMARY KEY,
+    date             DATE NOT NULL,
+    meal_period      INT  NOT NULL,
+    old_override     BOOL NOT NULL,
+    old_cook_user_id INT,
+    new_override     BOOL NOT NULL,
+    new_cook_user_id INT,
+    actor_id         INT  REFERENCES users(id) ON DELETE SET NULL,
+    source           TEXT NOT NULL,
+    changed_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS cook_schedule_changes_date_idx ON cook_schedule_changes (date);
@@ -0,0 +1,6 @@
+-- Migration: per-period cutoff modes.
+-- Existing periods keep today's behaviour (late changes are accepted and notified).
+ALTER TABLE meal_periods ADD COLUMN IF NOT EXISTS cutoff_mode TEXT NOT NULL DEFAULT 'notify'
+    CHECK (cutoff_mode IN ('reject', 'acknowledge', 'notify'));
+
+ALTER TABLE meal_changes ADD COLUMN IF NOT EXISTS cutoff_mode TEXT;
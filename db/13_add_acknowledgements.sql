@@ -0,0 +1,16 @@
+-- Migration: cook acknowledgements for late meal changes.
+-- The table is new; no existing tables are modified.
+-- One row per late meal change the cook has to confirm. cook_user_id is the cook
+-- resolved when the change was made (cook_schedules, then cook_default_schedules).
+-- notified_at is the last Slack notification; pending rows are re-sent after an interval.
+CREATE TABLE IF NOT EXISTS acknowledgements (
+    id              BIGSERIAL PRIMARY KEY,
+    meal_change_id  BIGINT NOT NULL UNIQUE REFERENCES meal_changes(id) ON DELETE CASCADE,
+    cook_user_id    INT    NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    notified_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    notify_count    INT    NOT NULL DEFAULT 1,
+    acknowledged_at TIMESTAMPTZ,
+    acknowledged_by INT    REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS acknowledgements_pending_idx ON acknowledgements (cook_user_id)
+    WHERE acknowledged_at IS NULL;
@@ -0,0 +1,9 @@
+-- Migration: tokens for the iCalendar feeds.
+-- The table is new; no existing tables are modified.
+-- One token per member authorises both of their feeds. Only the SHA-256 hash is
+-- stored, as for sessions; issuing a new token replaces (revokes) the old one.
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_id    INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,16 @@
+-- Migration: guests joining a meal.
+-- The table is new; no existing tables are modified.
+-- One row per group of visitors eating one period of one day, so a one-off diner
+-- does not need a users row. host_user_id is the member who invited them; guests
+-- are counted in the headcounts alongside the members.
+CREATE TABLE IF NOT EXISTS meal_guests (
+    id            BIGSERIAL PRIMARY KEY,
+    date          DATE NOT NULL,
+    meal_period   INT  NOT NULL REFERENCES meal_periods(id) ON DELETE CASCADE,
+    count         INT  NOT NULL CHECK (count > 0),
+    name          TEXT,
+    host_user_id  INT  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dietary_notes TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS meal_guests_date_idx ON meal_guests (date, meal_period);
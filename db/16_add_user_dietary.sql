@@ -0,0 +1,12 @@
+-- Migration: dietary profiles per member.
+-- The table is new; no existing tables are modified.
+-- At most one row per member; members without one have no dietary needs.
+-- vegetarian_days are weekdays (0 = Sunday) on which the member eats vegetarian.
+CREATE TABLE IF NOT EXISTS user_dietary (
+    user_id         INT    PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    allergies       TEXT[] NOT NULL DEFAULT '{}',
+    dislikes        TEXT[] NOT NULL DEFAULT '{}',
+    vegetarian_days INT[]  NOT NULL DEFAULT '{}',
+    notes           TEXT,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
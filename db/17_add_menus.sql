@@ -0,0 +1,14 @@
+-- Migration: menus per date and meal period.
+-- The table is new; no existing tables are modified.
+-- What the cook plans to serve. At most one row per date and period; a period
+-- without a row has no menu yet.
+CREATE TABLE IF NOT EXISTS menus (
+    date        DATE   NOT NULL,
+    meal_period INT    NOT NULL REFERENCES meal_periods(id) ON DELETE CASCADE,
+    dishes      TEXT[] NOT NULL DEFAULT '{}',
+    notes       TEXT,
+    recipe_url  TEXT,
+    updated_by  INT    REFERENCES users(id) ON DELETE SET NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (date, meal_period)
+);
@@ -0,0 +1,16 @@
+-- Migration: per-serving ingredients of menu dishes.
+-- The table is new; no existing tables are modified.
+-- quantity is for one serving; GET /api/shopping-list multiplies it by the number of
+-- people eating at home. Rows go away with their menu.
+CREATE TABLE IF NOT EXISTS menu_ingredients (
+    id          BIGSERIAL PRIMARY KEY,
+    date        DATE    NOT NULL,
+    meal_period INT     NOT NULL,
+    dish        TEXT    NOT NULL,
+    name        TEXT    NOT NULL,
+    quantity    NUMERIC NOT NULL CHECK (quantity > 0),
+    unit        TEXT    NOT NULL DEFAULT '',
+    FOREIGN KEY (date, meal_period) REFERENCES menus(date, meal_period) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS menu_ingredients_date_idx ON menu_ingredients (date, meal_period);
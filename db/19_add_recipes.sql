@@ -0,0 +1,27 @@
+-- Migration: shared recipe library.
+-- The tables are new; no existing tables are modified.
+-- Ingredient quantities are for the recipe's servings. Tags are free text such as
+-- 弁当向け or 作り置き; GET /api/recipes/suggestions offers 弁当向け recipes for days
+-- with 弁当.
+CREATE TABLE IF NOT EXISTS recipes (
+    id           SERIAL PRIMARY KEY,
+    title        TEXT   NOT NULL,
+    servings     INT    NOT NULL CHECK (servings > 0),
+    prep_minutes INT    CHECK (prep_minutes >= 0),
+    steps        TEXT[] NOT NULL DEFAULT '{}',
+    tags         TEXT[] NOT NULL DEFAULT '{}',
+    created_by   INT    REFERENCES users(id) ON DELETE SET NULL,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS recipes_tags_idx ON recipes USING GIN (tags);
+
+CREATE TABLE IF NOT EXISTS recipe_ingredients (
+    id        BIGSERIAL PRIMARY KEY,
+    recipe_id INT     NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+    name      TEXT    NOT NULL,
+    quantity  NUMERIC NOT NULL CHECK (quantity > 0),
+    unit      TEXT    NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS recipe_ingredients_recipe_idx ON recipe_ingredients (recipe_id);
@@ -0,0 +1,4 @@
+-- Migration: standing 弁当 preference per member.
+-- Adds a nullable column to users; existing rows get NULL (no preference).
+-- Shown to the cook in GET /api/bento and the evening 弁当 reminder.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bento_note TEXT;
@@ -0,0 +1,21 @@
+-- Migration: notification channels of the household.
+-- The table is new; no existing tables are modified. SLACK_WEBHOOK_URL keeps
+-- working alongside it, so existing deployments need no rows here.
+-- kind decides which columns are used: slack and webhook post to url, line posts
+-- to url (LINE Notify when NULL) with token, email sends through smtp_* to email_to.
+-- events lists the event kinds to send; empty means all of them.
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id            SERIAL PRIMARY KEY,
+    kind          TEXT    NOT NULL CHECK (kind IN ('slack', 'webhook', 'email', 'line')),
+    name          TEXT    NOT NULL,
+    url           TEXT,
+    token         TEXT,
+    smtp_host     TEXT,
+    smtp_port     INT     CHECK (smtp_port BETWEEN 1 AND 65535),
+    smtp_username TEXT,
+    smtp_password TEXT,
+    email_from    TEXT,
+    email_to      TEXT[]  NOT NULL DEFAULT '{}',
+    events        TEXT[]  NOT NULL DEFAULT '{}',
+    active        BOOLEAN NOT NULL DEFAULT true
+);
@@ -0,0 +1,18 @@
+-- Migration: notification preferences per member.
+-- The table is new; no existing tables are modified. Members without a row get
+-- every event addressed to them on every channel, at any time.
+-- Late meal changes, acknowledgement reminders and the 弁当 reminder are addressed to
+-- the members concerned; these settings decide which of them reach whom, where.
+-- events: kinds the member wants (empty = all). channel_id: the only channel to reach
+-- them on (NULL = all). quiet_start/quiet_end: local times between which they get
+-- nothing; quiet_start > quiet_end wraps midnight. slack_member_id: used to @-mention.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id         INT    PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    events          TEXT[] NOT NULL DEFAULT '{}',
+    channel_id      INT    REFERENCES notification_channels(id) ON DELETE SET NULL,
+    quiet_start     TIME,
+    quiet_end       TIME,
+    slack_member_id TEXT,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((quiet_start IS NULL) = (quiet_end IS NULL))
+);
@@ -0,0 +1,15 @@
+-- Migration: add an audit log for weekday default cook assignments.
+-- The table is new; no existing tables are modified.
+-- Like cook_schedule_changes, but keyed by weekday instead of date; a NULL cook is
+-- 各自 (no cook_default_schedules row means the same).
+CREATE TABLE IF NOT EXISTS cook_default_schedule_changes (
+    id               BIGSERIAL PRIMARY KEY,
+    day_of_week      INT  NOT NULL CHECK (day_of_week BETWEEN 0 AND 6),
+    meal_period      INT  NOT NULL,
+    old_cook_user_id INT,
+    new_cook_user_id INT,
+    actor_id         INT  REFERENCES users(id) ON DELETE SET NULL,
+    source           TEXT NOT NULL,
+    changed_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS cook_default_schedule_changes_dow_idx ON cook_default_schedule_changes (day_of_week);
@@ -0,0 +1,5 @@
+-- Migration: mark the meal options that mean not eating at all.
+-- GET /api/stats/members counts these as skipped instead of assuming id 1 (なし).
+ALTER TABLE meal_options ADD COLUMN IF NOT EXISTS is_skip BOOL NOT NULL DEFAULT false;
+
+UPDATE meal_options SET is_skip = true WHERE id = 1;
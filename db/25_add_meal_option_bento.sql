@@ -0,0 +1,6 @@
+-- Migration: mark the meal options that mean taking a packed lunch (弁当).
+-- The 弁当 list, its reminder and the recipe suggestions look these up instead of
+-- assuming id 3.
+ALTER TABLE meal_options ADD COLUMN IF NOT EXISTS is_bento BOOL NOT NULL DEFAULT false;
+
+UPDATE meal_options SET is_bento = true WHERE id = 3;
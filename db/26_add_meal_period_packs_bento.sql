@@ -0,0 +1,6 @@
+-- Migration: mark the meal period whose cook packs the 弁当.
+-- GET /api/bento and its reminder used the first period in display order, which is
+-- 朝食 once that is added; id 1 (昼食) keeps the role.
+ALTER TABLE meal_periods ADD COLUMN IF NOT EXISTS packs_bento BOOL NOT NULL DEFAULT false;
+
+UPDATE meal_periods SET packs_bento = true WHERE id = 1;
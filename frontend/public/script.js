@@ -2,6 +2,13 @@
 let scheduleData = null;
 // Global variable to store cook schedule data.
 let cookScheduleData = null;
+// Send the user to the login page whenever the session is missing or expired.
+$(document).ajaxError(function(event, xhr) {
+  if (xhr.status === 401) {
+    window.location.href = '/login.html?next=' + encodeURIComponent(window.location.pathname + window.location.search);
+  }
+});
+
 // Global language setting; default is Japanese.
 let currentLang = "ja";
 
@@ -39,18 +46,14 @@ const translations = {
     startDateLabel: "Start Date (YYYY-MM-DD):",
     daysLabel: "Number of Days:",
     loadScheduleButton: "Load Schedule",
-    dateHeader: "Date",
-    lunchHeader: "Lunch",
-    dinnerHeader: "Dinner"
+    dateHeader: "Date"
   },
   ja: {
     pageTitle: "ごはん管理",
     startDateLabel: "開始日 (YYYY-MM-DD):",
     daysLabel: "日数:",
     loadScheduleButton: "スケジュール読み込み",
-    dateHeader: "日付",
-    lunchHeader: "昼",
-    dinnerHeader: "夜"
+    dateHeader: "日付"
   }
 };
 
@@ -98,24 +101,27 @@ $(document).ready(function() {
   }
   $('#startDate').val(getToday());
 
-  // Meal options mapping (numeric keys with text labels).
-  const mealOptions = {
-    1: "なし",
-    2: "家",
-    3: "弁当"
-  };
+  // Meal options loaded from /api/meal-options (already in display order).
+  let mealOptions = [];
+  // Meal periods loaded from /api/meal-periods (already in display order).
+  let mealPeriods = [];
 
-  // Load schedule from backend (meals and cook schedules in parallel).
+  // Load schedule from backend (meals, cook schedules, meal options and periods in parallel).
   function loadSchedule() {
     const startDate = $('#startDate').val();
     const days = $('#days').val();
     $.when(
       $.ajax({ url: '/api/meals', method: 'GET', data: { date: startDate, days: days } }),
-      $.ajax({ url: '/api/cook-schedules', method: 'GET', data: { date: startDate, days: days } })
-    ).done(function(mealsResult, cookResult) {
+      $.ajax({ url: '/api/cook-schedules', method: 'GET', data: { date: startDate, days: days } }),
+      $.ajax({ url: '/api/meal-options', method: 'GET' }),
+      $.ajax({ url: '/api/meal-periods', method: 'GET' })
+    ).done(function(mealsResult, cookResult, optionsResult, periodsResult) {
       scheduleData = mealsResult[0];
       cookScheduleData = cookResult[0];
+      mealOptions = optionsResult[0];
+      mealPeriods = periodsResult[0];
       renderSchedule(scheduleData, cookScheduleData);
+      loadAcknowledgements();
     }).fail(function(err) {
       alert('Failed to load schedule.');
       console.error(err);
@@ -131,21 +137,22 @@ $(document).ready(function() {
       return;
     }
     const weekdayNames = getWeekdayNames();
-    // Extract user list from the first date's data.
-    const firstDateMeals = data[dates[0]];
-    const users = firstDateMeals.slice().sort((a, b) => a.user_id - b.user_id);
+    // Extract user list from the first date's data (already in display order).
+    const users = data[dates[0]];
 
     let tableHtml = '<table>';
-    // Header row 1: Cook defaults link | each user (as link with colspan=2)
+    // Header row 1: Cook defaults link | each user (as link spanning all periods)
     tableHtml += '<thead><tr><th><a href="/cook-defaults.html">コック</a></th>';
     users.forEach(user => {
-      tableHtml += '<th colspan="2"><a href="/user-defaults.html?user_id=' + user.user_id + '">' + user.user_name + '</a></th>';
+      tableHtml += '<th colspan="' + mealPeriods.length + '"><a href="/user-defaults.html?user_id=' + user.user_id + '">' + user.user_name + '</a></th>';
     });
     tableHtml += '</tr>';
-    // Header row 2: Date | Lunch/Dinner for each user.
+    // Header row 2: Date | each meal period for each user.
     tableHtml += '<tr><th>' + translations[currentLang].dateHeader + '</th>';
     users.forEach(() => {
-      tableHtml += '<th>' + translations[currentLang].lunchHeader + '</th><th>' + translations[currentLang].dinnerHeader + '</th>';
+      mealPeriods.forEach(period => {
+        tableHtml += '<th>' + period.name + '</th>';
+      });
     });
     tableHtml += '</tr></thead>';
 
@@ -166,10 +173,8 @@ $(document).ready(function() {
       else dateClass = "weekday";
       tableHtml += '<td class="' + dateClass + '"><a href="/daily.html?date=' + date + '">' + dateDisplay + '</a></td>';
 
-      // Cook info for this date.
-      const cookDay = (cookData && cookData[date]) ? cookData[date] : { lunch: null, dinner: null };
-      const lunchCook = cookDay.lunch;
-      const dinnerCook = cookDay.dinner;
+      // Cook info for this date, keyed by meal period id.
+      const cookDay = (cookData && cookData[date]) ? cookData[date] : {};
 
       // Create a mapping for this date.
       const mealMapping = {};
@@ -178,35 +183,25 @@ $(document).ready(function() {
       });
 
       users.forEach(user => {
-        const meal = mealMapping[user.user_id] || { lunch: 0, dinner: 0, defaultLunch: 0, defaultDinner: 0 };
-        const displayedLunch = meal.lunch === 0 ? meal.defaultLunch : meal.lunch;
-        const displayedDinner = meal.dinner === 0 ? meal.defaultDinner : meal.dinner;
+        const meal = mealMapping[user.user_id] || { options: {}, defaults: {} };
 
-        // Lunch cell: show 各自 text when no cook assigned.
-        if (!lunchCook) {
-          tableHtml += '<td class="cell-kakuji">各自</td>';
-        } else {
-          let lunchCellClass = meal.lunch === 0 ? "cell-gray" : (meal.lunch !== meal.defaultLunch ? "cell-highlight" : "");
-          let lunchSelect = '<select class="lunchSelect" data-user-id="' + user.user_id + '" data-date="' + date + '">';
-          for (let key in mealOptions) {
-            lunchSelect += '<option value="' + key + '"' + (displayedLunch === parseInt(key) ? ' selected' : '') + '>' + mealOptions[key] + '</option>';
+        mealPeriods.forEach(period => {
+          // Show 各自 text when no cook is assigned for this period.
+          if (!cookDay[period.id]) {
+            tableHtml += '<td class="cell-kakuji">各自</td>';
+            return;
           }
-          lunchSelect += '</select>';
-          tableHtml += '<td class="' + lunchCellClass + '">' + lunchSelect + '</td>';
-        }
-
-        // Dinner cell: show 各自 text when no cook assigned.
-        if (!dinnerCook) {
-          tableHtml += '<td class="cell-kakuji">各自</td>';
-        } else {
-          let dinnerCellClass = meal.dinner === 0 ? "cell-gray" : (meal.dinner !== meal.defaultDinner ? "cell-highlight" : "");
-          let dinnerSelect = '<select class="dinnerSelect" data-user-id="' + user.user_id + '" data-date="' + date + '">';
-          for (let key in mealOptions) {
-            dinnerSelect += '<option value="' + key + '"' + (displayedDinner === parseInt(key) ? ' selected' : '') + '>' + mealOptions[key] + '</option>';
-          }
-          dinnerSelect += '</select>';
-          tableHtml += '<td class="' + dinnerCellClass + '">' + dinnerSelect + '</td>';
-        }
+          const value = meal.options[period.id] || 0;
+          const defaultValue = meal.defaults[period.id] || 0;
+          const displayed = value === 0 ? defaultValue : value;
+          const cellClass = value === 0 ? "cell-gray" : (value !== defaultValue ? "cell-highlight" : "");
+          let select = '<select class="mealSelect" data-user-id="' + user.user_id + '" data-date="' + date + '" data-meal-period="' + period.id + '">';
+          mealOptions.forEach(option => {
+            select += '<option value="' + option.id + '"' + (displayed === option.id ? ' selected' : '') + '>' + option.label + '</option>';
+          });
+          select += '</select>';
+          tableHtml += '<td class="' + cellClass + '">' + select + '</td>';
+        });
       });
       tableHtml += '</tr>';
     });
@@ -215,38 +210,125 @@ $(document).ready(function() {
   }
 
   // Attach event handler so that when a dropdown changes, update that meal individually.
-  $(document).on('change', 'select.lunchSelect, select.dinnerSelect', function() {
+  $(document).on('change', 'select.mealSelect', function() {
     const userId = $(this).attr('data-user-id');
     const date = $(this).attr('data-date');
+    const mealPeriod = $(this).attr('data-meal-period');
+    // Only the changed period is sent; other periods are left untouched.
     let payload = {
       user_id: parseInt(userId),
       date: date,
-      lunch: 0,
-      dinner: 0
+      options: {}
     };
-    if ($(this).hasClass("lunchSelect")) {
-      const lunchVal = $(this).val();
-      payload.lunch = parseInt(lunchVal);
-    }
-    if ($(this).hasClass("dinnerSelect")) {
-      const dinnerVal = $(this).val();
-      payload.dinner = parseInt(dinnerVal);
-    }
+    payload.options[mealPeriod] = parseInt($(this).val());
     console.log('Updating meal for user ' + userId + ' on ' + date, payload);
+    saveMeal(payload, false);
+  });
+
+  // Send one meal change. With override, the cook or an admin pushes a change
+  // through a period's cutoff.
+  function saveMeal(payload, override) {
     $.ajax({
-      url: '/api/meals/bulk-update',
+      url: '/api/meals/bulk-update' + (override ? '?override=true' : ''),
       method: 'PUT',
       contentType: 'application/json',
       data: JSON.stringify([payload]),
       success: function(response) {
-        console.log('Meal updated for user ' + userId + ' on ' + date);
+        console.log('Meal updated for user ' + payload.user_id + ' on ' + payload.date);
         loadSchedule();
       },
       error: function(err) {
-        alert('Failed to update meal for user ' + userId);
+        if (err.status === 409 && !override) {
+          // Past the cutoff in reject mode; only the cook or an admin may override.
+          if (confirm('締め切りを過ぎています。料理担当・管理者として変更しますか？')) {
+            saveMeal(payload, true);
+          } else {
+            loadSchedule();
+          }
+          return;
+        }
+        if (err.status === 403) {
+          // Not our row and we are not the cook that day; explain and restore the select.
+          const rows = (err.responseJSON && err.responseJSON.rows) || [];
+          alert(rows.map(function(r) { return r.reason; }).join('\n') || 'Not allowed to update this meal.');
+          loadSchedule();
+          return;
+        }
+        alert('Failed to update meal for user ' + payload.user_id);
         console.error(err);
       }
     });
+  }
+
+  // Show late changes the logged-in cook has not acknowledged yet.
+  function loadAcknowledgements() {
+    $.ajax({ url: '/api/acknowledgements/pending', method: 'GET' }).done(function(pending) {
+      if (pending.length === 0) {
+        $('#pendingAcks').empty();
+        return;
+      }
+      const periodNames = {};
+      mealPeriods.forEach(function(p) { periodNames[p.id] = p.name; });
+      const optionLabels = {};
+      mealOptions.forEach(function(o) { optionLabels[o.id] = o.label; });
+      let html = '<h3>' + (currentLang === 'ja' ? '未確認の直前変更' : 'Late changes to acknowledge') + '</h3><ul>';
+      pending.forEach(function(a) {
+        const ch = a.change;
+        html += '<li>' + ch.date + ' ' + ch.user_name + ' ' + (periodNames[ch.meal_period] || ch.meal_period) +
+          ' → ' + (optionLabels[ch.new_option] || ch.new_option) +
+          (ch.actor_name ? '（' + ch.actor_name + '）' : '') +
+          ' <button class="ackButton" data-ack-id="' + a.id + '">' + (currentLang === 'ja' ? '確認' : 'OK') + '</button></li>';
+      });
+      html += '</ul>';
+      $('#pendingAcks').html(html);
+    });
+  }
+
+  $(document).on('click', 'button.ackButton', function() {
+    $.ajax({ url: '/api/acknowledgements/' + $(this).attr('data-ack-id'), method: 'POST' }).always(function() {
+      loadAcknowledgements();
+    });
+  });
+
+  // Download the displayed range as CSV (resolved choices, with where each came from).
+  $('#exportCsv').click(function() {
+    window.location.href = '/api/export/meals?' + $.param({ date: $('#startDate').val(), days: $('#days').val(), format: 'csv' });
+  });
+
+  // Import meal choices from CSV: validate with a dry run first, then commit on confirmation.
+  $('#importCsv').click(function() {
+    $('#importFile').val('').click();
+  });
+  $('#importFile').change(function() {
+    const file = this.files[0];
+    if (!file) return;
+    file.text().then(function(csv) {
+      const post = function(mode) {
+        return $.ajax({ url: '/api/import/meals?mode=' + mode, method: 'POST', contentType: 'text/csv', data: csv, processData: false });
+      };
+      post('dry-run').done(function(report) {
+        if (report.invalid > 0) {
+          alert(report.lines.filter(function(l) { return l.errors; })
+            .map(function(l) { return l.line + ': ' + l.errors.join(', '); }).join('\n'));
+          return;
+        }
+        if (!confirm(report.valid + '行を取り込みますか？')) return;
+        post('commit').done(function() {
+          loadSchedule();
+        }).fail(function(xhr) {
+          alert((xhr.responseJSON && xhr.responseJSON.error) || 'Failed to import meals.');
+        });
+      }).fail(function(xhr) {
+        alert((xhr.responseJSON && xhr.responseJSON.error) || 'Failed to import meals.');
+      });
+    });
+  });
+
+  // Log out and return to the login page.
+  $('#logout').click(function() {
+    $.ajax({ url: '/api/auth/logout', method: 'POST' }).always(function() {
+      window.location.href = '/login.html';
+    });
   });
 
   // Load schedule on initial page load and when the "Load Schedule" button is clicked.
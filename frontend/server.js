@@ -14,6 +14,58 @@ app.use(cors());
 // Set backend API base URL (can be overridden via environment variable)
 const BACKEND_API_BASE = process.env.BACKEND_API_BASE || 'http://backend:8080/api';
 
+// Pass the caller's session (cookie or bearer token) through to the backend,
+// which is what identifies the member making the change.
+function forward(req) {
+  const headers = {};
+  if (req.headers.cookie) headers.cookie = req.headers.cookie;
+  if (req.headers.authorization) headers.authorization = req.headers.authorization;
+  return { headers };
+}
+
+// Relay backend errors (e.g. 401 so the page can redirect to login) and fall back to 500.
+function sendError(res, error, message) {
+  if (error.response) {
+    res.status(error.response.status).json(error.response.data);
+    return;
+  }
+  res.status(500).json({ error: message });
+}
+
+// Proxy endpoints for login/logout. Set-Cookie is relayed so the browser keeps the session.
+app.post('/api/auth/login', async (req, res) => {
+  try {
+    const response = await axios.post(`${BACKEND_API_BASE}/auth/login`, req.body, forward(req));
+    if (response.headers['set-cookie']) res.set('Set-Cookie', response.headers['set-cookie']);
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error logging in:', error.message);
+    sendError(res, error, 'Failed to log in');
+  }
+});
+
+app.post('/api/auth/logout', async (req, res) => {
+  try {
+    const response = await axios.post(`${BACKEND_API_BASE}/auth/logout`, {}, forward(req));
+    if (response.headers['set-cookie']) res.set('Set-Cookie', response.headers['set-cookie']);
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error logging out:', error.message);
+    sendError(res, error, 'Failed to log out');
+  }
+});
+
+// Proxy endpoint for GET /api/auth/me
+app.get('/api/auth/me', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/auth/me`, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching current user:', error.message);
+    sendError(res, error, 'Failed to fetch current user from backend');
+  }
+});
+
 // Health check endpoint for the frontend.
 // This endpoint proxies the backend's /health endpoint.
 app.get('/health', async (req, res) => {
@@ -28,125 +80,548 @@ app.get('/health', async (req, res) => {
   }
 });
 
+// Proxy endpoint for GET /api/meal-periods
+app.get('/api/meal-periods', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/meal-periods`, { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching meal periods:', error.message);
+    sendError(res, error, 'Failed to fetch meal periods from backend');
+  }
+});
+
+// Proxy endpoint for GET /api/meal-options
+app.get('/api/meal-options', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/meal-options`, { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching meal options:', error.message);
+    sendError(res, error, 'Failed to fetch meal options from backend');
+  }
+});
+
 // Proxy endpoint for GET /api/meals
 app.get('/api/meals', async (req, res) => {
   try {
     // Forward query parameters (date and days) to the backend
-    const response = await axios.get(`${BACKEND_API_BASE}/meals`, { params: req.query });
+    const response = await axios.get(`${BACKEND_API_BASE}/meals`, { params: req.query, ...forward(req) });
     res.json(response.data);
   } catch (error) {
     console.error('Error fetching meals:', error.message);
-    res.status(500).json({ error: 'Failed to fetch meals from backend' });
+    sendError(res, error, 'Failed to fetch meals from backend');
+  }
+});
+
+// Proxy endpoints for meal guests
+app.get('/api/meal-guests', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/meal-guests`, { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching meal guests:', error.message);
+    sendError(res, error, 'Failed to fetch meal guests from backend');
+  }
+});
+
+app.post('/api/meal-guests', async (req, res) => {
+  try {
+    const response = await axios.post(`${BACKEND_API_BASE}/meal-guests`, req.body, forward(req));
+    res.status(response.status).json(response.data);
+  } catch (error) {
+    console.error('Error adding meal guests:', error.message);
+    sendError(res, error, 'Failed to add meal guests');
+  }
+});
+
+app.patch('/api/meal-guests/:id', async (req, res) => {
+  try {
+    const response = await axios.patch(`${BACKEND_API_BASE}/meal-guests/${encodeURIComponent(req.params.id)}`, req.body, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error updating meal guests:', error.message);
+    sendError(res, error, 'Failed to update meal guests');
+  }
+});
+
+app.delete('/api/meal-guests/:id', async (req, res) => {
+  try {
+    const response = await axios.delete(`${BACKEND_API_BASE}/meal-guests/${encodeURIComponent(req.params.id)}`, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error deleting meal guests:', error.message);
+    sendError(res, error, 'Failed to delete meal guests');
   }
 });
 
 // Proxy endpoint for bulk update of meals.
 app.put('/api/meals/bulk-update', async (req, res) => {
   try {
-    const response = await axios.put(`${BACKEND_API_BASE}/meals/bulk-update`, req.body);
+    const response = await axios.put(`${BACKEND_API_BASE}/meals/bulk-update`, req.body, { params: req.query, ...forward(req) });
     res.json(response.data);
   } catch (error) {
     console.error('Error bulk updating meals:', error.message);
-    res.status(500).json({ error: 'Failed to bulk update meals in backend' });
+    sendError(res, error, 'Failed to bulk update meals in backend');
   }
 });
 
 // Proxy endpoint for GET /api/user-defaults/:user_id
 app.get('/api/user-defaults/:user_id', async (req, res) => {
   try {
-    const response = await axios.get(`${BACKEND_API_BASE}/user-defaults/${req.params.user_id}`);
+    const response = await axios.get(`${BACKEND_API_BASE}/user-defaults/${req.params.user_id}`, forward(req));
     res.json(response.data);
   } catch (error) {
     console.error('Error fetching user defaults:', error.message);
-    res.status(500).json({ error: 'Failed to fetch user defaults from backend' });
+    sendError(res, error, 'Failed to fetch user defaults from backend');
   }
 });
 
 // Proxy endpoint for PUT /api/user-defaults/:user_id
 app.put('/api/user-defaults/:user_id', async (req, res) => {
   try {
-    const response = await axios.put(`${BACKEND_API_BASE}/user-defaults/${req.params.user_id}`, req.body);
+    const response = await axios.put(`${BACKEND_API_BASE}/user-defaults/${req.params.user_id}`, req.body, forward(req));
     res.json(response.data);
   } catch (error) {
     console.error('Error updating user defaults:', error.message);
-    res.status(500).json({ error: 'Failed to update user defaults in backend' });
+    sendError(res, error, 'Failed to update user defaults in backend');
   }
 });
 
 // Proxy endpoint for GET /api/users
 app.get('/api/users', async (req, res) => {
   try {
-    const response = await axios.get(`${BACKEND_API_BASE}/users`);
+    const response = await axios.get(`${BACKEND_API_BASE}/users`, forward(req));
     res.json(response.data);
   } catch (error) {
     console.error('Error fetching users:', error.message);
-    res.status(500).json({ error: 'Failed to fetch users from backend' });
+    sendError(res, error, 'Failed to fetch users from backend');
   }
 });
 
 // Proxy endpoint for PUT /api/users/:user_id/roles
 app.put('/api/users/:user_id/roles', async (req, res) => {
   try {
-    const response = await axios.put(`${BACKEND_API_BASE}/users/${req.params.user_id}/roles`, req.body);
+    const response = await axios.put(`${BACKEND_API_BASE}/users/${req.params.user_id}/roles`, req.body, forward(req));
     res.json(response.data);
   } catch (error) {
     console.error('Error updating user roles:', error.message);
-    res.status(500).json({ error: 'Failed to update user roles in backend' });
+    sendError(res, error, 'Failed to update user roles in backend');
   }
 });
 
 // Proxy endpoint for GET /api/cook-schedules
 app.get('/api/cook-schedules', async (req, res) => {
   try {
-    const response = await axios.get(`${BACKEND_API_BASE}/cook-schedules`, { params: req.query });
+    const response = await axios.get(`${BACKEND_API_BASE}/cook-schedules`, { params: req.query, ...forward(req) });
     res.json(response.data);
   } catch (error) {
     console.error('Error fetching cook schedules:', error.message);
-    res.status(500).json({ error: 'Failed to fetch cook schedules from backend' });
+    sendError(res, error, 'Failed to fetch cook schedules from backend');
   }
 });
 
 // Proxy endpoint for PUT /api/cook-schedules
 app.put('/api/cook-schedules', async (req, res) => {
   try {
-    const response = await axios.put(`${BACKEND_API_BASE}/cook-schedules`, req.body);
+    const response = await axios.put(`${BACKEND_API_BASE}/cook-schedules`, req.body, forward(req));
     res.json(response.data);
   } catch (error) {
     console.error('Error updating cook schedules:', error.message);
-    res.status(500).json({ error: 'Failed to update cook schedules in backend' });
+    sendError(res, error, 'Failed to update cook schedules in backend');
   }
 });
 
 // Proxy endpoint for DELETE /api/cook-schedules
 app.delete('/api/cook-schedules', async (req, res) => {
   try {
-    const response = await axios.delete(`${BACKEND_API_BASE}/cook-schedules`, { params: req.query });
+    const response = await axios.delete(`${BACKEND_API_BASE}/cook-schedules`, { params: req.query, ...forward(req) });
     res.json(response.data);
   } catch (error) {
     console.error('Error deleting cook schedules:', error.message);
-    res.status(500).json({ error: 'Failed to delete cook schedules from backend' });
+    sendError(res, error, 'Failed to delete cook schedules from backend');
+  }
+});
+
+// Proxy endpoint for POST /api/cook-schedules/generate (mode=preview|commit in the query)
+app.post('/api/cook-schedules/generate', async (req, res) => {
+  try {
+    const response = await axios.post(`${BACKEND_API_BASE}/cook-schedules/generate`, req.body,
+      { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error generating cook schedules:', error.message);
+    sendError(res, error, 'Failed to generate cook schedules in backend');
   }
 });
 
 // Proxy endpoint for GET /api/cook-default-schedules
 app.get('/api/cook-default-schedules', async (req, res) => {
   try {
-    const response = await axios.get(`${BACKEND_API_BASE}/cook-default-schedules`);
+    const response = await axios.get(`${BACKEND_API_BASE}/cook-default-schedules`, forward(req));
     res.json(response.data);
   } catch (error) {
     console.error('Error fetching cook default schedules:', error.message);
-    res.status(500).json({ error: 'Failed to fetch cook default schedules from backend' });
+    sendError(res, error, 'Failed to fetch cook default schedules from backend');
   }
 });
 
 // Proxy endpoint for PUT /api/cook-default-schedules
 app.put('/api/cook-default-schedules', async (req, res) => {
   try {
-    const response = await axios.put(`${BACKEND_API_BASE}/cook-default-schedules`, req.body);
+    const response = await axios.put(`${BACKEND_API_BASE}/cook-default-schedules`, req.body, forward(req));
     res.json(response.data);
   } catch (error) {
     console.error('Error updating cook default schedules:', error.message);
-    res.status(500).json({ error: 'Failed to update cook default schedules in backend' });
+    sendError(res, error, 'Failed to update cook default schedules in backend');
+  }
+});
+
+// Proxy endpoints for notification channels (admin only)
+app.get('/api/notification-channels', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/notification-channels`, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching notification channels:', error.message);
+    sendError(res, error, 'Failed to fetch notification channels from backend');
+  }
+});
+
+app.post('/api/notification-channels', async (req, res) => {
+  try {
+    const response = await axios.post(`${BACKEND_API_BASE}/notification-channels`, req.body, forward(req));
+    res.status(response.status).json(response.data);
+  } catch (error) {
+    console.error('Error adding notification channel:', error.message);
+    sendError(res, error, 'Failed to add notification channel');
+  }
+});
+
+app.put('/api/notification-channels/:id', async (req, res) => {
+  try {
+    const response = await axios.put(`${BACKEND_API_BASE}/notification-channels/${encodeURIComponent(req.params.id)}`,
+      req.body, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error updating notification channel:', error.message);
+    sendError(res, error, 'Failed to update notification channel');
+  }
+});
+
+app.delete('/api/notification-channels/:id', async (req, res) => {
+  try {
+    const response = await axios.delete(`${BACKEND_API_BASE}/notification-channels/${encodeURIComponent(req.params.id)}`,
+      forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error deleting notification channel:', error.message);
+    sendError(res, error, 'Failed to delete notification channel');
+  }
+});
+
+app.post('/api/notification-channels/:id/test', async (req, res) => {
+  try {
+    const response = await axios.post(`${BACKEND_API_BASE}/notification-channels/${encodeURIComponent(req.params.id)}/test`,
+      {}, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error sending test notification:', error.message);
+    sendError(res, error, 'Failed to send test notification');
+  }
+});
+
+// Proxy endpoints for menus
+app.get('/api/menus', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/menus`, { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching menus:', error.message);
+    sendError(res, error, 'Failed to fetch menus from backend');
+  }
+});
+
+app.put('/api/menus', async (req, res) => {
+  try {
+    const response = await axios.put(`${BACKEND_API_BASE}/menus`, req.body, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error updating menus:', error.message);
+    sendError(res, error, 'Failed to update menus in backend');
+  }
+});
+
+// Proxy endpoints for the recipe library. ?tag= may repeat, so arrays are sent as
+// tag=a&tag=b rather than axios' default tag[]=a.
+app.get('/api/recipes', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/recipes`,
+      { params: req.query, paramsSerializer: { indexes: null }, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching recipes:', error.message);
+    sendError(res, error, 'Failed to fetch recipes from backend');
+  }
+});
+
+app.get('/api/recipes/suggestions', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/recipes/suggestions`, { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching recipe suggestions:', error.message);
+    sendError(res, error, 'Failed to fetch recipe suggestions from backend');
+  }
+});
+
+app.get('/api/recipes/:id', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/recipes/${encodeURIComponent(req.params.id)}`, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching recipe:', error.message);
+    sendError(res, error, 'Failed to fetch recipe from backend');
+  }
+});
+
+app.post('/api/recipes', async (req, res) => {
+  try {
+    const response = await axios.post(`${BACKEND_API_BASE}/recipes`, req.body, forward(req));
+    res.status(response.status).json(response.data);
+  } catch (error) {
+    console.error('Error adding recipe:', error.message);
+    sendError(res, error, 'Failed to add recipe');
+  }
+});
+
+app.put('/api/recipes/:id', async (req, res) => {
+  try {
+    const response = await axios.put(`${BACKEND_API_BASE}/recipes/${encodeURIComponent(req.params.id)}`, req.body, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error updating recipe:', error.message);
+    sendError(res, error, 'Failed to update recipe');
+  }
+});
+
+app.delete('/api/recipes/:id', async (req, res) => {
+  try {
+    const response = await axios.delete(`${BACKEND_API_BASE}/recipes/${encodeURIComponent(req.params.id)}`, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error deleting recipe:', error.message);
+    sendError(res, error, 'Failed to delete recipe');
+  }
+});
+
+// Proxy endpoint for GET /api/shopping-list. format=text returns a plain-text
+// checklist, so the body is relayed with the backend's Content-Type.
+app.get('/api/shopping-list', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/shopping-list`,
+      { params: req.query, responseType: 'text', ...forward(req) });
+    res.type(response.headers['content-type']).send(response.data);
+  } catch (error) {
+    console.error('Error fetching shopping list:', error.message);
+    if (error.response) {
+      // Error bodies are text too; pass them on unparsed.
+      res.status(error.response.status).type(error.response.headers['content-type']).send(error.response.data);
+      return;
+    }
+    res.status(500).json({ error: 'Failed to fetch shopping list from backend' });
+  }
+});
+
+// Proxy endpoint for GET /api/summary
+app.get('/api/summary', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/summary`, { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching summary:', error.message);
+    sendError(res, error, 'Failed to fetch summary from backend');
+  }
+});
+
+// Proxy endpoint for GET /api/export/meals. The backend streams the file, so it is
+// piped through with its Content-Type and Content-Disposition instead of buffered.
+app.get('/api/export/meals', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/export/meals`,
+      { params: req.query, responseType: 'stream', ...forward(req) });
+    res.set({
+      'Content-Type': response.headers['content-type'],
+      'Content-Disposition': response.headers['content-disposition'],
+    });
+    response.data.pipe(res);
+  } catch (error) {
+    console.error('Error exporting meals:', error.message);
+    if (error.response) {
+      // Error bodies are streams too; report the status with a generic message.
+      res.status(error.response.status).json({ error: 'Failed to export meals' });
+      return;
+    }
+    res.status(500).json({ error: 'Failed to export meals' });
+  }
+});
+
+// Proxy endpoints for CSV imports. The file is sent as the raw text/csv body and
+// relayed as-is; mode=dry-run|commit passes through in the query string.
+const csvBody = bodyParser.text({ type: 'text/csv', limit: '1mb' });
+['meals', 'user-defaults'].forEach((kind) => {
+  app.post(`/api/import/${kind}`, csvBody, async (req, res) => {
+    try {
+      const { headers } = forward(req);
+      const response = await axios.post(`${BACKEND_API_BASE}/import/${kind}`, req.body,
+        { params: req.query, headers: { ...headers, 'Content-Type': 'text/csv' } });
+      res.json(response.data);
+    } catch (error) {
+      console.error(`Error importing ${kind}:`, error.message);
+      sendError(res, error, `Failed to import ${kind}`);
+    }
+  });
+});
+
+// Proxy endpoint for GET /api/stats/:kind (cooks, members)
+app.get('/api/stats/:kind', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/stats/${encodeURIComponent(req.params.kind)}`,
+      { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching stats:', error.message);
+    sendError(res, error, 'Failed to fetch stats from backend');
+  }
+});
+
+// Proxy endpoint for GET /api/history
+app.get('/api/history', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/history`, { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching history:', error.message);
+    sendError(res, error, 'Failed to fetch history from backend');
+  }
+});
+
+// Proxy endpoints for cook acknowledgements of late changes
+app.get('/api/acknowledgements/pending', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/acknowledgements/pending`, { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching acknowledgements:', error.message);
+    sendError(res, error, 'Failed to fetch acknowledgements from backend');
+  }
+});
+
+app.post('/api/acknowledgements/:id', async (req, res) => {
+  try {
+    const response = await axios.post(`${BACKEND_API_BASE}/acknowledgements/${req.params.id}`, {}, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error acknowledging change:', error.message);
+    sendError(res, error, 'Failed to acknowledge change');
+  }
+});
+
+// Proxy endpoints for dietary profiles
+app.get('/api/users/:user_id/dietary', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/users/${encodeURIComponent(req.params.user_id)}/dietary`, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching dietary profile:', error.message);
+    sendError(res, error, 'Failed to fetch dietary profile from backend');
+  }
+});
+
+app.put('/api/users/:user_id/dietary', async (req, res) => {
+  try {
+    const response = await axios.put(`${BACKEND_API_BASE}/users/${encodeURIComponent(req.params.user_id)}/dietary`, req.body, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error updating dietary profile:', error.message);
+    sendError(res, error, 'Failed to update dietary profile');
+  }
+});
+
+// Proxy endpoint for a member's bento note
+app.put('/api/users/:user_id/bento-note', async (req, res) => {
+  try {
+    const response = await axios.put(`${BACKEND_API_BASE}/users/${encodeURIComponent(req.params.user_id)}/bento-note`, req.body, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error updating bento note:', error.message);
+    sendError(res, error, 'Failed to update bento note');
+  }
+});
+
+// Proxy endpoints for a member's notification preferences
+app.get('/api/users/:user_id/notification-preferences', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/users/${encodeURIComponent(req.params.user_id)}/notification-preferences`, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching notification preferences:', error.message);
+    sendError(res, error, 'Failed to fetch notification preferences');
+  }
+});
+
+app.put('/api/users/:user_id/notification-preferences', async (req, res) => {
+  try {
+    const response = await axios.put(`${BACKEND_API_BASE}/users/${encodeURIComponent(req.params.user_id)}/notification-preferences`, req.body, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error updating notification preferences:', error.message);
+    sendError(res, error, 'Failed to update notification preferences');
+  }
+});
+
+// Proxy endpoint for GET /api/bento
+app.get('/api/bento', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/bento`, { params: req.query, ...forward(req) });
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error fetching bento list:', error.message);
+    sendError(res, error, 'Failed to fetch bento list from backend');
+  }
+});
+
+// Proxy endpoints for calendar feed tokens
+app.post('/api/users/:user_id/calendar-token', async (req, res) => {
+  try {
+    const response = await axios.post(`${BACKEND_API_BASE}/users/${req.params.user_id}/calendar-token`, {}, forward(req));
+    res.status(response.status).json(response.data);
+  } catch (error) {
+    console.error('Error issuing calendar token:', error.message);
+    sendError(res, error, 'Failed to issue calendar token');
+  }
+});
+
+app.delete('/api/users/:user_id/calendar-token', async (req, res) => {
+  try {
+    const response = await axios.delete(`${BACKEND_API_BASE}/users/${req.params.user_id}/calendar-token`, forward(req));
+    res.json(response.data);
+  } catch (error) {
+    console.error('Error revoking calendar token:', error.message);
+    sendError(res, error, 'Failed to revoke calendar token');
+  }
+});
+
+// Proxy endpoints for the iCalendar feeds. Calendar apps send no session, so only the
+// ?token= query is passed through and the body is relayed as text/calendar.
+app.get('/api/calendar/:kind/:file', async (req, res) => {
+  try {
+    const response = await axios.get(`${BACKEND_API_BASE}/calendar/${req.params.kind}/${req.params.file}`,
+      { params: req.query, responseType: 'text' });
+    res.type('text/calendar; charset=utf-8').send(response.data);
+  } catch (error) {
+    console.error('Error fetching calendar:', error.message);
+    sendError(res, error, 'Failed to fetch calendar from backend');
   }
 });
 